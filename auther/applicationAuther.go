@@ -24,8 +24,13 @@ func (a *applicationAuther) ValidateAuth(app domain.Application, username string
 }
 
 // Auth wraps defaultInstance.Auth
-func (a *applicationAuther) Auth(app domain.Application, deviceType, username string, password, newPassword []byte, meta map[string]string, session *domain.Session) (*domain.Session, error) {
-	return a.getAuther(app, username).Auth(app, deviceType, username, password, newPassword, meta, session)
+func (a *applicationAuther) Auth(app domain.Application, deviceType, username string, password, newPassword []byte, totpCode, recoveryCode string, meta map[string]string, session *domain.Session) (*domain.Session, error) {
+	if err := checkRateLimits(app, deviceType, username, meta); err != nil {
+		return nil, err
+	}
+	return withLockout(app, deviceType, username, meta, func() (*domain.Session, error) {
+		return a.getAuther(app, username).Auth(app, deviceType, username, password, newPassword, totpCode, recoveryCode, meta, session)
+	})
 }
 
 // AuthAs wraps defaultInstance.AuthAs
@@ -35,7 +40,12 @@ func (a *applicationAuther) AuthAs(app domain.Application, deviceType, username
 
 // Auth wraps defaultInstance.Auth
 func (a *applicationAuther) OAuth(app domain.Application, deviceType, username, oauthtoken, provider string, meta map[string]string) (*domain.Session, error) {
-	return a.getAuther(app, username).OAuth(app, deviceType, username, oauthtoken, provider, meta)
+	if err := checkRateLimits(app, deviceType, username, meta); err != nil {
+		return nil, err
+	}
+	return withLockout(app, deviceType, username, meta, func() (*domain.Session, error) {
+		return a.getAuther(app, username).OAuth(app, deviceType, username, oauthtoken, provider, meta)
+	})
 }
 
 // AutoRenew wraps defaultInstance.AutoRenew
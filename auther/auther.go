@@ -9,7 +9,7 @@ import (
 const sessionIdSizeInBits = 1280
 
 type Auther interface {
-	Auth(app domain.Application, deviceType, username string, password, newPassword []byte, meta map[string]string, session *domain.Session) (*domain.Session, error)
+	Auth(app domain.Application, deviceType, username string, password, newPassword []byte, totpCode, recoveryCode string, meta map[string]string, session *domain.Session) (*domain.Session, error)
 	AuthAs(app domain.Application, deviceType, username string, meta map[string]string) (*domain.Session, error)
 	OAuth(app domain.Application, deviceType, username, oauthtoken, oauthprovider string, meta map[string]string) (*domain.Session, error)
 	AutoRenew(s *domain.Session) (*domain.Session, error)
@@ -38,8 +38,8 @@ func ValidateAuth(app domain.Application, username string, password []byte) erro
 }
 
 // Auth wraps defaultInstance.Auth
-func Auth(app domain.Application, deviceType, username string, password, newPassword []byte, meta map[string]string, session *domain.Session) (*domain.Session, error) {
-	return defaultInstance.Auth(app, deviceType, username, password, newPassword, meta, session)
+func Auth(app domain.Application, deviceType, username string, password, newPassword []byte, totpCode, recoveryCode string, meta map[string]string, session *domain.Session) (*domain.Session, error) {
+	return defaultInstance.Auth(app, deviceType, username, password, newPassword, totpCode, recoveryCode, meta, session)
 }
 
 // OAuth wraps defaultInstance.OAuth
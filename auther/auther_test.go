@@ -0,0 +1,739 @@
+package auther
+
+import (
+	"crypto/md5"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/login-service/signer"
+	"github.com/HailoOSS/service/sync"
+)
+
+type noopLock struct{}
+
+func (l *noopLock) Unlock() {}
+
+type fakeSigner struct{}
+
+func (s *fakeSigner) Sign(t *domain.Token) (*domain.Token, error) {
+	signed := t.Copy()
+	signed.Sign([]byte("signed"))
+	return signed, nil
+}
+
+func (s *fakeSigner) Verify(t *domain.Token) bool {
+	return string(t.DecodedSig()) == "signed"
+}
+
+// setupMemory runs the auther against the in-memory store, with no ZooKeeper and a fake signer
+func setupMemory(t *testing.T) func() {
+	dao.SetStore(dao.NewMemoryStore())
+	signer.SetSigner(&fakeSigner{})
+	regionLock = func(id []byte) (sync.Lock, error) { return &noopLock{}, nil }
+
+	user := &domain.User{
+		App:     domain.Application("DRIVER"),
+		Uid:     "auther2",
+		Ids:     []domain.Id{"auther2@example.com"},
+		Created: time.Now(),
+		Roles:   []string{"DRIVER"},
+	}
+	if err := user.SetPassword("foobarbaz"); err != nil {
+		t.Fatalf("Failed to set password for user: %v", err)
+	}
+	if err := dao.CreateUser(user, "foobarbaz"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	return func() {
+		regionLock = sync.RegionLock
+	}
+}
+
+func TestAuthReadExpireInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2@example.com", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, sess, "Expecting a session") {
+		return
+	}
+	assert.Equal(t, "auther2", sess.Token.Id)
+	assert.True(t, signer.Verify(&sess.Token))
+
+	read, err := dao.ReadSession(sess.Id)
+	assert.NoError(t, err)
+	if assert.NotNil(t, read) {
+		assert.Equal(t, sess.Token.String(), read.Token.String())
+	}
+
+	// a second login on the same device replaces the first
+	second, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	if assert.NotNil(t, second) {
+		assert.NotEqual(t, sess.Id, second.Id)
+	}
+	read, _ = dao.ReadSession(sess.Id)
+	assert.Nil(t, read, "Expecting first session to have been expired")
+
+	assert.NoError(t, Expire(second))
+	read, _ = dao.ReadSession(second.Id)
+	assert.Nil(t, read, "Expecting session to be gone after expiry")
+
+	logins, _, err := dao.ReadUserLogins(domain.Application("DRIVER"), "auther2", time.Now().Add(-time.Hour), time.Now(), 10, "")
+	assert.NoError(t, err)
+	assert.Len(t, logins, 2)
+}
+
+func TestAuthBadPasswordInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("wrong"), nil, "", "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, sess)
+}
+
+func TestAuthMigratesH1PasswordInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	app := domain.Application("DRIVER")
+	user, err := dao.ReadUser(app, "auther2")
+	if !assert.NoError(t, err) || !assert.NotNil(t, user) {
+		return
+	}
+	// an H1 hash of a password today's policy rejects, for being the user's own UID
+	user.Password = []byte(fmt.Sprintf("%x", md5.Sum([]byte("auther2"+"2103ccff866295b95e057e9c3a75ceaf"))))
+	assert.True(t, user.OldHashFormat())
+	assert.True(t, domain.TestPolicy("auther2", user).AnyErrors())
+	assert.NoError(t, dao.UpdateUser(user))
+
+	sess, err := Auth(app, "cli", "auther2", []byte("auther2"), nil, "", "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess)
+
+	migrated, err := dao.ReadUser(app, "auther2")
+	if assert.NoError(t, err) && assert.NotNil(t, migrated) {
+		assert.False(t, migrated.OldHashFormat(), "Expecting the H1 hash to have been migrated regardless of policy")
+		assert.NoError(t, migrated.PasswordMatches([]byte("auther2")))
+		assert.Equal(t, user.PasswordChange.Unix(), migrated.PasswordChange.Unix(), "Expecting no password change")
+	}
+}
+
+func TestAuthMfaInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	secret, err := domain.NewTotpSecret()
+	assert.NoError(t, err)
+	user, err := dao.ReadUser(domain.Application("DRIVER"), "auther2")
+	if !assert.NoError(t, err) || !assert.NotNil(t, user) {
+		return
+	}
+	user.TotpSecret = secret
+	assert.NoError(t, dao.UpdateUser(user))
+
+	// a correct password alone gets a challenge, not a session
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	assert.Equal(t, ErrorMfaRequired, err)
+	assert.Nil(t, sess)
+
+	sess, err = Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "123456x", "", map[string]string{}, nil)
+	assert.Equal(t, ErrorMfaInvalid, err)
+	assert.Nil(t, sess)
+
+	// a code is no use without the password
+	code, _ := domain.TotpCode(secret, time.Now())
+	sess, err = Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("wrong"), nil, code, "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, sess)
+
+	sess, err = Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, code, "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess)
+
+	// each code can only be used once, even though it's still within the skew window
+	sess, err = Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, code, "", map[string]string{}, nil)
+	assert.Equal(t, ErrorMfaInvalid, err)
+	assert.Nil(t, sess)
+
+	// ...and nor can the one before it
+	previous, _ := domain.TotpCode(secret, time.Now().Add(-30*time.Second))
+	sess, err = Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, previous, "", map[string]string{}, nil)
+	assert.Equal(t, ErrorMfaInvalid, err)
+	assert.Nil(t, sess)
+}
+
+func TestAuthRecoveryCodeInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	user, err := dao.ReadUser(domain.Application("DRIVER"), "auther2")
+	if !assert.NoError(t, err) || !assert.NotNil(t, user) {
+		return
+	}
+	user.TotpSecret, _ = domain.NewTotpSecret()
+	codes, err := user.NewRecoveryCodes()
+	assert.NoError(t, err)
+	assert.NoError(t, dao.UpdateUser(user))
+
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "aaaaa-aaaaa", map[string]string{}, nil)
+	assert.Equal(t, ErrorMfaInvalid, err)
+	assert.Nil(t, sess)
+
+	sess, err = Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", codes[0], map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess)
+
+	// it's single use
+	sess, err = Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", codes[0], map[string]string{}, nil)
+	assert.Equal(t, ErrorMfaInvalid, err)
+	assert.Nil(t, sess)
+
+	logins, _, err := dao.ReadUserLogins(domain.Application("DRIVER"), "auther2", time.Now().Add(-time.Hour), time.Now(), 10, "")
+	if assert.NoError(t, err) && assert.Len(t, logins, 1) {
+		assert.Equal(t, "true", logins[0].Meta["recoveryCodeUsed"])
+		assert.Equal(t, "9", logins[0].Meta["recoveryCodesRemaining"])
+	}
+}
+
+func TestUpdateMfaInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	app := domain.Application("DRIVER")
+	secret, _ := domain.NewTotpSecret()
+	stale, err := dao.ReadUser(app, "auther2")
+	if !assert.NoError(t, err) || !assert.NotNil(t, stale) {
+		return
+	}
+	stale.TotpSecret = secret
+	assert.NoError(t, dao.UpdateUser(stale))
+
+	spend := func(code string) func(*domain.User) error {
+		return func(u *domain.User) error {
+			if !u.UseTotpCode(code) {
+				return ErrorMfaInvalid
+			}
+			return nil
+		}
+	}
+	code, _ := domain.TotpCode(secret, time.Now())
+	_, err = UpdateMfa(app, stale.Uid, spend(code))
+	assert.NoError(t, err)
+
+	// the user is read afresh under lock, so a copy from before the code was spent can't spend it again
+	_, err = UpdateMfa(stale.App, stale.Uid, spend(code))
+	assert.Equal(t, ErrorMfaInvalid, err)
+	sess, err := Auth(app, "cli", "auther2", []byte("foobarbaz"), nil, code, "", map[string]string{}, nil)
+	assert.Equal(t, ErrorMfaInvalid, err)
+	assert.Nil(t, sess)
+
+	user, err := UpdateMfa(app, "nobody", spend(code))
+	assert.NoError(t, err)
+	assert.Nil(t, user)
+}
+
+func TestAuthRecoveryCodeNotSpentByFailedLoginInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	user, err := dao.ReadUser(domain.Application("DRIVER"), "auther2")
+	if !assert.NoError(t, err) || !assert.NotNil(t, user) {
+		return
+	}
+	user.TotpSecret, _ = domain.NewTotpSecret()
+	codes, err := user.NewRecoveryCodes()
+	assert.NoError(t, err)
+	user.Status = "disabled"
+	assert.NoError(t, dao.UpdateUser(user))
+
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", codes[0], map[string]string{}, nil)
+	assert.Equal(t, ErrorAccountIsDisabled, err)
+	assert.Nil(t, sess)
+
+	user, err = dao.ReadUser(domain.Application("DRIVER"), "auther2")
+	if assert.NoError(t, err) && assert.NotNil(t, user) {
+		assert.Len(t, user.RecoveryCodes, 10, "Expecting the recovery code not to have been spent")
+	}
+}
+
+func TestAuthLockoutInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	// DRIVER locks after 10 failures; trying different IDs all counts against the same user
+	for i := 0; i < 10; i++ {
+		username := "auther2"
+		if i%2 == 0 {
+			username = "auther2@example.com"
+		}
+		sess, err := Auth(domain.Application("DRIVER"), "cli", username, []byte("wrong"), nil, "", "", map[string]string{}, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, sess)
+	}
+
+	lockout, err := dao.ReadLockout(domain.Application("DRIVER"), "auther2")
+	if assert.NoError(t, err) && assert.NotNil(t, lockout) {
+		assert.Equal(t, 10, lockout.Failures)
+	}
+
+	// even the right password is refused
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	assert.Equal(t, ErrorAccountIsLocked, err)
+	assert.Nil(t, sess)
+
+	// until an ADMIN unlocks them
+	assert.NoError(t, dao.DeleteLockout(domain.Application("DRIVER"), "auther2"))
+	sess, err = Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess)
+}
+
+func TestAuthSuccessClearsFailuresInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("wrong"), nil, "", "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, sess)
+	lockout, _ := dao.ReadLockout(domain.Application("DRIVER"), "auther2")
+	if assert.NotNil(t, lockout) {
+		assert.Equal(t, 1, lockout.Failures)
+	}
+
+	sess, err = Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess)
+	lockout, _ = dao.ReadLockout(domain.Application("DRIVER"), "auther2")
+	assert.Nil(t, lockout)
+}
+
+func TestAuthRateLimitInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	// DRIVER allows 60 attempts at once from an IP, whatever the username
+	meta := map[string]string{"ip": "10.0.0.1"}
+	for i := 0; i < 60; i++ {
+		_, err := Auth(domain.Application("DRIVER"), "cli", fmt.Sprintf("nobody%d", i), []byte("wrong"), nil, "", "", meta, nil)
+		assert.NoError(t, err)
+	}
+	_, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "", meta, nil)
+	assert.Equal(t, ErrorRateLimited, err)
+
+	// and 10 for a username, whatever the IP
+	for i := 0; i < 10; i++ {
+		_, err := Auth(domain.Application("DRIVER"), "cli", "nobody", []byte("wrong"), nil, "", "", map[string]string{"ip": fmt.Sprintf("10.0.1.%d", i)}, nil)
+		assert.NoError(t, err)
+	}
+	_, err = Auth(domain.Application("DRIVER"), "cli", "NOBODY", []byte("wrong"), nil, "", "", map[string]string{}, nil)
+	assert.Equal(t, ErrorRateLimited, err)
+
+	// other users from other IPs are unaffected
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{"ip": "10.0.0.2"}, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess)
+}
+
+func TestAuthRecordsRateLimitedOncePerWindowInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	app := domain.Application("DRIVER")
+	start := time.Now().Add(-time.Minute)
+	for i := 0; i < 10; i++ {
+		_, err := Auth(app, "cli", "auther2@example.com", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+		assert.NoError(t, err)
+	}
+	for i := 0; i < 5; i++ {
+		_, err := Auth(app, "cli", "auther2@example.com", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+		assert.Equal(t, ErrorRateLimited, err)
+	}
+
+	// recorded against the user's UID, however many times they were throttled
+	failures, _, err := dao.ReadUserFailedLogins(app, "auther2", start, time.Now(), 10, "")
+	assert.NoError(t, err)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, domain.FailedLoginRateLimited, failures[0].Reason)
+		assert.Equal(t, "auther2@example.com", failures[0].Username)
+	}
+}
+
+func TestAuthRecordsFailedLoginsInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	app := domain.Application("DRIVER")
+	start := time.Now().Add(-time.Minute)
+
+	sess, err := Auth(app, "cli", "auther2@example.com", []byte("wrong"), nil, "", "", map[string]string{"ip": "10.0.0.1"}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, sess)
+	sess, err = Auth(app, "cli", "nobody", []byte("wrong"), nil, "", "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, sess)
+	sess, err = Auth(app, "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess)
+
+	failures, _, err := dao.ReadUserFailedLogins(app, "auther2", start, time.Now(), 10, "")
+	assert.NoError(t, err)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, "auther2@example.com", failures[0].Username)
+		assert.Equal(t, domain.FailedLoginBadCredentials, failures[0].Reason)
+		assert.Equal(t, "h2.DRIVER", failures[0].AuthMechanism)
+		assert.Equal(t, "cli", failures[0].DeviceType)
+		assert.Equal(t, "10.0.0.1", failures[0].Meta["ip"])
+	}
+
+	// attempts on unknown users are recorded against the username tried
+	failures, _, err = dao.ReadUserFailedLogins(app, "nobody", start, time.Now(), 10, "")
+	assert.NoError(t, err)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, domain.FailedLoginUnknownUser, failures[0].Reason)
+	}
+}
+
+func TestIntrospectInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	if !assert.NoError(t, err) || !assert.NotNil(t, sess) {
+		return
+	}
+
+	in, err := Introspect(sess.Token.String())
+	assert.NoError(t, err)
+	assert.True(t, in.Active, "Expecting a fresh token to be active")
+	assert.Equal(t, TokenTypeHailo, in.TokenType)
+	assert.Empty(t, in.SessionId, "Expecting no session ID for a token that doesn't carry one")
+	if assert.NotNil(t, in.Token) {
+		assert.Equal(t, "auther2", in.Token.Id)
+		assert.Equal(t, []string{"DRIVER"}, in.Token.Roles)
+	}
+
+	forged := sess.Token.Copy()
+	forged.Sign([]byte("forged"))
+	in, err = Introspect(forged.String())
+	assert.NoError(t, err)
+	assert.False(t, in.Active, "Expecting a bad signature to be inactive")
+	assert.Nil(t, in.Token)
+
+	expired := sess.Token.Copy()
+	expired.Expires = time.Now().Add(-time.Minute)
+	expired.Sign([]byte("signed"))
+	in, _ = Introspect(expired.String())
+	assert.False(t, in.Active, "Expecting an expired token to be inactive")
+
+	in, _ = Introspect("not.a.jwt")
+	assert.False(t, in.Active)
+	assert.Equal(t, TokenTypeJWT, in.TokenType)
+
+	// revoking the session revokes the token, even though it hasn't expired
+	assert.NoError(t, Expire(sess))
+	in, err = Introspect(sess.Token.String())
+	assert.NoError(t, err)
+	assert.False(t, in.Active, "Expecting a token to be inactive once its session is gone")
+}
+
+func TestIntrospectLoggedOutSessionOnSharedDeviceInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	app := domain.Application("ADMIN")
+	sessions := make([]*domain.Session, 2)
+	for i := range sessions {
+		created := time.Now().Add(time.Duration(i-len(sessions)) * time.Minute)
+		sess := &domain.Session{
+			Id:      fmt.Sprintf("admin%d", i),
+			Created: created,
+			Token: domain.Token{
+				Created:       created,
+				AuthMechanism: app.ToAuthMechanism(),
+				DeviceType:    "web",
+				Id:            "admin",
+				Expires:       created.Add(time.Hour),
+				Roles:         []string{"ADMIN"},
+			},
+		}
+		signed, err := signer.Sign(&sess.Token)
+		if !assert.NoError(t, err) {
+			return
+		}
+		sess.Token = *signed
+		assert.NoError(t, dao.WriteSession(sess))
+		sessions[i] = sess
+	}
+
+	// the second session's token mustn't be vouched for by the first once it has logged out
+	assert.NoError(t, Expire(sessions[1]))
+	in, err := Introspect(sessions[1].Token.String())
+	assert.NoError(t, err)
+	assert.False(t, in.Active, "Expecting a logged out session's token to be inactive")
+
+	in, err = Introspect(sessions[0].Token.String())
+	assert.NoError(t, err)
+	assert.True(t, in.Active, "Expecting the remaining session's token to stay active")
+}
+
+func TestRefreshInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	sess, err := Auth(domain.Application("DRIVER"), "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	if !assert.NoError(t, err) || !assert.NotNil(t, sess) {
+		return
+	}
+	sess, first, err := IssueRefreshToken(sess)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEmpty(t, first)
+	assert.True(t, sess.CanRefresh())
+
+	// a token that has expired can still be refreshed
+	expired := sess.Copy()
+	expired.Token.Expires = time.Now().Add(-time.Minute)
+	expired.Token.AutoRenew = time.Time{}
+	assert.NoError(t, dao.WriteSession(expired))
+
+	refreshed, second, err := Refresh(first)
+	if !assert.NoError(t, err) || !assert.NotNil(t, refreshed) {
+		return
+	}
+	assert.Equal(t, sess.Id, refreshed.Id, "Expecting the same session")
+	assert.NotEqual(t, first, second, "Expecting the refresh token to be rotated")
+	assert.True(t, refreshed.Token.Expires.After(time.Now()))
+	assert.True(t, signer.Verify(&refreshed.Token))
+
+	_, _, err = Refresh("nonsense")
+	assert.Equal(t, ErrorRefreshTokenInvalid, err)
+
+	// replaying the first refresh token revokes the session, so the second is no good either
+	_, _, err = Refresh(first)
+	assert.Equal(t, ErrorRefreshTokenReused, err)
+	read, _ := dao.ReadSession(sess.Id)
+	assert.Nil(t, read, "Expecting the session to have been revoked")
+	_, _, err = Refresh(second)
+	assert.Equal(t, ErrorRefreshTokenInvalid, err)
+}
+
+func TestRefreshRereadsUserInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	app := domain.Application("DRIVER")
+	sess, err := Auth(app, "cli", "auther2", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	if !assert.NoError(t, err) || !assert.NotNil(t, sess) {
+		return
+	}
+	sess, first, err := IssueRefreshToken(sess)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// roles come from the user, not the old token
+	user, _ := dao.ReadUser(app, "auther2")
+	user.RevokeRoles([]string{"DRIVER"})
+	user.GrantRoles([]string{"DRIVER.TRAINEE"})
+	assert.NoError(t, dao.UpdateUser(user))
+	refreshed, second, err := Refresh(first)
+	if assert.NoError(t, err) && assert.NotNil(t, refreshed) {
+		assert.Equal(t, []string{"DRIVER.TRAINEE"}, refreshed.Token.Roles)
+	}
+
+	// and a disabled account can't refresh at all, and loses its session
+	user.Status = "disabled"
+	assert.NoError(t, dao.UpdateUser(user))
+	_, _, err = Refresh(second)
+	assert.Equal(t, ErrorAccountIsDisabled, err)
+	read, _ := dao.ReadSession(sess.Id)
+	assert.Nil(t, read, "Expecting the session to have been revoked")
+}
+
+func TestRefreshIdleSessionInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	app := domain.Application("ADMIN")
+	now := time.Now()
+	sess := &domain.Session{
+		Id:      "idle",
+		Created: now.Add(-2 * time.Hour),
+		Token: domain.Token{
+			Created:       now.Add(-2 * time.Hour),
+			AuthMechanism: app.ToAuthMechanism(),
+			DeviceType:    "web",
+			Id:            "admin",
+			Expires:       now.Add(6 * time.Hour),
+		},
+	}
+	sess, plain, err := IssueRefreshToken(sess)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// unseen for longer than the admin idle timeout, so a refresh token can't revive it
+	_, _, err = Refresh(plain)
+	assert.Equal(t, ErrorRefreshSessionIdle, err)
+	read, _ := dao.ReadSession(sess.Id)
+	assert.Nil(t, read, "Expecting the idle session to have been expired")
+}
+
+func TestEvictSessionsInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	app := domain.Application("ADMIN")
+	max := domain.MaxSessionsPerDeviceType(app)
+	for i := 0; i < max; i++ {
+		created := time.Now().Add(time.Duration(i-max) * time.Minute)
+		assert.NoError(t, dao.WriteSession(&domain.Session{
+			Id:      fmt.Sprintf("admin%d", i),
+			Created: created,
+			Token: domain.Token{
+				Created:       created,
+				AuthMechanism: app.ToAuthMechanism(),
+				DeviceType:    "web",
+				Id:            "admin",
+				Expires:       created.Add(time.Hour),
+			},
+		}))
+	}
+
+	sessions, err := SessionsFor(app.ToAuthMechanism(), "web", "admin")
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, max) {
+		assert.Equal(t, "admin0", sessions[0].Id, "Expecting oldest first")
+	}
+
+	// making room for one more evicts only the oldest
+	assert.NoError(t, evictSessions(app, "web", "admin"))
+	sessions, err = SessionsFor(app.ToAuthMechanism(), "web", "admin")
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, max-1) {
+		assert.Equal(t, "admin1", sessions[0].Id)
+	}
+
+	// other device types are left alone
+	sessions, _ = SessionsFor(app.ToAuthMechanism(), "cli", "admin")
+	assert.Len(t, sessions, 0)
+}
+
+func TestTouchInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	now := time.Now()
+	sess := &domain.Session{
+		Id:      "idle",
+		Created: now.Add(-2 * time.Hour),
+		Token: domain.Token{
+			Created:       now.Add(-2 * time.Hour),
+			AuthMechanism: domain.Application("ADMIN").ToAuthMechanism(),
+			DeviceType:    "web",
+			Id:            "admin",
+			Expires:       now.Add(6 * time.Hour),
+		},
+		LastSeen: now.Add(-10 * time.Minute),
+	}
+	assert.NoError(t, dao.WriteSession(sess))
+
+	// seen recently enough, so the last seen time is written back
+	touched, err := Touch(sess)
+	assert.NoError(t, err)
+	if assert.NotNil(t, touched) {
+		assert.True(t, touched.LastSeen.After(sess.LastSeen))
+	}
+	read, _ := dao.ReadSession(sess.Id)
+	if assert.NotNil(t, read) {
+		assert.Equal(t, touched.LastSeen.Unix(), read.LastSeen.Unix())
+	}
+
+	// but not again within lastSeenInterval
+	again, err := Touch(touched)
+	assert.NoError(t, err)
+	assert.Equal(t, touched, again)
+
+	// an idle session is expired
+	sess.LastSeen = now.Add(-2 * time.Hour)
+	_, err = Touch(sess)
+	assert.Equal(t, ErrorSessionIdle, err)
+	read, _ = dao.ReadSession(sess.Id)
+	assert.Nil(t, read, "Expecting an idle session to have been expired")
+}
+
+func TestAutoRenewMaxLifetimeInMemory(t *testing.T) {
+	defer setupMemory(t)()
+
+	now := time.Now()
+	app := domain.Application("DRIVER")
+	lifetime := domain.MaxSessionLifetime(app)
+	sess := &domain.Session{
+		Id:      "old",
+		Created: now.Add(-lifetime + time.Hour),
+		Token: domain.Token{
+			Created:       now.Add(-7 * time.Hour),
+			AuthMechanism: app.ToAuthMechanism(),
+			DeviceType:    "cli",
+			Id:            "auther2",
+			Expires:       now.Add(time.Hour),
+			AutoRenew:     now.Add(-time.Minute),
+		},
+	}
+	sess.Token.Sign([]byte("signed"))
+
+	// renewing can take the token no further than the end of the session's lifetime, nor renew it again
+	renewed, err := AutoRenew(sess)
+	assert.NoError(t, err)
+	if assert.NotNil(t, renewed) {
+		assert.Equal(t, sess.Created.Unix(), renewed.Created.Unix(), "Expecting the login time to be preserved")
+		assert.Equal(t, sess.LifetimeEnds().Unix(), renewed.Token.Expires.Unix())
+		assert.True(t, renewed.Token.AutoRenew.IsZero())
+	}
+
+	// and once the lifetime is up, there is no renewing at all
+	sess.Created = now.Add(-lifetime - time.Minute)
+	renewed, err = AutoRenew(sess)
+	assert.NoError(t, err)
+	assert.Nil(t, renewed, "Expecting a session past its maximum lifetime not to be renewed")
+}
+
+func TestPasswordResetInMemory(t *testing.T) {
+	defer setupMemory(t)()
+	notifications := NewMemoryNotifier()
+	SetNotifier(notifications)
+	defer SetNotifier(nsqNotifier{})
+	goSendPasswordReset = func(send func()) { send() }
+	defer func() { goSendPasswordReset = func(send func()) { go send() } }()
+
+	app := domain.Application("DRIVER")
+	assert.Equal(t, ErrorPasswordResetDisabled, RequestPasswordReset(domain.Application("ADMIN"), "auther2"))
+
+	// unknown users get the same response, but nothing is sent
+	assert.NoError(t, RequestPasswordReset(app, "nobody@example.com"))
+	assert.Equal(t, "", notifications.LastToken("nobody@example.com"))
+
+	sess, err := Auth(app, "cli", "auther2@example.com", []byte("foobarbaz"), nil, "", "", map[string]string{}, nil)
+	if !assert.NoError(t, err) || !assert.NotNil(t, sess) {
+		return
+	}
+
+	assert.NoError(t, RequestPasswordReset(app, "auther2@example.com"))
+	first := notifications.LastToken("auther2")
+	assert.NoError(t, RequestPasswordReset(app, "auther2@example.com"))
+	second := notifications.LastToken("auther2")
+	if !assert.NotEqual(t, "", first) || !assert.NotEqual(t, first, second) {
+		return
+	}
+
+	// a password the policy rejects doesn't use up the token
+	err = CompletePasswordReset(first, "abc")
+	if assert.Error(t, err) {
+		_, rejected := err.(*PasswordRejectedError)
+		assert.True(t, rejected, "Expecting the new password to be rejected, got %v", err)
+	}
+	assert.NoError(t, CompletePasswordReset(first, "bazbarfoo"))
+
+	// the reset logs the user out everywhere, and only the new password works
+	read, err := dao.ReadSession(sess.Id)
+	assert.NoError(t, err)
+	assert.Nil(t, read, "Expecting the user's sessions to be invalidated")
+	assert.NoError(t, ValidateAuth(app, "auther2", []byte("bazbarfoo")))
+	assert.Error(t, ValidateAuth(app, "auther2", []byte("foobarbaz")))
+
+	// tokens are single use, and any outstanding ones die with the old password
+	assert.Equal(t, ErrorPasswordResetTokenInvalid, CompletePasswordReset(first, "foobarbaz2"))
+	assert.Equal(t, ErrorPasswordResetTokenInvalid, CompletePasswordReset(second, "foobarbaz2"))
+	assert.Equal(t, ErrorPasswordResetTokenInvalid, CompletePasswordReset("garbage", "foobarbaz2"))
+}
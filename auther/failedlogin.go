@@ -0,0 +1,56 @@
+package auther
+
+import (
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/login-service/event"
+)
+
+// logFailedLogin records a failed authentication attempt and publishes it for security monitoring. If we
+// don't know the user's UID the attempt is recorded against the username tried. Errors are only logged,
+// the attempt has failed anyway.
+func logFailedLogin(app domain.Application, deviceType, uid, username, reason string, meta map[string]string) {
+	if uid == "" {
+		uid = username
+	}
+	failed := &domain.FailedLogin{
+		App:           app,
+		Uid:           uid,
+		Username:      username,
+		Failed:        time.Now(),
+		Reason:        reason,
+		AuthMechanism: app.ToAuthMechanism(),
+		DeviceType:    deviceType,
+		Meta:          meta,
+	}
+	if err := dao.WriteFailedLogin(failed); err != nil {
+		log.Errorf("[Auther] Failed to store failed login for '%v': %v", uid, err)
+	}
+
+	e := &event.FailedLoginEvent{
+		Application: string(failed.App),
+		Uid:         failed.Uid,
+		Username:    failed.Username,
+		FailedAt:    failed.Failed.Format(time.RFC3339),
+		Reason:      failed.Reason,
+		Mech:        failed.AuthMechanism,
+		DeviceType:  failed.DeviceType,
+		Meta:        failed.Meta,
+	}
+	e.Publish()
+}
+
+// failedLoginReason classifies an authentication attempt that isFailedLogin
+func failedLoginReason(uid string, err error) string {
+	switch {
+	case uid == "":
+		return domain.FailedLoginUnknownUser
+	case err == ErrorMfaInvalid:
+		return domain.FailedLoginMfaInvalid
+	}
+	return domain.FailedLoginBadCredentials
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,15 +22,14 @@ import (
 	"github.com/HailoOSS/protobuf/proto"
 )
 
-const (
-	tokenTtl         = 8 * time.Hour
-	tokenRenewWindow = 30 * time.Minute
-)
-
 var (
 	ErrorChangePassword    = errors.New("Authentication failed - you must change your password")
 	ErrorAccountIsDisabled = errors.New("Authentication failed - your account is disabled")
 	ErrorAccountIsExpired  = errors.New("Authentication failed - your account is expired")
+	// ErrorMfaRequired is the challenge returned when the password is right but no TOTP code was supplied
+	ErrorMfaRequired          = errors.New("Authentication failed - a TOTP code is required")
+	ErrorMfaInvalid           = errors.New("Authentication failed - invalid TOTP code")
+	ErrorMfaEnrolmentRequired = errors.New("Authentication failed - you must enrol in multi-factor authentication")
 )
 
 // h2autheR is the default implementation
@@ -60,13 +60,13 @@ func (a *h2Auther) ChangePassword(user *domain.User, newPassword string, activeS
 	}
 
 	log.Debugf("[Change password] user sessions found %d", len(sessionIds))
-	for deviceType, sessionId := range sessionIds {
+	for sessionId := range sessionIds {
 		if activeSession != nil && sessionId == activeSession.Id {
 			continue
 		}
 
 		log.Debugf("[Change password:Invalidating] Going to delete session: %v", sessionId)
-		if err = dao.DeleteSession(sessionId, deviceType); err != nil {
+		if err = dao.DeleteSession(sessionId); err != nil {
 			return fmt.Errorf("Failed to expire other sessions: %s", err.Error())
 		}
 
@@ -139,36 +139,51 @@ func (a *h2Auther) sanityCheckUser(user *domain.User, password, newPassword []by
 		}
 	}
 
-	if user.IsDisabled() {
-		return ErrorAccountIsDisabled
-	}
-
-	if user.IsAccountExpired() {
-		return ErrorAccountIsExpired
+	if err := checkAccountStatus(user); err != nil {
+		return err
 	}
 
-	// passwords matches - lets check format
+	// passwords matches - lets check format. H1 hashes are migrated like any other rehash, not as a password
+	// change, since the user already has this password whether or not it would pass today's policy.
 	if user.OldHashFormat() {
-		user.SetPassword(string(password))
-		if err := dao.UpdateUser(user); err != nil {
+		if err := user.Rehash(password); err != nil {
+			log.Errorf("[Auther] Failed to migrate H1 password: %v", err)
+		} else if err := dao.UpdateUser(user); err != nil {
 			log.Errorf("[Auther] Failed to update user with new password format: %v", err)
 			// only log error, as we should still allow login
 		} else {
 			log.Debugf("[Auther] Migrated H1 password to H2 for user '%v'", user.Uid)
 		}
+	} else if user.NeedsRehash() {
+		if err := user.Rehash(password); err != nil {
+			log.Errorf("[Auther] Failed to rehash password: %v", err)
+		} else if err := dao.UpdateUser(user); err != nil {
+			log.Errorf("[Auther] Failed to update user with rehashed password: %v", err)
+			// only log error, as we should still allow login
+		} else {
+			log.Debugf("[Auther] Rehashed password for user '%v'", user.Uid)
+		}
+	}
+
+	return nil
+}
+
+// checkAccountStatus rejects users whose accounts are disabled or expired
+func checkAccountStatus(user *domain.User) error {
+	if user.IsDisabled() {
+		return ErrorAccountIsDisabled
+	}
+
+	if user.IsAccountExpired() {
+		return ErrorAccountIsExpired
 	}
 
 	return nil
 }
 
 func (a *h2Auther) sanityCheckSession(user *domain.User, sess *domain.Session, app domain.Application, deviceType string, meta map[string]string) error {
-	existingSess, err := dao.ReadActiveSessionFor(app.ToAuthMechanism(), deviceType, user.Uid)
-	if err != nil {
-		return fmt.Errorf("Authentication failed - failed checking for existing session: %v", err)
-	} else if existingSess != nil {
-		if err := Expire(existingSess); err != nil {
-			return fmt.Errorf("Authentication failed - failed to release existing session: %v", err)
-		}
+	if err := evictSessions(app, deviceType, user.Uid); err != nil {
+		return fmt.Errorf("Authentication failed - failed to release existing sessions: %v", err)
 	}
 
 	// Persist new session
@@ -179,13 +194,12 @@ func (a *h2Auther) sanityCheckSession(user *domain.User, sess *domain.Session, a
 }
 
 func (a *h2Auther) newUserToken(user *domain.User, app domain.Application, deviceType string, now time.Time) *domain.Token {
-	expires := now.Add(tokenTtl)
+	expires := now.Add(domain.TokenTtl(app))
 
-	// configure for auto-renew, but only if roles allow -- specifically can't
-	// have any admin role or derivative of
+	// configure for auto-renew, but only if the application's policy allows it for the user's roles
 	autoRenew := time.Time{}
-	if !user.AnyAdminRoles() {
-		autoRenew = expires.Add(-tokenRenewWindow)
+	if domain.AutoRenewAllowed(app, user.Roles) {
+		autoRenew = expires.Add(-domain.TokenRenewWindow(app))
 	}
 
 	return &domain.Token{
@@ -203,7 +217,7 @@ func (a *h2Auther) newUserToken(user *domain.User, app domain.Application, devic
 // a single application.
 // Where we cannot auth, but there is no error, we return nil session.
 // We purposefully don't give any indication of why auth failed, unless it's a change password error
-func (a *h2Auther) Auth(app domain.Application, deviceType, username string, password, newPassword []byte, meta map[string]string, session *domain.Session) (*domain.Session, error) {
+func (a *h2Auther) Auth(app domain.Application, deviceType, username string, password, newPassword []byte, totpCode, recoveryCode string, meta map[string]string, session *domain.Session) (*domain.Session, error) {
 	startAuth := time.Now()
 
 	user, err := a.getUser(app, deviceType, username)
@@ -221,6 +235,15 @@ func (a *h2Auther) Auth(app domain.Application, deviceType, username string, pas
 
 	log.Debugf("[Auther] PwdMatch %dms", endPwdMatch.Sub(startPwdMatch)/time.Millisecond)
 
+	// before the second factor, so that a login that's bound to fail doesn't spend a single-use code
+	if err := checkAccountStatus(user); err != nil {
+		return nil, err
+	}
+
+	if err := a.checkMfa(user, totpCode, recoveryCode, meta); err != nil {
+		return nil, err
+	}
+
 	var retSession *domain.Session
 	if session != nil { // Session was passed in? No need to create one
 		retSession = session
@@ -244,6 +267,68 @@ func (a *h2Auther) Auth(app domain.Application, deviceType, username string, pas
 	return retSession, err
 }
 
+// checkMfa enforces the second factor, once the password has matched. A recovery code can stand in for
+// the TOTP code; using one is recorded in the login meta.
+func (a *h2Auther) checkMfa(user *domain.User, totpCode, recoveryCode string, meta map[string]string) error {
+	if !user.MfaEnrolled() {
+		if user.MfaRequired() {
+			return ErrorMfaEnrolmentRequired
+		}
+		return nil
+	}
+
+	if len(recoveryCode) > 0 {
+		used, err := a.useRecoveryCode(user, recoveryCode)
+		if err != nil {
+			return err
+		}
+		if !used {
+			log.Debug("[Auther] Auth -- Recovery code does not match")
+			return ErrorMfaInvalid
+		}
+		log.Infof("[Auther] Recovery code used by user '%v', %d remaining", user.Uid, len(user.RecoveryCodes))
+		meta["recoveryCodeUsed"] = "true"
+		meta["recoveryCodesRemaining"] = strconv.Itoa(len(user.RecoveryCodes))
+		return nil
+	}
+
+	if len(totpCode) == 0 {
+		return ErrorMfaRequired
+	}
+	used, err := a.useMfaCode(user, func(u *domain.User) bool { return u.UseTotpCode(totpCode) })
+	if err != nil {
+		return err
+	}
+	if !used {
+		log.Debug("[Auther] Auth -- TOTP code does not match, or has already been used")
+		return ErrorMfaInvalid
+	}
+	return nil
+}
+
+// useRecoveryCode spends one of the user's recovery codes
+func (a *h2Auther) useRecoveryCode(user *domain.User, code string) (bool, error) {
+	return a.useMfaCode(user, func(u *domain.User) bool { return u.UseRecoveryCode(code) })
+}
+
+// useMfaCode spends a single-use second factor with use, under lock so that each can only be used once
+func (a *h2Auther) useMfaCode(user *domain.User, use func(*domain.User) bool) (bool, error) {
+	current, err := UpdateMfa(user.App, user.Uid, func(u *domain.User) error {
+		if !use(u) {
+			return errMfaCodeUnused
+		}
+		return nil
+	})
+	if err == errMfaCodeUnused || (err == nil && current == nil) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("Authentication failed - cannot spend MFA code: %v", err)
+	}
+
+	user.RecoveryCodes, user.TotpLastStep = current.RecoveryCodes, current.TotpLastStep
+	return true, nil
+}
+
 // AuthAs will retrieve a user token without asking for a password
 func (a *h2Auther) AuthAs(app domain.Application, deviceType, username string, meta map[string]string) (*domain.Session, error) {
 	log.Debugf("app: %+v, deviceType: %+v, username: %+v", app, deviceType, username)
@@ -385,7 +470,7 @@ func (a *h2Auther) OAuth(app domain.Application, deviceType, username, oauthtoke
 
 // Expire will remove all knowledge of a session such that it cannot be used anymore
 func (a *h2Auther) Expire(s *domain.Session) error {
-	if err := dao.DeleteSession(s.Id, s.Token.AuthMechanism); err != nil {
+	if err := dao.DeleteSession(s.Id); err != nil {
 		return fmt.Errorf("Session expire failed: %v", err)
 	}
 	// Broadcast this via NSQ so all regions can expunge from caches, if they want to
@@ -407,14 +492,18 @@ func (a *h2Auther) AutoRenew(s *domain.Session) (*domain.Session, error) {
 		return nil, fmt.Errorf("Failed to verify existing token -- refusing to auto-renew.")
 	}
 
+	// the session has lived as long as it may, so the user must log in again once the token expires
+	now := time.Now()
+	if s.HasOutlived(now) {
+		log.Debugf("[Auther] Not renewing session %v, which reached its maximum lifetime at %v", s.Id, s.LifetimeEnds())
+		return nil, nil
+	}
+
 	// ok - we can auto-renew, let's go do it
 	// what we actually do is mint a "new" token (well we update created timestamp),
 	// extend the expiry/auto-renew timestamps and then sign it and store it
 	renewed := s.Copy()
-
-	renewed.Token.Created = time.Now()
-	renewed.Token.Expires = renewed.Token.Created.Add(tokenTtl)
-	renewed.Token.AutoRenew = renewed.Token.Expires.Add(-tokenRenewWindow)
+	extendToken(renewed, now)
 
 	tSigned, err := signer.Sign(&renewed.Token)
 	if err != nil {
@@ -430,6 +519,23 @@ func (a *h2Auther) AutoRenew(s *domain.Session) (*domain.Session, error) {
 	return renewed, nil
 }
 
+// extendToken updates (but doesn't sign) a session's token to last from now for its application's token TTL, but
+// no longer than the session's lifetime. It can be auto-renewed again if it could before, unless that would take
+// it past the end of the session's lifetime.
+func extendToken(sess *domain.Session, now time.Time) {
+	app := sess.Token.Application()
+	autoRenew := !sess.Token.AutoRenew.IsZero()
+
+	sess.Token.Created = now
+	sess.Token.Expires = now.Add(domain.TokenTtl(app))
+	sess.Token.AutoRenew = time.Time{}
+	if ends := sess.LifetimeEnds(); !ends.IsZero() && ends.Before(sess.Token.Expires) {
+		sess.Token.Expires = ends
+	} else if autoRenew {
+		sess.Token.AutoRenew = sess.Token.Expires.Add(-domain.TokenRenewWindow(app))
+	}
+}
+
 func newSessionId() string {
 	bigi, err := rand.Int(rand.Reader, maxSessRand)
 	if err != nil {
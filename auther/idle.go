@@ -0,0 +1,41 @@
+package auther
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+)
+
+// lastSeenInterval throttles how often reading a session writes its last seen time back
+const lastSeenInterval = time.Minute
+
+var ErrorSessionIdle = errors.New("Session expired - idle for too long")
+
+// Touch records that a session has been seen, expiring it instead (and returning ErrorSessionIdle) if it had
+// already gone idle. The session is only written back if it hasn't been seen for lastSeenInterval, so busy
+// sessions don't cost a write on every read.
+func Touch(sess *domain.Session) (*domain.Session, error) {
+	now := time.Now()
+	if sess.IsIdle(now) {
+		log.Debugf("[Auther] Session %v idle since %v, expiring it", sess.Id, sess.LastSeen)
+		if err := Expire(sess); err != nil {
+			return nil, fmt.Errorf("Failed to expire idle session: %v", err)
+		}
+		return nil, ErrorSessionIdle
+	}
+	if now.Sub(sess.LastSeen) < lastSeenInterval {
+		return sess, nil
+	}
+
+	touched := sess.Copy()
+	touched.LastSeen = now
+	if err := dao.WriteSession(touched); err != nil {
+		return nil, fmt.Errorf("Failed to save session: %v", err)
+	}
+	return touched, nil
+}
@@ -0,0 +1,126 @@
+package auther
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/login-service/signer"
+)
+
+// The formats a token can be presented to Introspect in
+const (
+	TokenTypeHailo = "hailo"
+	TokenTypeJWT   = "jwt"
+)
+
+// Introspection is what we know about a token presented to us, as RFC 7662 has it
+type Introspection struct {
+	// Active is true if the token's signature is good, it hasn't expired and its session hasn't been revoked
+	Active bool
+	// TokenType is the format the token was in
+	TokenType string
+	// Token is only set for active tokens
+	Token *domain.Token
+	// SessionId is only set for active JWTs, echoing the session ID they carry. It's never looked up for
+	// colon-delimited tokens, as a session ID is itself a credential and introspect is open to the world.
+	SessionId string
+}
+
+// Introspect checks a token string in either format, confirming its session still exists so that the
+// caller learns of revocations (logouts, password changes etc.) before the token expires. An inactive
+// token is not an error; the reason is only logged, as RFC 7662 suggests.
+func Introspect(s string) (*Introspection, error) {
+	in := &Introspection{TokenType: TokenTypeHailo}
+	if isJWT(s) {
+		in.TokenType = TokenTypeJWT
+	}
+
+	token, sessId, reason := verifyTokenString(in.TokenType, s)
+	if reason != "" {
+		log.Debugf("[Auther] Introspect -- inactive %v token: %v", in.TokenType, reason)
+		return in, nil
+	}
+	if token.HasExpired() {
+		log.Debugf("[Auther] Introspect -- inactive %v token: expired at %v", in.TokenType, token.Expires)
+		return in, nil
+	}
+
+	sess, err := tokenSession(token, sessId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read session: %v", err)
+	}
+	// a token minted before its session was created belongs to an earlier session for the same device
+	if sess == nil || token.Created.Unix() < sess.Created.Unix() {
+		log.Debugf("[Auther] Introspect -- inactive %v token: no session", in.TokenType)
+		return in, nil
+	}
+	// idle sessions are only expired when next read, which we leave to readsession
+	if sess.IsIdle(time.Now()) {
+		log.Debugf("[Auther] Introspect -- inactive %v token: session idle", in.TokenType)
+		return in, nil
+	}
+
+	in.Active = true
+	in.Token = token
+	in.SessionId = sessId
+	return in, nil
+}
+
+// isJWT tells a JWT (three base64url parts) from a colon-delimited token, which always has colons
+func isJWT(s string) bool {
+	return strings.Count(s, ".") == 2 && !strings.Contains(s, ":")
+}
+
+// verifyTokenString parses and verifies a token string, returning the token and the session ID if the
+// format carries one, or the reason it isn't valid
+func verifyTokenString(tokenType, s string) (*domain.Token, string, string) {
+	if tokenType == TokenTypeJWT {
+		claims, err := signer.VerifyJWT(s)
+		if err != nil {
+			return nil, "", err.Error()
+		}
+		return claims.Token(), claims.SessionId, ""
+	}
+
+	token, err := domain.ParseToken(s)
+	if err != nil {
+		return nil, "", err.Error()
+	}
+	if !signer.Verify(token) {
+		return nil, "", "bad signature"
+	}
+	return token, "", ""
+}
+
+// tokenSession finds the session a token belongs to: by ID if we have it, otherwise whichever of the user's
+// sessions for the token's device type currently holds it. A colon-delimited token doesn't say which session it
+// came from, so one that no session holds (say one replaced by renewal, or whose session was logged out while
+// another remains on the device) is treated as having none, rather than guessing and vouching for it.
+func tokenSession(token *domain.Token, sessId string) (*domain.Session, error) {
+	if sessId == "" {
+		sessions, err := SessionsFor(token.AuthMechanism, token.DeviceType, token.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, sess := range sessions {
+			if sess.Token.String() == token.String() {
+				return sess, nil
+			}
+		}
+		return nil, nil
+	}
+
+	sess, err := dao.ReadSession(sessId)
+	if err != nil || sess == nil {
+		return nil, err
+	}
+	if sess.Token.Id != token.Id || sess.Token.DeviceType != token.DeviceType {
+		return nil, nil
+	}
+	return sess, nil
+}
@@ -38,7 +38,9 @@ func (a *ldapAuther) ValidateAuth(app domain.Application, username string, passw
 	return nil
 }
 
-func (a *ldapAuther) Auth(app domain.Application, deviceType, username string, password, newPassword []byte, meta map[string]string, session *domain.Session) (*domain.Session, error) {
+// Auth authenticates against the LDAP directory; LDAP users have no stored h2 user, so they cannot enrol in
+// TOTP and totpCode and recoveryCode are ignored
+func (a *ldapAuther) Auth(app domain.Application, deviceType, username string, password, newPassword []byte, totpCode, recoveryCode string, meta map[string]string, session *domain.Session) (*domain.Session, error) {
 	username, _, ok := dao.IsLDAPUser(app, username)
 	if !ok {
 		return nil, fmt.Errorf("Username is not a valid LDAP user")
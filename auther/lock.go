@@ -3,13 +3,38 @@ package auther
 import (
 	"fmt"
 
+	"github.com/HailoOSS/login-service/domain"
 	"github.com/HailoOSS/service/sync"
 )
 
 const (
-	lockPath = "%s/%s-%s"
+	lockPath              = "%s/%s-%s"
+	mfaLockPath           = "mfa/%s/%s"
+	lockoutLockPath       = "lockout/%s/%s"
+	refreshTokenLockPath  = "refreshtoken/%s"
+	passwordResetLockPath = "passwordreset/%s"
 )
 
+// regionLock is how we lock; swapped out in tests so we don't need ZooKeeper
+var regionLock = sync.RegionLock
+
 func lockDeviceUser(authMech, deviceType, userId string) (sync.Lock, error) {
-	return sync.RegionLock([]byte(fmt.Sprintf(lockPath, authMech, deviceType, userId)))
+	return regionLock([]byte(fmt.Sprintf(lockPath, authMech, deviceType, userId)))
+}
+
+// lockMfa guards a user's single-use second factors: their recovery codes and last TOTP step
+func lockMfa(app domain.Application, userId string) (sync.Lock, error) {
+	return regionLock([]byte(fmt.Sprintf(mfaLockPath, app, userId)))
+}
+
+func lockLockout(app domain.Application, userId string) (sync.Lock, error) {
+	return regionLock([]byte(fmt.Sprintf(lockoutLockPath, app, userId)))
+}
+
+func lockRefreshToken(hash string) (sync.Lock, error) {
+	return regionLock([]byte(fmt.Sprintf(refreshTokenLockPath, hash)))
+}
+
+func lockPasswordResetToken(hash string) (sync.Lock, error) {
+	return regionLock([]byte(fmt.Sprintf(passwordResetLockPath, hash)))
 }
@@ -0,0 +1,110 @@
+package auther
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	platformerrors "github.com/HailoOSS/platform/errors"
+)
+
+var ErrorAccountIsLocked = errors.New("Authentication failed - too many failed attempts, your account is locked")
+
+// withLockout wraps an authentication attempt, refusing it outright if the user is locked out, counting it
+// if it fails and clearing any count if it succeeds. Every failure is recorded via logFailedLogin.
+func withLockout(app domain.Application, deviceType, username string, meta map[string]string, auth func() (*domain.Session, error)) (*domain.Session, error) {
+	uid, err := lockoutUid(app, username)
+	if err != nil {
+		return nil, fmt.Errorf("Authentication failed - DAO error: %v", err)
+	} else if uid == "" {
+		sess, err := auth()
+		if isFailedLogin(sess, err) {
+			logFailedLogin(app, deviceType, uid, username, failedLoginReason(uid, err), meta)
+		}
+		return sess, err
+	}
+
+	lockout, err := dao.ReadLockout(app, uid)
+	if err != nil {
+		return nil, fmt.Errorf("Authentication failed - failed checking for lockout: %v", err)
+	}
+	if lockout != nil && lockout.IsLocked(time.Now()) {
+		log.Debugf("[Auther] Auth -- User '%v' is locked out until %v", uid, lockout.LockedUntil)
+		logFailedLogin(app, deviceType, uid, username, domain.FailedLoginLocked, meta)
+		return nil, ErrorAccountIsLocked
+	}
+
+	sess, err := auth()
+	if isFailedLogin(sess, err) {
+		logFailedLogin(app, deviceType, uid, username, failedLoginReason(uid, err), meta)
+		if lerr := recordFailedLogin(app, uid); lerr != nil {
+			// only log error, the login has failed anyway
+			log.Errorf("[Auther] Failed to record failed login for '%v': %v", uid, lerr)
+		}
+	} else if sess != nil && lockout != nil {
+		if lerr := dao.DeleteLockout(app, uid); lerr != nil {
+			log.Errorf("[Auther] Failed to clear failed logins for '%v': %v", uid, lerr)
+		}
+	}
+
+	return sess, err
+}
+
+// lockoutUid resolves the UID that failed logins are counted against, so that trying a user's various IDs
+// all counts the same. LDAP users have no stored user, so we use their username. Returns "" if there is no
+// such user.
+func lockoutUid(app domain.Application, username string) (string, error) {
+	if _, _, ok := dao.IsLDAPUser(app, username); ok {
+		return username, nil
+	}
+	user, err := dao.ReadUser(app, username)
+	if err != nil || user == nil {
+		return "", err
+	}
+	return user.Uid, nil
+}
+
+// isFailedLogin tests whether an authentication attempt failed because of the credentials supplied, rather
+// than for some other reason (eg: a DAO error, or needing a TOTP code)
+func isFailedLogin(sess *domain.Session, err error) bool {
+	if err == nil {
+		return sess == nil
+	}
+	if err == ErrorMfaInvalid {
+		return true
+	}
+	// LDAP rejecting the credentials comes back as a 4xx
+	if perr, ok := err.(platformerrors.Error); ok {
+		return perr.HttpCode() >= 400 && perr.HttpCode() < 500
+	}
+	return false
+}
+
+// recordFailedLogin counts a failed login, under lock so concurrent failures all count
+func recordFailedLogin(app domain.Application, uid string) error {
+	lck, err := lockLockout(app, uid)
+	if err != nil {
+		return fmt.Errorf("Failed to lock: %v", err)
+	}
+	defer lck.Unlock()
+
+	lockout, err := dao.ReadLockout(app, uid)
+	if err != nil {
+		return err
+	}
+	if lockout == nil {
+		lockout = &domain.Lockout{App: app, Uid: uid}
+	}
+	if lockout.Fail(time.Now()) {
+		log.Warnf("[Auther] Locked out user '%v' after %d failed logins, until %v", uid, lockout.Failures, lockout.LockedUntil)
+	}
+	if lockout.Failures == 0 {
+		// the policy has lockout turned off
+		return nil
+	}
+	return dao.WriteLockout(lockout)
+}
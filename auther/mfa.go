@@ -0,0 +1,38 @@
+package auther
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+)
+
+// errMfaCodeUnused tells useMfaCode that the code wasn't good, so there is nothing to write
+var errMfaCodeUnused = errors.New("MFA code not used")
+
+// UpdateMfa changes a user's second factors under lock: it re-reads the user, passes them to update and writes
+// them back, so that codes are only ever spent once and changes to enrolment can't be overwritten by a stale copy
+// of the user (or overwrite one). Nothing is written if update returns an error, which is returned as it is. A
+// user that no longer exists is returned as nil, without calling update.
+func UpdateMfa(app domain.Application, userId string, update func(*domain.User) error) (*domain.User, error) {
+	lck, err := lockMfa(app, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to lock MFA: %v", err)
+	}
+	defer lck.Unlock()
+
+	user, err := dao.ReadUser(app, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DAO error: %v", err)
+	} else if user == nil {
+		return nil, nil
+	}
+	if err := update(user); err != nil {
+		return nil, err
+	}
+	if err := dao.UpdateUser(user); err != nil {
+		return nil, fmt.Errorf("Failed to update MFA: %v", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,68 @@
+package auther
+
+import (
+	"sync"
+	"time"
+
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/login-service/event"
+)
+
+// Notifier delivers password reset tokens to users, out of band
+type Notifier interface {
+	NotifyPasswordReset(user *domain.User, token string, expires time.Time) error
+}
+
+// notifier is how we deliver reset tokens; by default we publish them to NSQ for another service to send
+var notifier Notifier = nsqNotifier{}
+
+// SetNotifier switches how password reset tokens are delivered
+func SetNotifier(n Notifier) {
+	notifier = n
+}
+
+// nsqNotifier publishes reset tokens as PasswordResetEvents
+type nsqNotifier struct{}
+
+func (nsqNotifier) NotifyPasswordReset(user *domain.User, token string, expires time.Time) error {
+	ids := make([]string, len(user.Ids))
+	for i, id := range user.Ids {
+		ids[i] = string(id)
+	}
+	e := &event.PasswordResetEvent{
+		Application: string(user.App),
+		Uid:         user.Uid,
+		Ids:         ids,
+		Token:       token,
+		Expires:     expires.Format(time.RFC3339),
+	}
+	return e.Publish()
+}
+
+// MemoryNotifier keeps the last reset token sent to each user instead of delivering it, as a stand-in for
+// tests and local development
+type MemoryNotifier struct {
+	sync.RWMutex
+	tokens map[string]string
+}
+
+// NewMemoryNotifier mints an empty MemoryNotifier
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{
+		tokens: make(map[string]string),
+	}
+}
+
+func (n *MemoryNotifier) NotifyPasswordReset(user *domain.User, token string, expires time.Time) error {
+	n.Lock()
+	defer n.Unlock()
+	n.tokens[user.Uid] = token
+	return nil
+}
+
+// LastToken returns the last reset token sent to a user, or "" if none has been
+func (n *MemoryNotifier) LastToken(uid string) string {
+	n.RLock()
+	defer n.RUnlock()
+	return n.tokens[uid]
+}
@@ -0,0 +1,144 @@
+package auther
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/go-hailo-lib/multierror"
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+)
+
+const (
+	// passwordResetTokenSizeInBytes is how much randomness goes into a reset token
+	passwordResetTokenSizeInBytes = 32
+	passwordResetRateLimitKey     = "passwordreset/%s/%s"
+)
+
+var (
+	ErrorPasswordResetDisabled     = errors.New("Self-service password reset is not available for this application")
+	ErrorPasswordResetTokenInvalid = errors.New("Password reset failed - invalid, expired or already used reset token")
+)
+
+// goSendPasswordReset is how a reset token is saved and sent, after we've answered the request so that how long
+// we take doesn't give away whether there was anyone to send it to (it's a var only so tests can wait for it)
+var goSendPasswordReset = func(send func()) { go send() }
+
+// PasswordRejectedError is returned when a new password fails the application's policy
+type PasswordRejectedError struct {
+	Errs *multierror.MultiError
+}
+
+func (e *PasswordRejectedError) Error() string {
+	return fmt.Sprintf("Password reset failed - invalid new password: %v", e.Errs.Error())
+}
+
+// RequestPasswordReset sends a user a single-use token they can exchange for a new password. Unless the
+// application doesn't allow self-service resets, or the request is rate limited, it returns nil whether or
+// not we sent anything, so that it can't be used to find out who has an account; the token is sent in the
+// background for the same reason, so failing to send it is only logged. Users that can't log in, and those
+// with admin roles, must go through an admin instead.
+func RequestPasswordReset(app domain.Application, username string) error {
+	ttl := domain.PasswordResetTtl(app)
+	if ttl <= 0 {
+		return ErrorPasswordResetDisabled
+	}
+	if !takeToken(fmt.Sprintf(passwordResetRateLimitKey, app, strings.ToLower(username)), domain.UsernameRateLimit(app)) {
+		log.Infof("[Auther] Rate limited password reset for username '%v' for %v", username, app)
+		return ErrorRateLimited
+	}
+
+	user, err := dao.ReadUser(app, username)
+	if err != nil {
+		return fmt.Errorf("Password reset failed - DAO error: %v", err)
+	}
+	if user == nil || user.IsDisabled() || user.IsAccountExpired() || user.AnyAdminRoles() {
+		log.Debugf("[Auther] Not sending password reset for username '%v' for %v", username, app)
+		return nil
+	}
+
+	goSendPasswordReset(func() {
+		if err := sendPasswordReset(user, ttl); err != nil {
+			log.Errorf("[Auther] Failed to send password reset to user '%v': %v", user.Uid, err)
+		}
+	})
+	return nil
+}
+
+// sendPasswordReset mints a reset token for a user, saves its hash and sends it to them
+func sendPasswordReset(user *domain.User, ttl time.Duration) error {
+	b := make([]byte, passwordResetTokenSizeInBytes)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("Failed to generate password reset token: %v", err)
+	}
+	plain := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	prt := &domain.PasswordResetToken{
+		Hash:            domain.HashPasswordResetToken(plain),
+		App:             user.App,
+		Uid:             user.Uid,
+		Created:         now,
+		Expires:         now.Add(ttl),
+		PasswordChanged: user.PasswordChange,
+	}
+	if err := dao.WritePasswordResetToken(prt); err != nil {
+		return fmt.Errorf("Failed to save password reset token: %v", err)
+	}
+	if err := notifier.NotifyPasswordReset(user, plain, prt.Expires); err != nil {
+		return fmt.Errorf("Failed to send password reset token: %v", err)
+	}
+
+	log.Debugf("[Auther] Sent password reset token to user '%v', expiring at %v", user.Uid, prt.Expires)
+	return nil
+}
+
+// CompletePasswordReset exchanges a reset token for a new password, which must pass the application's policy
+// (a rejected password doesn't use up the token). Like ChangePassword, it invalidates all of the user's
+// sessions. Each token can only be used once, and stops working as soon as the password changes by any means.
+func CompletePasswordReset(plain, newPassword string) error {
+	hash := domain.HashPasswordResetToken(plain)
+	lck, err := lockPasswordResetToken(hash)
+	if err != nil {
+		return fmt.Errorf("Password reset failed - failed to lock reset token: %v", err)
+	}
+	defer lck.Unlock()
+
+	prt, err := dao.ReadPasswordResetToken(hash)
+	if err != nil {
+		return fmt.Errorf("Password reset failed - DAO error: %v", err)
+	} else if prt == nil {
+		return ErrorPasswordResetTokenInvalid
+	}
+	user, err := dao.ReadUser(prt.App, prt.Uid)
+	if err != nil {
+		return fmt.Errorf("Password reset failed - DAO error: %v", err)
+	}
+	now := time.Now()
+	if !prt.IsValidFor(user, now) || user.IsDisabled() || user.IsAccountExpired() {
+		return ErrorPasswordResetTokenInvalid
+	}
+
+	if errs := domain.TestPolicy(newPassword, user); errs.AnyErrors() {
+		return &PasswordRejectedError{Errs: errs}
+	}
+
+	// the token is only used up once the password has changed, which invalidates it anyway, so failing to mark it
+	// isn't worth failing the reset over; the lock stops it being used twice meanwhile
+	if err := ChangePassword(user, newPassword, nil); err != nil {
+		return err
+	}
+	prt.Used = now
+	if err := dao.WritePasswordResetToken(prt); err != nil {
+		log.Errorf("[Auther] Failed to mark password reset token used for user '%v': %v", user.Uid, err)
+	}
+
+	log.Infof("[Auther] Reset password for user '%v'", user.Uid)
+	return nil
+}
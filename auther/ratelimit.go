@@ -0,0 +1,90 @@
+package auther
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/service/config"
+)
+
+const (
+	ipRateLimitKey       = "ip/%s/%s"
+	usernameRateLimitKey = "username/%s/%s"
+	// rateLimitedLogKey is a bucket per rate limit bucket, which we take from to decide whether to record a
+	// rate limited attempt
+	rateLimitedLogKey = "logged/%s"
+)
+
+var ErrorRateLimited = errors.New("Authentication failed - too many attempts, try again later")
+
+// checkRateLimits takes a token from both the caller IP's and the username's bucket (if the application
+// limits them), recording a failed login if either is empty. If the store fails we let the attempt through,
+// rather than stop everyone logging in. Each bucket is its own ZK lock and C* read and write, so this is two
+// or three region locks per auth on an endpoint open to the world.
+func checkRateLimits(app domain.Application, deviceType, username string, meta map[string]string) error {
+	if ip := meta[ipMetaKey()]; len(ip) > 0 {
+		key, limit := fmt.Sprintf(ipRateLimitKey, app, ip), domain.IpRateLimit(app)
+		if !takeToken(key, limit) {
+			log.Infof("[Auther] Rate limited auth from IP %v for %v", ip, app)
+			logRateLimited(key, limit, app, deviceType, username, meta)
+			return ErrorRateLimited
+		}
+	}
+	if len(username) > 0 {
+		key, limit := fmt.Sprintf(usernameRateLimitKey, app, strings.ToLower(username)), domain.UsernameRateLimit(app)
+		if !takeToken(key, limit) {
+			log.Infof("[Auther] Rate limited auth for username '%v' for %v", username, app)
+			logRateLimited(key, limit, app, deviceType, username, meta)
+			return ErrorRateLimited
+		}
+	}
+	return nil
+}
+
+// logRateLimited records a rate limited attempt as a failed login against the user's UID, but only the first
+// for a bucket in the time the bucket takes to refill, so that a flood of throttled attempts isn't also a
+// flood of writes and events
+func logRateLimited(key string, limit domain.RateLimit, app domain.Application, deviceType, username string, meta map[string]string) {
+	window := domain.RateLimit{Burst: 1, Interval: time.Duration(limit.Burst) * limit.Interval}
+	if ok, err := dao.TakeToken(fmt.Sprintf(rateLimitedLogKey, key), window); err != nil {
+		log.Errorf("[Auther] Failed to check whether to record rate limited auth for %v: %v", key, err)
+		return
+	} else if !ok {
+		return
+	}
+
+	uid := ""
+	if len(username) > 0 {
+		var err error
+		if uid, err = lockoutUid(app, username); err != nil {
+			// only log error, we can still record it against the username
+			log.Errorf("[Auther] Failed to look up rate limited user '%v': %v", username, err)
+		}
+	}
+	logFailedLogin(app, deviceType, uid, username, domain.FailedLoginRateLimited, meta)
+}
+
+// takeToken takes a token from a bucket, reporting whether there was one. A zero limit means unlimited, and a
+// store error lets the attempt through.
+func takeToken(key string, limit domain.RateLimit) bool {
+	if limit.Burst <= 0 {
+		return true
+	}
+	ok, err := dao.TakeToken(key, limit)
+	if err != nil {
+		log.Errorf("[Auther] Failed to check rate limit %v: %v", key, err)
+		return true
+	}
+	return ok
+}
+
+// ipMetaKey is the auth request meta key the caller's IP address is passed in
+func ipMetaKey() string {
+	return config.AtPath("hailo", "service", "login", "rateLimit", "ipMetaKey").AsString("ip")
+}
@@ -0,0 +1,164 @@
+package auther
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/login-service/signer"
+)
+
+// refreshTokenSizeInBytes is how much randomness goes into a refresh token
+const refreshTokenSizeInBytes = 32
+
+var (
+	ErrorRefreshDisabled     = errors.New("Refresh tokens are not issued for this application")
+	ErrorRefreshTokenInvalid = errors.New("Refresh failed - invalid or expired refresh token")
+	ErrorRefreshTokenReused  = errors.New("Refresh failed - refresh token has already been used, session revoked")
+	ErrorRefreshSessionEnded = errors.New("Refresh failed - session has reached its maximum lifetime, you must log in again")
+	ErrorRefreshSessionIdle  = errors.New("Refresh failed - session has been idle for too long, you must log in again")
+)
+
+// IssueRefreshToken mints a refresh token for a session, returning the session (updated to live as long as
+// the refresh token) and the refresh token to give to the client
+func IssueRefreshToken(sess *domain.Session) (*domain.Session, string, error) {
+	ttl := domain.RefreshTokenTtl(sess.Token.Application())
+	if ttl <= 0 {
+		return nil, "", ErrorRefreshDisabled
+	}
+	updated := sess.Copy()
+	plain, err := writeRefreshToken(updated, ttl)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := dao.WriteSession(updated); err != nil {
+		return nil, "", fmt.Errorf("Failed to save session: %v", err)
+	}
+	return updated, plain, nil
+}
+
+// Refresh exchanges a refresh token for a new token for its session, and a new refresh token; each refresh
+// token can only be used once. Using one a second time means it has been stolen (either the thief or the
+// client has used it already), so we revoke the session, and with it every refresh token descended from
+// the same login. The user is re-read, so a disabled, expired or deleted account can't be refreshed and the
+// new token has the user's current roles.
+func Refresh(plain string) (*domain.Session, string, error) {
+	hash := domain.HashRefreshToken(plain)
+	lck, err := lockRefreshToken(hash)
+	if err != nil {
+		return nil, "", fmt.Errorf("Refresh failed - failed to lock refresh token: %v", err)
+	}
+	defer lck.Unlock()
+
+	rt, err := dao.ReadRefreshToken(hash)
+	if err != nil {
+		return nil, "", fmt.Errorf("Refresh failed - DAO error: %v", err)
+	}
+	now := time.Now()
+	if rt == nil || rt.HasExpired(now) {
+		return nil, "", ErrorRefreshTokenInvalid
+	}
+	sess, err := dao.ReadSession(rt.SessionId)
+	if err != nil {
+		return nil, "", fmt.Errorf("Refresh failed - DAO error: %v", err)
+	} else if sess == nil {
+		// logged out, or replaced by a new login
+		return nil, "", ErrorRefreshTokenInvalid
+	}
+
+	if rt.IsUsed() {
+		log.Warnf("[Auther] Refresh token for session %v reused (first used at %v), revoking the session", sess.Id, rt.Used)
+		if err := Expire(sess); err != nil {
+			return nil, "", fmt.Errorf("Refresh failed - failed to revoke session: %v", err)
+		}
+		return nil, "", ErrorRefreshTokenReused
+	}
+	if sess.HasOutlived(now) {
+		return nil, "", expireRefused(sess, ErrorRefreshSessionEnded)
+	}
+	// checked before extending the token, which would count as seeing the session
+	if sess.IsIdle(now) {
+		return nil, "", expireRefused(sess, ErrorRefreshSessionIdle)
+	}
+
+	app := sess.Token.Application()
+	user, err := dao.ReadUser(app, sess.Token.Id)
+	if err != nil {
+		return nil, "", fmt.Errorf("Refresh failed - DAO error: %v", err)
+	}
+	if user == nil {
+		return nil, "", expireRefused(sess, ErrorRefreshTokenInvalid)
+	}
+	if err := checkAccountStatus(user); err != nil {
+		return nil, "", expireRefused(sess, err)
+	}
+
+	rt.Used = now
+	if err := dao.WriteRefreshToken(rt); err != nil {
+		return nil, "", fmt.Errorf("Refresh failed - failed to save refresh token: %v", err)
+	}
+
+	refreshed := sess.Copy()
+	refreshed.Token.Roles = user.Roles
+	if !domain.AutoRenewAllowed(app, user.Roles) {
+		refreshed.Token.AutoRenew = time.Time{}
+	}
+	extendToken(refreshed, now)
+	signed, err := signer.Sign(&refreshed.Token)
+	if err != nil {
+		return nil, "", fmt.Errorf("Refresh failed - failed to sign new token: %v", err)
+	}
+	refreshed.Token = *signed
+
+	newPlain, err := writeRefreshToken(refreshed, domain.RefreshTokenTtl(app))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := dao.WriteSession(refreshed); err != nil {
+		return nil, "", fmt.Errorf("Refresh failed - failed to save session: %v", err)
+	}
+
+	log.Debugf("[Auther] Refreshed session %v to expire at %v", refreshed.Id, refreshed.Token.Expires)
+	return refreshed, newPlain, nil
+}
+
+// expireRefused ends a session that can no longer be refreshed, returning why
+func expireRefused(sess *domain.Session, reason error) error {
+	log.Infof("[Auther] Refusing to refresh session %v, revoking it: %v", sess.Id, reason)
+	if err := Expire(sess); err != nil {
+		return fmt.Errorf("Refresh failed - failed to revoke session: %v", err)
+	}
+	return reason
+}
+
+// writeRefreshToken stores a new refresh token for the session, which it updates (but doesn't save) to live
+// at least as long; the refresh token expires early if the session's lifetime ends first
+func writeRefreshToken(sess *domain.Session, ttl time.Duration) (string, error) {
+	b := make([]byte, refreshTokenSizeInBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("Failed to generate refresh token: %v", err)
+	}
+	plain := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	rt := &domain.RefreshToken{
+		Hash:      domain.HashRefreshToken(plain),
+		SessionId: sess.Id,
+		Created:   now,
+		Expires:   now.Add(ttl),
+	}
+	if ends := sess.LifetimeEnds(); !ends.IsZero() && ends.Before(rt.Expires) {
+		rt.Expires = ends
+	}
+	if err := dao.WriteRefreshToken(rt); err != nil {
+		return "", fmt.Errorf("Failed to save refresh token: %v", err)
+	}
+	sess.RefreshExpires = rt.Expires
+	return plain, nil
+}
@@ -0,0 +1,72 @@
+package auther
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+)
+
+// SessionsFor returns every session a user has for an auth mechanism and device type, oldest first
+func SessionsFor(authMechanism, deviceType, userId string) ([]*domain.Session, error) {
+	sessionIds, err := dao.ReadActiveSessionIdsFor(userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get active sessions: %v", err)
+	}
+
+	// the secondary index has the most recent session even if it never made it into userSessions
+	latest, err := dao.ReadActiveSessionFor(authMechanism, deviceType, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read latest session: %v", err)
+	}
+	sessions := make([]*domain.Session, 0, len(sessionIds)+1)
+	if latest != nil {
+		sessions = append(sessions, latest)
+	}
+
+	for sessId, dt := range sessionIds {
+		if dt != deviceType || (latest != nil && sessId == latest.Id) {
+			continue
+		}
+		sess, err := dao.ReadSession(sessId)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read session %v: %v", sessId, err)
+		}
+		// userSessions can point at sessions that are dead but not yet swept
+		if sess == nil || sess.Token.AuthMechanism != authMechanism {
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+
+	sort.Sort(sessionsByCreated(sessions))
+	return sessions, nil
+}
+
+// evictSessions expires a user's oldest sessions for the application and device type until there is room
+// for one more under the application's policy
+func evictSessions(app domain.Application, deviceType, userId string) error {
+	sessions, err := SessionsFor(app.ToAuthMechanism(), deviceType, userId)
+	if err != nil {
+		return err
+	}
+	for max := domain.MaxSessionsPerDeviceType(app); len(sessions) >= max; sessions = sessions[1:] {
+		if err := Expire(sessions[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionsByCreated sorts sessions oldest first
+type sessionsByCreated []*domain.Session
+
+func (l sessionsByCreated) Len() int      { return len(l) }
+func (l sessionsByCreated) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l sessionsByCreated) Less(i, j int) bool {
+	if !l[i].Created.Equal(l[j].Created) {
+		return l[i].Created.Before(l[j].Created)
+	}
+	return l[i].Id < l[j].Id
+}
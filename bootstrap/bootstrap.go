@@ -1,6 +1,9 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
 	"fmt"
 	"os"
 	"time"
@@ -13,12 +16,58 @@ import (
 	"github.com/HailoOSS/service/zookeeper"
 )
 
-const (
-	username = "admin"
-	password = "Password1"
-)
+const username = "admin"
+
+const generateAttempts = 100
+
+var password = flag.String("password", "", "Password for the admin user; a random one is generated if not given")
+
+// generatePassword makes a random password that the user's policy accepts. Random base64 quite often lacks a
+// digit or a case, so we draw again until one passes.
+func generatePassword(u *domain.User) (string, error) {
+	b := make([]byte, 12)
+	for i := 0; i < generateAttempts; i++ {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		p := base64.RawURLEncoding.EncodeToString(b)
+		if !domain.TestPolicy(p, u).AnyErrors() {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("none of %d random passwords met the %v password policy", generateAttempts, u.App)
+}
 
 func main() {
+	flag.Parse()
+
+	// as the service does, so the admin's hash is peppered and their password checked against the blocklist
+	if err := domain.LoadPeppers(); err != nil {
+		fmt.Println("Error loading password peppers: ", err)
+		os.Exit(1)
+	}
+	if err := domain.LoadPasswordBlocklist(); err != nil {
+		fmt.Println("Error loading password blocklist: ", err)
+		os.Exit(1)
+	}
+
+	u := &domain.User{
+		App:     domain.Application("ADMIN"),
+		Uid:     username,
+		Ids:     []domain.Id{},
+		Created: time.Now(),
+		Roles:   []string{"ADMIN"},
+	}
+	generated := *password == ""
+	if generated {
+		p, err := generatePassword(u)
+		if err != nil {
+			fmt.Println("Error generating password: ", err)
+			os.Exit(1)
+		}
+		*password = p
+	}
+
 	sync.SetRegionLockNamespace("com.HailoOSS.service.login")
 
 	fmt.Println("Loading config...")
@@ -39,22 +88,20 @@ func main() {
 	}
 	fmt.Println("Loaded and connected to ZK.")
 
-	u := &domain.User{
-		App:     domain.Application("ADMIN"),
-		Uid:     username,
-		Ids:     []domain.Id{},
-		Created: time.Now(),
-		Roles:   []string{"ADMIN"},
-	}
-	if err := u.SetPassword(password); err != nil {
+	if err := u.SetPassword(*password); err != nil {
 		fmt.Println("Error setting password: ", err)
 		os.Exit(1)
 	}
 
-	if err := dao.CreateUser(u, password); err != nil {
+	if err := dao.CreateUser(u, *password); err != nil {
 		fmt.Println("Error creating user: ", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Created user ", username, " ", password)
+	// an operator who chose the password already knows it, and it shouldn't end up in their terminal's scrollback
+	if generated {
+		fmt.Println("Created user ", username, " ", *password)
+	} else {
+		fmt.Println("Created user ", username)
+	}
 }
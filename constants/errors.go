@@ -2,6 +2,8 @@ package constants
 
 const (
 	BadCredentialsErrCode    = "com.HailoOSS.service.login.auth.badCredentials"
+	AccountLockedErrCode     = "com.HailoOSS.service.login.auth.locked"
+	RateLimitedErrCode       = "com.HailoOSS.service.login.auth.ratelimited"
 	OauthUserNotFoundErrCode = "com.HailoOSS.service.login.oauth.user"
 	OauthUnknownErrCode      = "com.HailoOSS.service.login.oauth.error"
 )
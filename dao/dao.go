@@ -19,6 +19,11 @@ const (
 	cfUserIndex      = "usersIndex"
 	cfUserIndexIndex = "usersIndexIndex"
 	cfUserSessions   = "userSessions"
+	cfCheckpoints    = "checkpoints"
+	cfLockouts       = "lockouts"
+	cfRateLimits     = "rateLimits"
+	cfRefreshTokens  = "refreshTokens"
+	cfPasswordResets = "passwordResetTokens"
 
 	defaultType = gossie.UTF8Type
 	separator   = "§"
@@ -29,9 +34,16 @@ var (
 	userMapping    gossie.Mapping
 	userTs         *timeseries.TimeSeries
 
-	Cfs []string = []string{cfSessions, cfUsers, cfEndpointAuths, cfUserIndex, cfUserIndexIndex}
+	Cfs []string = []string{cfSessions, cfUsers, cfEndpointAuths, cfUserIndex, cfUserIndexIndex, cfCheckpoints, cfLockouts, cfRateLimits, cfRefreshTokens, cfPasswordResets}
 )
 
+// cassandraStore is the default Store, backed by Cassandra via gossie
+type cassandraStore struct{}
+
+func newCassandraStore() *cassandraStore {
+	return &cassandraStore{}
+}
+
 func init() {
 	var err error
 	sessionMapping, err = gossie.NewMapping(&storedSession{})
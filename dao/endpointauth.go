@@ -22,7 +22,7 @@ const (
 
 // ReadEndpointAuth grabs a list of all authorised services that can make
 // requests to the supplied service
-func ReadEndpointAuth(service string) ([]*domain.EndpointAuth, error) {
+func (s *cassandraStore) ReadEndpointAuth(service string) ([]*domain.EndpointAuth, error) {
 	pool, err := cassandra.ConnectionPool(Keyspace)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get connection pool: %v", err)
@@ -47,7 +47,7 @@ func ReadEndpointAuth(service string) ([]*domain.EndpointAuth, error) {
 }
 
 // WriteEndpointAuths defines a new rule that allows some service to call some endpoint
-func WriteEndpointAuths(epas []*domain.EndpointAuth) error {
+func (s *cassandraStore) WriteEndpointAuths(epas []*domain.EndpointAuth) error {
 	if len(epas) == 0 {
 		return fmt.Errorf("No rules to write")
 	}
@@ -69,7 +69,7 @@ func WriteEndpointAuths(epas []*domain.EndpointAuth) error {
 }
 
 // DeleteEndpointAuths will revoke these rules for allowing things to talk to each other
-func DeleteEndpointAuths(epas []*domain.EndpointAuth) error {
+func (s *cassandraStore) DeleteEndpointAuths(epas []*domain.EndpointAuth) error {
 	if len(epas) == 0 {
 		return fmt.Errorf("No rules to delete")
 	}
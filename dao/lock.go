@@ -8,7 +8,8 @@ import (
 )
 
 const (
-	lockPath = "%s/%s"
+	lockPath          = "%s/%s"
+	rateLimitLockPath = "ratelimit/%s"
 )
 
 type multiLock []sync.Lock
@@ -47,3 +48,7 @@ func lockUser(user *domain.User) (multiLock, error) {
 func lockUserId(namespace string, id string) (sync.Lock, error) {
 	return sync.RegionLock([]byte(fmt.Sprintf(lockPath, namespace, id)))
 }
+
+func lockRateLimit(key string) (sync.Lock, error) {
+	return sync.RegionLock([]byte(fmt.Sprintf(rateLimitLockPath, key)))
+}
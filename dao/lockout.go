@@ -0,0 +1,89 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/HailoOSS/gossie/src/gossie"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/service/cassandra"
+)
+
+/*
+ CF structure:
+  ROW KEY      COL        VALUE
+ [app§uid]    [lockout]  JSON
+
+ Rows are written with a TTL so they disappear once they no longer count towards a lockout
+*/
+
+const lockoutColumn = "lockout"
+
+// ReadLockout fetches a user's recent failed logins
+func (s *cassandraStore) ReadLockout(app domain.Application, uid string) (*domain.Lockout, error) {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	row, err := pool.Reader().Cf(cfLockouts).Columns([][]byte{[]byte(lockoutColumn)}).Get(userIdToRowKey(app, uid))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from C*: %v", err)
+	}
+	if row == nil || len(row.Columns) == 0 {
+		return nil, nil
+	}
+
+	lockout := &domain.Lockout{}
+	if err := json.Unmarshal(row.Columns[0].Value, lockout); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal lockout: %v", err)
+	}
+	return liveLockout(lockout), nil
+}
+
+// WriteLockout stores a user's recent failed logins, with a TTL of when they expire
+func (s *cassandraStore) WriteLockout(lockout *domain.Lockout) error {
+	data, err := json.Marshal(lockout)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal lockout: %v", err)
+	}
+
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	writer := pool.Writer()
+	insertTtl(writer, cfLockouts, &gossie.Row{
+		Key: userIdToRowKey(lockout.App, lockout.Uid),
+		Columns: []*gossie.Column{{
+			Name:  []byte(lockoutColumn),
+			Value: data,
+		}},
+	}, ttlUntil(lockout.Expires()))
+	if err := writer.Run(); err != nil {
+		return fmt.Errorf("Write error writing to C*: %v", err)
+	}
+	return nil
+}
+
+// DeleteLockout forgets a user's recent failed logins
+func (s *cassandraStore) DeleteLockout(app domain.Application, uid string) error {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	writer := pool.Writer()
+	writer.Delete(cfLockouts, userIdToRowKey(app, uid))
+	if err := writer.Run(); err != nil {
+		return fmt.Errorf("Write error deleting from C*: %v", err)
+	}
+	return nil
+}
+
+// liveLockout returns nil for a lockout that has expired, for Stores without TTLs
+func liveLockout(lockout *domain.Lockout) *domain.Lockout {
+	if lockout == nil || !lockout.Expires().After(time.Now()) {
+		return nil
+	}
+	return lockout
+}
@@ -26,12 +26,26 @@ var userLoginTs *timeseries.TimeSeries = &timeseries.TimeSeries{
 	},
 }
 
+// userFailedLoginTs is the same again for failed logins
+var userFailedLoginTs *timeseries.TimeSeries = &timeseries.TimeSeries{
+	Ks:             Keyspace,
+	Cf:             "userFailedLoginIndex",
+	RowGranularity: time.Hour * 24 * 30,
+	Marshaler: func(i interface{}) (uid string, t time.Time) {
+		return i.(*domain.FailedLogin).Uid, i.(*domain.FailedLogin).Failed
+	},
+	SecondaryIndexer: func(i interface{}) (index string) {
+		failed := i.(*domain.FailedLogin)
+		return toIndex(failed.App, failed.Uid)
+	},
+}
+
 func toIndex(app domain.Application, uid string) string {
 	return string(app) + separator + uid
 }
 
 // WriteLogin will record details of a user login
-func WriteLogin(login *domain.Login) error {
+func (s *cassandraStore) WriteLogin(login *domain.Login) error {
 	pool, err := cassandra.ConnectionPool(Keyspace)
 	if err != nil {
 		return fmt.Errorf("Failed to get connection pool: %v", err)
@@ -49,7 +63,7 @@ func WriteLogin(login *domain.Login) error {
 }
 
 // ReadUserLogins will return a list of user logins for a single user, within a time range
-func ReadUserLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.Login, string, error) {
+func (s *cassandraStore) ReadUserLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.Login, string, error) {
 	iter := userLoginTs.ReversedIterator(start, end, lastId, toIndex(app, uid))
 	logins := make([]*domain.Login, 0)
 
@@ -70,3 +84,44 @@ func ReadUserLogins(app domain.Application, uid string, start, end time.Time, co
 	log.Debugf("Read %v logins as time series", len(logins))
 	return logins, iter.Last(), nil
 }
+
+// WriteFailedLogin will record details of a failed authentication attempt
+func (s *cassandraStore) WriteFailedLogin(failed *domain.FailedLogin) error {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+
+	writer := pool.Writer()
+	userFailedLoginTs.Map(writer, failed, nil)
+	t := time.Now()
+	if err := writer.Run(); err != nil {
+		return fmt.Errorf("Create error writing to C*: %v", err)
+	}
+	inst.Timing(1.0, "cassandra.write.writefailedlogin", time.Since(t))
+
+	return nil
+}
+
+// ReadUserFailedLogins will return a list of failed logins for a single user, within a time range
+func (s *cassandraStore) ReadUserFailedLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.FailedLogin, string, error) {
+	iter := userFailedLoginTs.ReversedIterator(start, end, lastId, toIndex(app, uid))
+	failures := make([]*domain.FailedLogin, 0)
+
+	for iter.Next() {
+		failed := &domain.FailedLogin{}
+		if err := iter.Item().Unmarshal(failed); err != nil {
+			return nil, "", fmt.Errorf("Failed to unmarshal failed login: %v", err)
+		}
+		failures = append(failures, failed)
+		if len(failures) >= count {
+			break
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, "", fmt.Errorf("DAO read error: %v", err)
+	}
+	log.Debugf("Read %v failed logins as time series", len(failures))
+	return failures, iter.Last(), nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
 	"sort"
 	"strings"
 	"time"
@@ -43,9 +44,11 @@ type storedSession struct {
 }
 
 type encodedSession struct {
-	Key              string       `json:"key"`
-	CreatedTimestamp int64        `json:"createdTimestamp"`
-	Token            encodedToken `json:"token"`
+	Key                    string       `json:"key"`
+	CreatedTimestamp       int64        `json:"createdTimestamp"`
+	Token                  encodedToken `json:"token"`
+	RefreshExpiryTimestamp *int64       `json:"refreshExpiryTimestamp,omitempty"`
+	LastSeenTimestamp      *int64       `json:"lastSeenTimestamp,omitempty"`
 }
 
 type encodedToken struct {
@@ -55,6 +58,8 @@ type encodedToken struct {
 	Id                 string `json:"id"`
 	ExpiryTimestamp    int64  `json:"expiryTimestamp"`
 	AutoRenewTimestamp *int64 `json:"autoRenewTimestamp"`
+	KeyId              string `json:"keyId,omitempty"`
+	Algorithm          string `json:"algorithm,omitempty"`
 	Signature          string `json:"signature"`
 	// Ordered set of role patterns granted to the user
 	RolePatterns []string `json:"rolePatterns"`
@@ -74,15 +79,21 @@ type storedUser struct {
 	PasswordChange        time.Time `name:"passwordChangeTimestamp"`
 	AccountExpirationDate string    `name:"accountExpirationDate"`
 	Status                string    `name:"status"`
+	TotpSecret            string    `name:"totpSecret"`
+	TotpPendingSecret     string    `name:"totpPendingSecret"`
+	RecoveryCodes         []byte    `name:"recoveryCodes"`
+	TotpLastStep          int64     `name:"totpLastStep"`
 }
 
 // needed due to the fact PHP encodes empty object as [] rather than {} so Go complains
 // HTWO-319
 
 type encodedSessionNoRoles struct {
-	Key              string              `json:"key"`
-	CreatedTimestamp int64               `json:"createdTimestamp"`
-	Token            encodedTokenNoRoles `json:"token"`
+	Key                    string              `json:"key"`
+	CreatedTimestamp       int64               `json:"createdTimestamp"`
+	Token                  encodedTokenNoRoles `json:"token"`
+	RefreshExpiryTimestamp *int64              `json:"refreshExpiryTimestamp,omitempty"`
+	LastSeenTimestamp      *int64              `json:"lastSeenTimestamp,omitempty"`
 }
 type encodedTokenNoRoles struct {
 	CreatedTimestamp   int64    `json:"createdTimestamp"`
@@ -93,6 +104,8 @@ type encodedTokenNoRoles struct {
 	AutoRenewTimestamp *int64   `json:"autoRenewTimestamp"`
 	RolePatterns       []string `json:"rolePatterns"`
 	RoleCollection     []string `json:"roleCollection"`
+	KeyId              string   `json:"keyId,omitempty"`
+	Algorithm          string   `json:"algorithm,omitempty"`
 	Signature          string   `json:"signature"`
 }
 
@@ -100,6 +113,25 @@ type encodedTokenNoRoles struct {
 
 // marshalSession turns a session domain object into a row for storage
 func marshalSession(sess *domain.Session) (*gossie.Row, error) {
+	jsonBytes, err := encodeSessionData(sess)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &storedSession{
+		Id:          sess.Id,
+		SessionData: jsonBytes,
+	}
+	row, err := sessionMapping.Map(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return row, nil
+}
+
+// encodeSessionData turns a session into the JSON we store
+func encodeSessionData(sess *domain.Session) ([]byte, error) {
 	// Create a map of roles for RolesCollection backwards compatibility
 	rolesMap := make(map[string]string)
 	for _, r := range sess.Token.Roles {
@@ -118,24 +150,14 @@ func marshalSession(sess *domain.Session) (*gossie.Row, error) {
 			AutoRenewTimestamp: optTimeToUnix(sess.Token.AutoRenew),
 			RolePatterns:       sess.Token.Roles,
 			RoleCollection:     rolesMap,
+			KeyId:              sess.Token.KeyId,
+			Algorithm:          sess.Token.Algorithm,
 			Signature:          sess.Token.Signature,
 		},
+		RefreshExpiryTimestamp: optTimeToUnix(sess.RefreshExpires),
+		LastSeenTimestamp:      optTimeToUnix(sess.LastSeen),
 	}
-	jsonBytes, err := json.Marshal(encSess)
-	if err != nil {
-		return nil, err
-	}
-
-	stored := &storedSession{
-		Id:          sess.Id,
-		SessionData: jsonBytes,
-	}
-	row, err := sessionMapping.Map(stored)
-	if err != nil {
-		return nil, err
-	}
-
-	return row, nil
+	return json.Marshal(encSess)
 }
 
 // optTimeToUnix will take a time and return *int64 unless it's zero time in which case nil
@@ -155,13 +177,18 @@ func unmarshalSession(row *gossie.Row) (*domain.Session, error) {
 		return nil, fmt.Errorf("Error unmapping row: %s", err.Error())
 	}
 
-	sess, err := unmarshalDefaultSessionData(stored.SessionData)
+	return decodeSessionData(stored.SessionData)
+}
+
+// decodeSessionData yields a session domain object from the JSON we store
+func decodeSessionData(data []byte) (*domain.Session, error) {
+	sess, err := unmarshalDefaultSessionData(data)
 	if err == nil {
 		return sess, nil
 	}
 
 	// try backup plan
-	if sess, backuperr := unmarshalBorkedSessionData(stored.SessionData); backuperr == nil {
+	if sess, backuperr := unmarshalBorkedSessionData(data); backuperr == nil {
 		return sess, nil
 	}
 
@@ -202,8 +229,12 @@ func unmarshalDefaultSessionData(data []byte) (*domain.Session, error) {
 			Expires:       unixToTime(encoded.Token.ExpiryTimestamp),
 			AutoRenew:     optUnixToTime(encoded.Token.AutoRenewTimestamp),
 			Roles:         roles,
+			KeyId:         encoded.Token.KeyId,
+			Algorithm:     encoded.Token.Algorithm,
 			Signature:     encoded.Token.Signature,
 		},
+		RefreshExpires: optUnixToTime(encoded.RefreshExpiryTimestamp),
+		LastSeen:       optUnixToTime(encoded.LastSeenTimestamp),
 	}, nil
 }
 
@@ -232,8 +263,12 @@ func unmarshalBorkedSessionData(data []byte) (*domain.Session, error) {
 			Expires:       unixToTime(encoded.Token.ExpiryTimestamp),
 			AutoRenew:     optUnixToTime(encoded.Token.AutoRenewTimestamp),
 			Roles:         encoded.Token.RolePatterns,
+			KeyId:         encoded.Token.KeyId,
+			Algorithm:     encoded.Token.Algorithm,
 			Signature:     encoded.Token.Signature,
 		},
+		RefreshExpires: optUnixToTime(encoded.RefreshExpiryTimestamp),
+		LastSeen:       optUnixToTime(encoded.LastSeenTimestamp),
 	}, nil
 }
 
@@ -244,16 +279,45 @@ func writeSession(sess *domain.Session, writer gossie.Writer) error {
 		return fmt.Errorf("Write error marshaling session: %v", err)
 	}
 
-	// write this row for all row keys required
+	// write this row for all row keys required, expiring along with the token
+	ttl := sessionTtl(sess)
 	rowKeys := sessionToRowKeys(sess)
 	for _, rowKey := range rowKeys {
 		row.Key = rowKey
-		writer.Insert(cfSessions, row)
+		insertTtl(writer, cfSessions, row, ttl)
 	}
 
 	return nil
 }
 
+// sessionTtl is how long (in seconds) a session should live in C*, until it is no longer usable (see
+// domain.Session.LiveUntil); 0 means forever
+func sessionTtl(sess *domain.Session) int {
+	return ttlUntil(sess.LiveUntil())
+}
+
+// ttlUntil returns a C* TTL (in seconds) for something that expires at t, or 0 if t is zero
+func ttlUntil(t time.Time) int {
+	if t.IsZero() {
+		return 0
+	}
+	ttl := int(math.Ceil(t.Sub(time.Now()).Seconds()))
+	if ttl < 1 {
+		// a TTL of 0 would mean "never expire", which is the opposite of what we want
+		ttl = 1
+	}
+	return ttl
+}
+
+// insertTtl inserts a row with a TTL, unless the TTL is 0
+func insertTtl(writer gossie.Writer, cf string, row *gossie.Row, ttl int) {
+	if ttl > 0 {
+		writer.InsertTtl(cf, row, ttl)
+	} else {
+		writer.Insert(cf, row)
+	}
+}
+
 // deleteSession turns a session into deletion mutations
 func deleteSession(sess *domain.Session, writer gossie.Writer) {
 	// delete all rows
@@ -283,11 +347,6 @@ func optUnixToTime(t *int64) time.Time {
 func marshalUser(user *domain.User) *storedUser {
 	ids, _ := json.Marshal(user.Ids)
 	roles, _ := json.Marshal(user.Roles)
-	pwordHist := make([]string, 0)
-	for _, hash := range user.PasswordHistory {
-		pwordHist = append(pwordHist, base64.StdEncoding.EncodeToString(hash))
-	}
-	pbytes, _ := json.Marshal(pwordHist)
 	return &storedUser{
 		Id:                    string(userIdToRowKey(user.App, user.Uid)),
 		App:                   string(user.App),
@@ -295,11 +354,15 @@ func marshalUser(user *domain.User) *storedUser {
 		Ids:                   ids,
 		Created:               user.Created,
 		Roles:                 roles,
-		PasswordHistory:       pbytes,
+		PasswordHistory:       encodeHashes(user.PasswordHistory),
 		Password:              user.Password,
 		PasswordChange:        user.PasswordChange,
 		AccountExpirationDate: user.AccountExpirationDate,
 		Status:                user.Status,
+		TotpSecret:            user.TotpSecret,
+		TotpPendingSecret:     user.TotpPendingSecret,
+		RecoveryCodes:         encodeHashes(user.RecoveryCodes),
+		TotpLastStep:          user.TotpLastStep,
 	}
 }
 
@@ -311,17 +374,15 @@ func unmarshalUser(row *gossie.Row) (*domain.User, error) {
 		return nil, fmt.Errorf("Error unmapping row: %v", err)
 	}
 
+	return fromStoredUser(stored), nil
+}
+
+// fromStoredUser decodes the JSON encoded fields of a stored user
+func fromStoredUser(stored *storedUser) *domain.User {
 	ids := make([]domain.Id, 0)
 	json.Unmarshal(stored.Ids, &ids)
 	roles := make([]string, 0)
 	json.Unmarshal(stored.Roles, &roles)
-	pwordHist := make([]string, 0)
-	json.Unmarshal(stored.PasswordHistory, &pwordHist)
-	pwords := make([][]byte, 0)
-	for _, s := range pwordHist {
-		b, _ := base64.StdEncoding.DecodeString(s)
-		pwords = append(pwords, b)
-	}
 
 	return &domain.User{
 		App:                   domain.Application(stored.App),
@@ -329,12 +390,38 @@ func unmarshalUser(row *gossie.Row) (*domain.User, error) {
 		Ids:                   ids,
 		Created:               stored.Created,
 		Roles:                 roles,
-		PasswordHistory:       pwords,
+		PasswordHistory:       decodeHashes(stored.PasswordHistory),
 		Password:              stored.Password,
 		PasswordChange:        stored.PasswordChange,
 		AccountExpirationDate: stored.AccountExpirationDate,
 		Status:                stored.Status,
-	}, nil
+		TotpSecret:            stored.TotpSecret,
+		TotpPendingSecret:     stored.TotpPendingSecret,
+		RecoveryCodes:         decodeHashes(stored.RecoveryCodes),
+		TotpLastStep:          stored.TotpLastStep,
+	}
+}
+
+// encodeHashes encodes a list of password hashes as a JSON list of base64 strings
+func encodeHashes(hashes [][]byte) []byte {
+	encoded := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		encoded = append(encoded, base64.StdEncoding.EncodeToString(hash))
+	}
+	b, _ := json.Marshal(encoded)
+	return b
+}
+
+// decodeHashes is the reverse of encodeHashes, always returning a non-nil list
+func decodeHashes(data []byte) [][]byte {
+	encoded := make([]string, 0)
+	json.Unmarshal(data, &encoded)
+	hashes := make([][]byte, 0, len(encoded))
+	for _, s := range encoded {
+		b, _ := base64.StdEncoding.DecodeString(s)
+		hashes = append(hashes, b)
+	}
+	return hashes
 }
 
 // writeUser maps a user to a mutation, including updating all indexes for additional IDs
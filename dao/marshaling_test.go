@@ -239,3 +239,60 @@ func TestRegression_MarshalledTokenStability(t *testing.T) {
 	token.Sign([]byte("foobarbaz"))
 	assert.Equal(t, expectedString, token.String())
 }
+
+func TestSessionTtl(t *testing.T) {
+	sess := &domain.Session{Token: domain.Token{}}
+	assert.Equal(t, 0, sessionTtl(sess), "Expecting no TTL for a token without expiry")
+
+	sess.Token.Expires = time.Now().Add(8 * time.Hour)
+	ttl := sessionTtl(sess)
+	assert.True(t, ttl > 8*3600-5 && ttl <= 8*3600, "Expecting TTL of ~8 hours, got %d", ttl)
+
+	sess.Token.Expires = time.Now().Add(-time.Minute)
+	assert.Equal(t, 1, sessionTtl(sess), "Expecting minimum TTL for an expired token")
+
+	sess.Token.AutoRenew = time.Now().Add(-31 * time.Minute)
+	ttl = sessionTtl(sess)
+	assert.True(t, ttl > 29*60-5 && ttl <= 29*60, "Expecting a renewable session to live for a renew window, got %d", ttl)
+
+	sess.RefreshExpires = time.Now().Add(24 * time.Hour)
+	ttl = sessionTtl(sess)
+	assert.True(t, ttl > 24*3600-5 && ttl <= 24*3600, "Expecting the session to live as long as its refresh token, got %d", ttl)
+}
+
+func TestSessionRefreshExpiresRoundTrip(t *testing.T) {
+	sess := &domain.Session{
+		Id:             "sess1",
+		Created:        time.Unix(1378377732, 0),
+		Token:          domain.Token{Id: "dave", Expires: time.Unix(1378406533, 0)},
+		RefreshExpires: time.Unix(1380969733, 0),
+	}
+	data, err := encodeSessionData(sess)
+	assert.NoError(t, err)
+	decoded, err := decodeSessionData(data)
+	if assert.NoError(t, err) {
+		assert.True(t, sess.RefreshExpires.Equal(decoded.RefreshExpires))
+	}
+
+	// and sessions without a refresh token don't gain one
+	sess.RefreshExpires = time.Time{}
+	data, _ = encodeSessionData(sess)
+	assert.NotContains(t, string(data), "refreshExpiryTimestamp")
+	decoded, _ = decodeSessionData(data)
+	assert.True(t, decoded.RefreshExpires.IsZero())
+}
+
+func TestSessionLastSeenRoundTrip(t *testing.T) {
+	sess := &domain.Session{
+		Id:       "sess1",
+		Created:  time.Unix(1378377732, 0),
+		Token:    domain.Token{Id: "dave", Expires: time.Unix(1378406533, 0)},
+		LastSeen: time.Unix(1378380000, 0),
+	}
+	data, err := encodeSessionData(sess)
+	assert.NoError(t, err)
+	decoded, err := decodeSessionData(data)
+	if assert.NoError(t, err) {
+		assert.True(t, sess.LastSeen.Equal(decoded.LastSeen))
+	}
+}
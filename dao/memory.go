@@ -0,0 +1,647 @@
+package dao
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/platform/errors"
+)
+
+// memoryStore is a Store held entirely in process memory, for local development and tests. It mirrors
+// the Cassandra store's layout (and so its semantics) rather than modelling things "properly": users
+// are stored under app§uid and again under app§id for every secondary ID, sessions under their ID and
+// again under authMech§deviceType§uid, and userSessions maps uid -> session ID -> device type. The
+// created-time and login time series are derived at read time rather than being stored as indexes.
+type memoryStore struct {
+	sync.RWMutex
+
+	users         map[string]*domain.User
+	sessions      map[string]*domain.Session
+	userSessions  map[string]map[string]string
+	endpointAuths map[string]map[string]string
+	logins        map[string][]*memoryLogin
+	loginSeq      int
+	failedLogins  map[string][]*memoryFailedLogin
+	checkpoints   map[string][]byte
+	lockouts      map[string]*domain.Lockout
+	buckets       map[string]*domain.TokenBucket
+	refreshTokens map[string]*domain.RefreshToken
+	resetTokens   map[string]*domain.PasswordResetToken
+}
+
+// memoryLogin is a login plus a sequence number, which we use as the pagination ID
+type memoryLogin struct {
+	seq   int
+	login *domain.Login
+}
+
+// memoryFailedLogin is the same for failed logins, sharing the sequence
+type memoryFailedLogin struct {
+	seq    int
+	failed *domain.FailedLogin
+}
+
+// NewMemoryStore mints an empty in-memory Store
+func NewMemoryStore() Store {
+	return &memoryStore{
+		users:         make(map[string]*domain.User),
+		sessions:      make(map[string]*domain.Session),
+		userSessions:  make(map[string]map[string]string),
+		endpointAuths: make(map[string]map[string]string),
+		logins:        make(map[string][]*memoryLogin),
+		failedLogins:  make(map[string][]*memoryFailedLogin),
+		checkpoints:   make(map[string][]byte),
+		lockouts:      make(map[string]*domain.Lockout),
+		buckets:       make(map[string]*domain.TokenBucket),
+		refreshTokens: make(map[string]*domain.RefreshToken),
+		resetTokens:   make(map[string]*domain.PasswordResetToken),
+	}
+}
+
+// CreateUser will create a new user so long as none of the IDs already exist
+func (s *memoryStore) CreateUser(user *domain.User, plainPass string) errors.Error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.testIndexes(user); err != nil {
+		return err
+	}
+	if err := testBlat(s.readUser(user.App, user.Uid), user, plainPass); err != nil {
+		return err
+	}
+
+	s.writeUser(user, nil)
+	return nil
+}
+
+// ReadUser returns a user, fetched by UID or secondary ID
+func (s *memoryStore) ReadUser(app domain.Application, uid string) (*domain.User, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.readUser(app, uid), nil
+}
+
+// MultiReadUser returns all users found for the supplied IDs
+func (s *memoryStore) MultiReadUser(app domain.Application, uids []string) ([]*domain.User, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	ret := make([]*domain.User, 0, len(uids))
+	for _, uid := range uids {
+		if user := s.readUser(app, uid); user != nil {
+			ret = append(ret, user)
+		}
+	}
+	return ret, nil
+}
+
+// UpdateUser will update details of an existing user
+func (s *memoryStore) UpdateUser(user *domain.User) error {
+	s.Lock()
+	defer s.Unlock()
+
+	existingUser := s.readUser(user.App, user.Uid)
+	if existingUser == nil {
+		return fmt.Errorf("User %v:%s does not exist - cannot update", user.App, user.Uid)
+	}
+	if err := s.testIndexes(user); err != nil {
+		return err
+	}
+
+	s.writeUser(user, existingUser)
+	return nil
+}
+
+// DeleteUserIndexes removes the user's primary row and the supplied secondary indexes
+func (s *memoryStore) DeleteUserIndexes(user *domain.User, uid string, ids []domain.Id) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.users, string(userIdToRowKey(user.App, uid)))
+	for _, id := range ids {
+		delete(s.users, string(userIdToRowKey(user.App, string(id))))
+	}
+	return nil
+}
+
+// ReadUserList returns users ordered (newest first) by created timestamp
+func (s *memoryStore) ReadUserList(app domain.Application, start, end time.Time, count int, lastId string) ([]*domain.User, string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	matched := make([]*domain.User, 0)
+	for rowKey, user := range s.users {
+		// only primary rows make up the index -- secondary rows are the same user again
+		if user.App != app || rowKey != string(userIdToRowKey(user.App, user.Uid)) {
+			continue
+		}
+		if user.Created.Before(start) || user.Created.After(end) {
+			continue
+		}
+		matched = append(matched, user)
+	}
+	sort.Sort(usersByCreatedDesc(matched))
+
+	users := make([]*domain.User, 0)
+	last := ""
+	for _, i := range skipUntil(len(matched), lastId, func(i int) string { return matched[i].Uid }) {
+		users = append(users, copyUser(matched[i]))
+		last = matched[i].Uid
+		if len(users) >= count {
+			break
+		}
+	}
+
+	return users, last, nil
+}
+
+// ReadSession fetches a single session by session ID or secondary row key
+func (s *memoryStore) ReadSession(rowKey string) (*domain.Session, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return copySession(s.sessions[rowKey]), nil
+}
+
+// ReadActiveSessionFor fetches a single session by auth mechanism + device type + user ID
+func (s *memoryStore) ReadActiveSessionFor(authMechanism, deviceType, userId string) (*domain.Session, error) {
+	return s.ReadSession(string(authMechDeviceUserIdToRowKey(authMechanism, deviceType, userId)))
+}
+
+// WriteSession is create/update combined for sessions
+func (s *memoryStore) WriteSession(sess *domain.Session) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, rowKey := range sessionToRowKeys(sess) {
+		s.sessions[string(rowKey)] = copySession(sess)
+	}
+	if sess.Token.Id != "" {
+		if _, ok := s.userSessions[sess.Token.Id]; !ok {
+			s.userSessions[sess.Token.Id] = make(map[string]string)
+		}
+		s.userSessions[sess.Token.Id][sess.Id] = sess.Token.DeviceType
+	}
+	return nil
+}
+
+// DeleteSession will remove all knowledge of a session
+func (s *memoryStore) DeleteSession(rowKey string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	sess, ok := s.sessions[rowKey]
+	if !ok {
+		return nil
+	}
+	for _, k := range sessionToRowKeys(sess) {
+		// the secondary key may since have been taken by a newer session for the same device type
+		if other, ok := s.sessions[string(k)]; ok && other.Id == sess.Id {
+			delete(s.sessions, string(k))
+		}
+	}
+	delete(s.userSessions[sess.Token.Id], sess.Id)
+	return nil
+}
+
+// ReadActiveSessionIdsFor retrieves all active session IDs (mapped to their device type) for a user
+func (s *memoryStore) ReadActiveSessionIdsFor(userId string) (map[string]string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	sessionIds := make(map[string]string, len(s.userSessions[userId]))
+	for sessId, deviceType := range s.userSessions[userId] {
+		sessionIds[sessId] = deviceType
+	}
+	return sessionIds, nil
+}
+
+// ReadEndpointAuth grabs a list of all authorised services that can make requests to the supplied service
+func (s *memoryStore) ReadEndpointAuth(service string) ([]*domain.EndpointAuth, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	// return in column order, as Cassandra would
+	cols := make([]string, 0, len(s.endpointAuths[service]))
+	for col := range s.endpointAuths[service] {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	if len(cols) > maxAuthedServices {
+		cols = cols[:maxAuthedServices]
+	}
+
+	ret := make([]*domain.EndpointAuth, 0, len(cols))
+	for _, col := range cols {
+		colNameParts := strings.SplitN(col, separator, 2)
+		ret = append(ret, &domain.EndpointAuth{
+			ServiceName:    service,
+			EndpointName:   colNameParts[0],
+			AllowedService: colNameParts[1],
+			Role:           s.endpointAuths[service][col],
+		})
+	}
+	return ret, nil
+}
+
+// WriteEndpointAuths defines new rules that allow some service to call some endpoint
+func (s *memoryStore) WriteEndpointAuths(epas []*domain.EndpointAuth) error {
+	if len(epas) == 0 {
+		return fmt.Errorf("No rules to write")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, epa := range epas {
+		if _, ok := s.endpointAuths[epa.ServiceName]; !ok {
+			s.endpointAuths[epa.ServiceName] = make(map[string]string)
+		}
+		s.endpointAuths[epa.ServiceName][epa.EndpointName+separator+epa.AllowedService] = epa.Role
+	}
+	return nil
+}
+
+// DeleteEndpointAuths will revoke these rules
+func (s *memoryStore) DeleteEndpointAuths(epas []*domain.EndpointAuth) error {
+	if len(epas) == 0 {
+		return fmt.Errorf("No rules to delete")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, epa := range epas {
+		delete(s.endpointAuths[epa.ServiceName], epa.EndpointName+separator+epa.AllowedService)
+	}
+	return nil
+}
+
+// WriteLogin will record details of a user login
+func (s *memoryStore) WriteLogin(login *domain.Login) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.loginSeq++
+	c := *login
+	c.Meta = make(map[string]string, len(login.Meta))
+	for k, v := range login.Meta {
+		c.Meta[k] = v
+	}
+	idx := toIndex(login.App, login.Uid)
+	s.logins[idx] = append(s.logins[idx], &memoryLogin{seq: s.loginSeq, login: &c})
+	return nil
+}
+
+// ReadUserLogins will return a list of user logins (newest first) for a single user, within a time range
+func (s *memoryStore) ReadUserLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.Login, string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	matched := make([]*memoryLogin, 0)
+	for _, ml := range s.logins[toIndex(app, uid)] {
+		if ml.login.LoggedIn.Before(start) || ml.login.LoggedIn.After(end) {
+			continue
+		}
+		matched = append(matched, ml)
+	}
+	sort.Sort(loginsByLoggedInDesc(matched))
+
+	logins := make([]*domain.Login, 0)
+	last := ""
+	for _, i := range skipUntil(len(matched), lastId, func(i int) string { return strconv.Itoa(matched[i].seq) }) {
+		logins = append(logins, matched[i].login)
+		last = strconv.Itoa(matched[i].seq)
+		if len(logins) >= count {
+			break
+		}
+	}
+
+	return logins, last, nil
+}
+
+// WriteFailedLogin will record details of a failed authentication attempt
+func (s *memoryStore) WriteFailedLogin(failed *domain.FailedLogin) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.loginSeq++
+	c := *failed
+	c.Meta = make(map[string]string, len(failed.Meta))
+	for k, v := range failed.Meta {
+		c.Meta[k] = v
+	}
+	idx := toIndex(failed.App, failed.Uid)
+	s.failedLogins[idx] = append(s.failedLogins[idx], &memoryFailedLogin{seq: s.loginSeq, failed: &c})
+	return nil
+}
+
+// ReadUserFailedLogins will return a list of failed logins (newest first) for a single user, within a time range
+func (s *memoryStore) ReadUserFailedLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.FailedLogin, string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	matched := make([]*memoryFailedLogin, 0)
+	for _, mf := range s.failedLogins[toIndex(app, uid)] {
+		if mf.failed.Failed.Before(start) || mf.failed.Failed.After(end) {
+			continue
+		}
+		matched = append(matched, mf)
+	}
+	sort.Sort(failedLoginsByFailedDesc(matched))
+
+	failures := make([]*domain.FailedLogin, 0)
+	last := ""
+	for _, i := range skipUntil(len(matched), lastId, func(i int) string { return strconv.Itoa(matched[i].seq) }) {
+		failures = append(failures, matched[i].failed)
+		last = strconv.Itoa(matched[i].seq)
+		if len(failures) >= count {
+			break
+		}
+	}
+
+	return failures, last, nil
+}
+
+// scanSessions returns sessions in ID order; only the primary rows, since we have no TTLs to tidy up
+// the secondary ones
+func (s *memoryStore) scanSessions(after string, count int) ([]*sweptSession, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	keys := make([]string, 0)
+	for key, sess := range s.sessions {
+		if key == sess.Id && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > count {
+		keys = keys[:count]
+	}
+
+	ret := make([]*sweptSession, 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, &sweptSession{key: key, sess: copySession(s.sessions[key])})
+	}
+	return ret, nil
+}
+
+// scanUserSessions returns users' session ID -> device type maps in UID order
+func (s *memoryStore) scanUserSessions(after string, count int) ([]*sweptUserSessions, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	uids := make([]string, 0)
+	for uid, sessionIds := range s.userSessions {
+		if uid > after && len(sessionIds) > 0 {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Strings(uids)
+	if len(uids) > count {
+		uids = uids[:count]
+	}
+
+	ret := make([]*sweptUserSessions, 0, len(uids))
+	for _, uid := range uids {
+		sessionIds := make(map[string]string, len(s.userSessions[uid]))
+		for sessId, deviceType := range s.userSessions[uid] {
+			sessionIds[sessId] = deviceType
+		}
+		ret = append(ret, &sweptUserSessions{uid: uid, sessionIds: sessionIds})
+	}
+	return ret, nil
+}
+
+// deleteUserSession removes a single userSessions entry
+func (s *memoryStore) deleteUserSession(uid, deviceType, sessId string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.userSessions[uid], sessId)
+	return nil
+}
+
+func (s *memoryStore) readCheckpoint(name string) ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.checkpoints[name], nil
+}
+
+func (s *memoryStore) writeCheckpoint(name string, data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.checkpoints[name] = append([]byte{}, data...)
+	return nil
+}
+
+// testIndexes makes sure a secondary index (user.Ids) is not in use by someone else already
+func (s *memoryStore) testIndexes(user *domain.User) errors.Error {
+	for _, id := range user.Ids {
+		if other, ok := s.users[string(userIdToRowKey(user.App, string(id)))]; ok && other.Uid != user.Uid {
+			return errors.BadRequest("com.HailoOSS.service.login.createuser.indexinuse", fmt.Sprintf("Index '%s' is already linked to another user '%s'", id, other.Uid))
+		}
+	}
+	return nil
+}
+
+// readUser does the work of ReadUser; the caller must hold the lock
+func (s *memoryStore) readUser(app domain.Application, uid string) *domain.User {
+	return copyUser(s.users[string(userIdToRowKey(app, uid))])
+}
+
+// writeUser stores a user under all its row keys, removing any secondary IDs it no longer has; the
+// caller must hold the lock
+func (s *memoryStore) writeUser(user *domain.User, existingUser *domain.User) {
+	stored := copyUser(user)
+	s.users[string(userIdToRowKey(user.App, user.Uid))] = stored
+
+	newIds := make(map[domain.Id]bool)
+	for _, id := range user.Ids {
+		s.users[string(userIdToRowKey(user.App, string(id)))] = stored
+		newIds[id] = true
+	}
+
+	if existingUser != nil {
+		for _, id := range existingUser.Ids {
+			if !newIds[id] {
+				delete(s.users, string(userIdToRowKey(user.App, string(id))))
+			}
+		}
+	}
+}
+
+// skipUntil returns the indexes [0, n) that come after the one whose ID is lastId (or all of them, if
+// lastId is empty or not found)
+func skipUntil(n int, lastId string, id func(i int) string) []int {
+	from := 0
+	if lastId != "" {
+		for i := 0; i < n; i++ {
+			if id(i) == lastId {
+				from = i + 1
+				break
+			}
+		}
+	}
+
+	ret := make([]int, 0, n-from)
+	for i := from; i < n; i++ {
+		ret = append(ret, i)
+	}
+	return ret
+}
+
+// ReadLockout fetches a user's recent failed logins, ignoring those that have expired
+func (s *memoryStore) ReadLockout(app domain.Application, uid string) (*domain.Lockout, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	lockout, ok := s.lockouts[string(userIdToRowKey(app, uid))]
+	if !ok {
+		return nil, nil
+	}
+	c := *lockout
+	return liveLockout(&c), nil
+}
+
+// WriteLockout stores a user's recent failed logins
+func (s *memoryStore) WriteLockout(lockout *domain.Lockout) error {
+	s.Lock()
+	defer s.Unlock()
+
+	c := *lockout
+	s.lockouts[string(userIdToRowKey(lockout.App, lockout.Uid))] = &c
+	return nil
+}
+
+// DeleteLockout forgets a user's recent failed logins
+func (s *memoryStore) DeleteLockout(app domain.Application, uid string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.lockouts, string(userIdToRowKey(app, uid)))
+	return nil
+}
+
+// TakeToken takes a token from the named bucket
+func (s *memoryStore) TakeToken(key string, limit domain.RateLimit) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &domain.TokenBucket{}
+		s.buckets[key] = bucket
+	}
+	return bucket.Take(limit, time.Now()), nil
+}
+
+// ReadRefreshToken fetches a refresh token by its hash
+func (s *memoryStore) ReadRefreshToken(hash string) (*domain.RefreshToken, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	rt, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, nil
+	}
+	c := *rt
+	return &c, nil
+}
+
+// WriteRefreshToken stores a refresh token
+func (s *memoryStore) WriteRefreshToken(rt *domain.RefreshToken) error {
+	s.Lock()
+	defer s.Unlock()
+
+	c := *rt
+	s.refreshTokens[rt.Hash] = &c
+	return nil
+}
+
+// ReadPasswordResetToken fetches a password reset token by its hash
+func (s *memoryStore) ReadPasswordResetToken(hash string) (*domain.PasswordResetToken, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	prt, ok := s.resetTokens[hash]
+	if !ok {
+		return nil, nil
+	}
+	c := *prt
+	return &c, nil
+}
+
+// WritePasswordResetToken stores a password reset token
+func (s *memoryStore) WritePasswordResetToken(prt *domain.PasswordResetToken) error {
+	s.Lock()
+	defer s.Unlock()
+
+	c := *prt
+	s.resetTokens[prt.Hash] = &c
+	return nil
+}
+
+func copyUser(u *domain.User) *domain.User {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	c.Ids = append([]domain.Id{}, u.Ids...)
+	c.Roles = append([]string{}, u.Roles...)
+	c.PasswordHistory = append([][]byte{}, u.PasswordHistory...)
+	c.RecoveryCodes = append([][]byte{}, u.RecoveryCodes...)
+	c.Password = append([]byte{}, u.Password...)
+	return &c
+}
+
+func copySession(sess *domain.Session) *domain.Session {
+	if sess == nil {
+		return nil
+	}
+	c := sess.Copy()
+	c.Token = *sess.Token.Copy()
+	return c
+}
+
+type usersByCreatedDesc []*domain.User
+
+func (u usersByCreatedDesc) Len() int      { return len(u) }
+func (u usersByCreatedDesc) Swap(i, j int) { u[i], u[j] = u[j], u[i] }
+func (u usersByCreatedDesc) Less(i, j int) bool {
+	if u[i].Created.Equal(u[j].Created) {
+		return u[i].Uid < u[j].Uid
+	}
+	return u[i].Created.After(u[j].Created)
+}
+
+type loginsByLoggedInDesc []*memoryLogin
+
+func (l loginsByLoggedInDesc) Len() int      { return len(l) }
+func (l loginsByLoggedInDesc) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l loginsByLoggedInDesc) Less(i, j int) bool {
+	if l[i].login.LoggedIn.Equal(l[j].login.LoggedIn) {
+		return l[i].seq > l[j].seq
+	}
+	return l[i].login.LoggedIn.After(l[j].login.LoggedIn)
+}
+
+type failedLoginsByFailedDesc []*memoryFailedLogin
+
+func (l failedLoginsByFailedDesc) Len() int      { return len(l) }
+func (l failedLoginsByFailedDesc) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l failedLoginsByFailedDesc) Less(i, j int) bool {
+	if l[i].failed.Failed.Equal(l[j].failed.Failed) {
+		return l[i].seq > l[j].seq
+	}
+	return l[i].failed.Failed.After(l[j].failed.Failed)
+}
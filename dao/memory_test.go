@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"testing"
+)
+
+func TestMemoryUserIndexes(t *testing.T) {
+	testStoreUserIndexes(t, NewMemoryStore())
+}
+
+func TestMemoryUserMfa(t *testing.T) {
+	testStoreUserMfa(t, NewMemoryStore())
+}
+
+func TestMemoryLockouts(t *testing.T) {
+	testStoreLockouts(t, NewMemoryStore())
+}
+
+func TestMemoryTakeToken(t *testing.T) {
+	testStoreTakeToken(t, NewMemoryStore())
+}
+
+func TestMemoryReadUserList(t *testing.T) {
+	testStoreReadUserList(t, NewMemoryStore())
+}
+
+func TestMemorySessions(t *testing.T) {
+	testStoreSessions(t, NewMemoryStore())
+}
+
+func TestMemoryMultipleSessions(t *testing.T) {
+	testStoreMultipleSessions(t, NewMemoryStore())
+}
+
+func TestMemoryReadUserLogins(t *testing.T) {
+	testStoreReadUserLogins(t, NewMemoryStore())
+}
+
+func TestMemoryReadUserFailedLogins(t *testing.T) {
+	testStoreReadUserFailedLogins(t, NewMemoryStore())
+}
+
+func TestMemoryActiveKeyId(t *testing.T) {
+	testStoreActiveKeyId(t, NewMemoryStore())
+}
+
+func TestMemoryRefreshTokens(t *testing.T) {
+	testStoreRefreshTokens(t, NewMemoryStore())
+}
+
+func TestMemoryPasswordResetTokens(t *testing.T) {
+	testStorePasswordResetTokens(t, NewMemoryStore())
+}
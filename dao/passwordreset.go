@@ -0,0 +1,66 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/HailoOSS/gossie/src/gossie"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/service/cassandra"
+)
+
+/*
+ CF structure:
+  ROW KEY      COL                  VALUE
+ [hash]       [passwordResetToken]  JSON
+
+ Rows are written with a TTL of when the reset token expires
+*/
+
+const passwordResetTokenColumn = "passwordResetToken"
+
+// ReadPasswordResetToken fetches a password reset token by its hash
+func (s *cassandraStore) ReadPasswordResetToken(hash string) (*domain.PasswordResetToken, error) {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	row, err := pool.Reader().Cf(cfPasswordResets).Columns([][]byte{[]byte(passwordResetTokenColumn)}).Get([]byte(hash))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from C*: %v", err)
+	}
+	if row == nil || len(row.Columns) == 0 {
+		return nil, nil
+	}
+
+	prt := &domain.PasswordResetToken{}
+	if err := json.Unmarshal(row.Columns[0].Value, prt); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal password reset token: %v", err)
+	}
+	return prt, nil
+}
+
+// WritePasswordResetToken stores a password reset token, with a TTL of when it expires
+func (s *cassandraStore) WritePasswordResetToken(prt *domain.PasswordResetToken) error {
+	data, err := json.Marshal(prt)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal password reset token: %v", err)
+	}
+
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	writer := pool.Writer()
+	insertTtl(writer, cfPasswordResets, &gossie.Row{
+		Key: []byte(prt.Hash),
+		Columns: []*gossie.Column{{
+			Name:  []byte(passwordResetTokenColumn),
+			Value: data,
+		}},
+	}, ttlUntil(prt.Expires))
+	if err := writer.Run(); err != nil {
+		return fmt.Errorf("Write error writing to C*: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/HailoOSS/gossie/src/gossie"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/service/cassandra"
+)
+
+/*
+ CF structure:
+  ROW KEY   COL       VALUE
+ [key]     [bucket]  JSON
+
+ Rows are written with a TTL of when the bucket will be full again, since a full bucket is the same as none
+*/
+
+const bucketColumn = "bucket"
+
+// TakeToken takes a token from the named bucket, under a ZK lock since C* can't do it atomically
+func (s *cassandraStore) TakeToken(key string, limit domain.RateLimit) (bool, error) {
+	if limit.Burst <= 0 {
+		return true, nil
+	}
+
+	lck, err := lockRateLimit(key)
+	if err != nil {
+		lck.Unlock()
+		return false, fmt.Errorf("Failed to lock: %v", err)
+	}
+	defer lck.Unlock()
+
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return false, fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	row, err := pool.Reader().Cf(cfRateLimits).Columns([][]byte{[]byte(bucketColumn)}).Get([]byte(key))
+	if err != nil {
+		return false, fmt.Errorf("Failed to read from C*: %v", err)
+	}
+	bucket := &domain.TokenBucket{}
+	if row != nil && len(row.Columns) > 0 {
+		if err := json.Unmarshal(row.Columns[0].Value, bucket); err != nil {
+			return false, fmt.Errorf("Failed to unmarshal bucket: %v", err)
+		}
+	}
+
+	ok := bucket.Take(limit, time.Now())
+	data, err := json.Marshal(bucket)
+	if err != nil {
+		return false, fmt.Errorf("Failed to marshal bucket: %v", err)
+	}
+	writer := pool.Writer()
+	insertTtl(writer, cfRateLimits, &gossie.Row{
+		Key: []byte(key),
+		Columns: []*gossie.Column{{
+			Name:  []byte(bucketColumn),
+			Value: data,
+		}},
+	}, ttlUntil(bucket.Expires(limit)))
+	if err := writer.Run(); err != nil {
+		return false, fmt.Errorf("Write error writing to C*: %v", err)
+	}
+
+	return ok, nil
+}
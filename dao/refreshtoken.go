@@ -0,0 +1,67 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/HailoOSS/gossie/src/gossie"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/service/cassandra"
+)
+
+/*
+ CF structure:
+  ROW KEY      COL             VALUE
+ [hash]       [refreshToken]  JSON
+
+ Rows are written with a TTL of when the refresh token expires; used ones are kept until then so that we
+ notice if they are replayed
+*/
+
+const refreshTokenColumn = "refreshToken"
+
+// ReadRefreshToken fetches a refresh token by its hash
+func (s *cassandraStore) ReadRefreshToken(hash string) (*domain.RefreshToken, error) {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	row, err := pool.Reader().Cf(cfRefreshTokens).Columns([][]byte{[]byte(refreshTokenColumn)}).Get([]byte(hash))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from C*: %v", err)
+	}
+	if row == nil || len(row.Columns) == 0 {
+		return nil, nil
+	}
+
+	rt := &domain.RefreshToken{}
+	if err := json.Unmarshal(row.Columns[0].Value, rt); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal refresh token: %v", err)
+	}
+	return rt, nil
+}
+
+// WriteRefreshToken stores a refresh token, with a TTL of when it expires
+func (s *cassandraStore) WriteRefreshToken(rt *domain.RefreshToken) error {
+	data, err := json.Marshal(rt)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal refresh token: %v", err)
+	}
+
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	writer := pool.Writer()
+	insertTtl(writer, cfRefreshTokens, &gossie.Row{
+		Key: []byte(rt.Hash),
+		Columns: []*gossie.Column{{
+			Name:  []byte(refreshTokenColumn),
+			Value: data,
+		}},
+	}, ttlUntil(rt.Expires))
+	if err := writer.Run(); err != nil {
+		return fmt.Errorf("Write error writing to C*: %v", err)
+	}
+	return nil
+}
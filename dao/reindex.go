@@ -15,14 +15,24 @@ const (
 	sleepInterval = 500 * time.Millisecond
 )
 
+// reindexer is implemented by storage backends whose user created-time index can be rebuilt in place
+type reindexer interface {
+	reindexUsers() error
+}
+
 // ReindexUsers is **temporary** so we can upgrade-in-place our data model and put users into a TS index
 func ReindexUsers() {
-	if err := doReindex(); err != nil {
+	r, ok := defaultStore.(reindexer)
+	if !ok {
+		log.Info("[Reindex] Storage backend does not need reindexing")
+		return
+	}
+	if err := r.reindexUsers(); err != nil {
 		log.Criticalf("[Reindex] Failed to index users: %v", err)
 	}
 }
 
-func doReindex() error {
+func (s *cassandraStore) reindexUsers() error {
 	log.Info("[Reindex] Kicking off reindexing")
 
 	pool, err := cassandra.ConnectionPool(Keyspace)
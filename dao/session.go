@@ -2,6 +2,7 @@ package dao
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/HailoOSS/login-service/domain"
@@ -10,9 +11,17 @@ import (
 	"github.com/HailoOSS/gossie/src/gossie"
 )
 
+/*
+ userSessions CF structure:
+  ROW KEY   COL                     VALUE
+ [uid]     [deviceType§sessionId]  sessionId
+ Columns written before a user could have several sessions on a device type are named for the device type
+ alone; they go with their TTL.
+*/
+
 // ReadSession fetches a single session - usually by base64-encoded sessionId, but also called
 // by ReadActiveSessionFor for secondary indexed sessions
-func ReadSession(rowKey string) (*domain.Session, error) {
+func (s *cassandraStore) ReadSession(rowKey string) (*domain.Session, error) {
 	pool, err := cassandra.ConnectionPool(Keyspace)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get connection pool: %v", err)
@@ -35,14 +44,15 @@ func ReadSession(rowKey string) (*domain.Session, error) {
 	return session, nil
 }
 
-// ReadSession fetches a single session by secondary auth mechanism + device type + user ID index
-func ReadActiveSessionFor(authMechanism, deviceType, userId string) (*domain.Session, error) {
-	sess, err := ReadSession(string(authMechDeviceUserIdToRowKey(authMechanism, deviceType, userId)))
+// ReadActiveSessionFor fetches a single session by secondary auth mechanism + device type + user ID index, which
+// points at the most recently written session for them
+func (s *cassandraStore) ReadActiveSessionFor(authMechanism, deviceType, userId string) (*domain.Session, error) {
+	sess, err := s.ReadSession(string(authMechDeviceUserIdToRowKey(authMechanism, deviceType, userId)))
 	return sess, err
 }
 
 // WriteSession is create/update combined (we don't care) for sessions
-func WriteSession(sess *domain.Session) error {
+func (s *cassandraStore) WriteSession(sess *domain.Session) error {
 	pool, err := cassandra.ConnectionPool(Keyspace)
 	if err != nil {
 		return fmt.Errorf("Failed to get connection pool: %v", err)
@@ -53,15 +63,16 @@ func WriteSession(sess *domain.Session) error {
 		return fmt.Errorf("Failed to marshal into mutation: %v", err)
 	}
 
-	// Add a column to the user's row in userSessions representing this session, keyed on the device type
+	// Add a column to the user's row in userSessions representing this session (this expires along with
+	// the session)
 	if sess.Token.Id != "" {
-		writer.Insert(cfUserSessions, &gossie.Row{
+		insertTtl(writer, cfUserSessions, &gossie.Row{
 			Key: []byte(sess.Token.Id),
 			Columns: []*gossie.Column{{
-				Name:  []byte(sess.Token.DeviceType),
+				Name:  userSessionColumn(sess.Token.DeviceType, sess.Id),
 				Value: []byte(sess.Id),
 			}},
-		})
+		}, sessionTtl(sess))
 	}
 
 	t := time.Now()
@@ -73,10 +84,10 @@ func WriteSession(sess *domain.Session) error {
 	return nil
 }
 
-// DeleteSession will remove all knowledge of a session. The session device type must be specified because it needs to
-// be expunged from the userSessions column family
-func DeleteSession(rowKey, deviceType string) error {
-	sess, err := ReadSession(rowKey)
+// DeleteSession will remove all knowledge of a session. The secondary index row is left alone if a newer session for
+// the same device type has since taken it.
+func (s *cassandraStore) DeleteSession(rowKey string) error {
+	sess, err := s.ReadSession(rowKey)
 	if err != nil {
 		return fmt.Errorf("Delete session failed - error reading existing session: %v", err)
 	}
@@ -86,13 +97,24 @@ func DeleteSession(rowKey, deviceType string) error {
 		return nil
 	}
 
+	latest, err := s.ReadActiveSessionFor(sess.Token.AuthMechanism, sess.Token.DeviceType, sess.Token.Id)
+	if err != nil {
+		return fmt.Errorf("Delete session failed - error reading latest session for device type: %v", err)
+	}
+
 	pool, err := cassandra.ConnectionPool(Keyspace)
 	if err != nil {
 		return fmt.Errorf("Failed to get connection pool: %v", err)
 	}
 	writer := pool.Writer()
-	deleteSession(sess, writer)
-	writer.DeleteColumns(cfUserSessions, []byte(sess.Token.Id), [][]byte{[]byte(deviceType)})
+	if latest != nil && latest.Id == sess.Id {
+		deleteSession(sess, writer)
+	} else {
+		writer.Delete(cfSessions, []byte(sess.Id))
+	}
+	if err := deleteUserSessionColumns(pool, writer, sess.Token.Id, sess.Token.DeviceType, sess.Id); err != nil {
+		return err
+	}
 	t := time.Now()
 	if err := writer.Run(); err != nil {
 		return fmt.Errorf("Write error deleting from C*: %v", err)
@@ -102,9 +124,8 @@ func DeleteSession(rowKey, deviceType string) error {
 	return nil
 }
 
-// ReadActiveSessionIdsFor retrieves all active session IDs (keyed in a map by their device type) for a given user
-// ID
-func ReadActiveSessionIdsFor(userId string) (sessionIds map[string]string, err error) {
+// ReadActiveSessionIdsFor retrieves all active session IDs (mapped to their device type) for a given user ID
+func (s *cassandraStore) ReadActiveSessionIdsFor(userId string) (sessionIds map[string]string, err error) {
 	pool, err := cassandra.ConnectionPool(Keyspace)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get connection pool: %v", err)
@@ -120,9 +141,35 @@ func ReadActiveSessionIdsFor(userId string) (sessionIds map[string]string, err e
 		return make(map[string]string, 0), nil
 	}
 
-	sessionIds = make(map[string]string, len(row.Columns))
+	return unmarshalUserSessions(row), nil
+}
+
+// userSessionColumn names a session's column in its user's userSessions row
+func userSessionColumn(deviceType, sessId string) []byte {
+	return []byte(deviceType + separator + sessId)
+}
+
+// unmarshalUserSessions turns a userSessions row into a session ID -> device type map; legacy columns are named
+// for the device type alone, so the name up to the first separator is the device type either way
+func unmarshalUserSessions(row *gossie.Row) map[string]string {
+	sessionIds := make(map[string]string, len(row.Columns))
 	for _, col := range row.Columns {
-		sessionIds[string(col.Name)] = string(col.Value)
+		sessionIds[string(col.Value)] = strings.SplitN(string(col.Name), separator, 2)[0]
+	}
+	return sessionIds
+}
+
+// deleteUserSessionColumns adds the deletion of a session's userSessions column to writer, along with the user's
+// legacy column for the device type if that points at the same session
+func deleteUserSessionColumns(pool gossie.ConnectionPool, writer gossie.Writer, uid, deviceType, sessId string) error {
+	cols := [][]byte{userSessionColumn(deviceType, sessId)}
+	row, err := pool.Reader().Cf(cfUserSessions).Columns([][]byte{[]byte(deviceType)}).Get([]byte(uid))
+	if err != nil {
+		return fmt.Errorf("Failed to read from C*: %v", err)
 	}
-	return sessionIds, nil
+	if row != nil && len(row.Columns) > 0 && string(row.Columns[0].Value) == sessId {
+		cols = append(cols, []byte(deviceType))
+	}
+	writer.DeleteColumns(cfUserSessions, []byte(uid), cols)
+	return nil
 }
@@ -0,0 +1,87 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/HailoOSS/login-service/domain"
+)
+
+const (
+	// activeKeyCheckpointName is where we record which key all instances should sign tokens with
+	activeKeyCheckpointName = "signingkey"
+	// maxKeyRotations is how many rotations we remember, which is plenty to work out which keys are retired
+	maxKeyRotations = 20
+)
+
+// signingKeyState is what we store under activeKeyCheckpointName; the last rotation is the active key
+type signingKeyState struct {
+	Rotations []*domain.KeyRotation
+}
+
+// ReadActiveKeyId returns the ID of the signing key last promoted, or "" if none ever has been
+func ReadActiveKeyId() (string, error) {
+	rotations, err := ReadKeyRotations()
+	if err != nil || len(rotations) == 0 {
+		return "", err
+	}
+	return rotations[len(rotations)-1].KeyId, nil
+}
+
+// ReadKeyRotations returns the most recent signing key rotations, oldest first
+func ReadKeyRotations() ([]*domain.KeyRotation, error) {
+	cp, ok := defaultStore.(checkpointer)
+	if !ok {
+		return nil, fmt.Errorf("Storage backend cannot store the active signing key")
+	}
+	state, err := readSigningKeyState(cp)
+	if err != nil {
+		return nil, err
+	}
+	return state.Rotations, nil
+}
+
+// WriteActiveKeyId records the ID of the key all instances should sign tokens with
+func WriteActiveKeyId(kid string) error {
+	cp, ok := defaultStore.(checkpointer)
+	if !ok {
+		return fmt.Errorf("Storage backend cannot store the active signing key")
+	}
+	state, err := readSigningKeyState(cp)
+	if err != nil {
+		return err
+	}
+
+	state.Rotations = append(state.Rotations, &domain.KeyRotation{KeyId: kid, Promoted: time.Now()})
+	if len(state.Rotations) > maxKeyRotations {
+		state.Rotations = state.Rotations[len(state.Rotations)-maxKeyRotations:]
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal active signing key: %v", err)
+	}
+	if err := cp.writeCheckpoint(activeKeyCheckpointName, data); err != nil {
+		return fmt.Errorf("Failed to write active signing key: %v", err)
+	}
+	return nil
+}
+
+func readSigningKeyState(cp checkpointer) (*signingKeyState, error) {
+	data, err := cp.readCheckpoint(activeKeyCheckpointName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read active signing key: %v", err)
+	}
+	state := &signingKeyState{}
+	switch {
+	case len(data) == 0:
+	case data[0] != '{':
+		// originally we stored just the active key's ID
+		state.Rotations = []*domain.KeyRotation{{KeyId: string(data)}}
+	default:
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal active signing key: %v", err)
+		}
+	}
+	return state, nil
+}
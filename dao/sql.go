@@ -0,0 +1,787 @@
+package dao
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/platform/errors"
+)
+
+const (
+	sqlUserColumns = "app, uid, ids, created, roles, password_history, password, password_change, account_expiration_date, status, " +
+		"totp_secret, totp_pending_secret, recovery_codes, totp_last_step"
+)
+
+// sqlStore is a Store backed by a relational database via database/sql. The driver must be registered
+// by whoever imports us (main imports lib/pq and go-sqlite3). The schema is managed by migrate.
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+// sqlQuerier is satisfied by both *sql.DB and *sql.Tx
+type sqlQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NewSQLStore connects to a database using the named database/sql driver ("postgres" or "sqlite3")
+// and brings its schema up to date
+func NewSQLStore(driver, dsn string) (Store, error) {
+	dialect, ok := sqlDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported SQL driver '%s'", driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open database: %v", err)
+	}
+	if driver == "sqlite3" {
+		// SQLite only allows a single writer, and each connection to ":memory:" is a different database
+		db.SetMaxOpenConns(1)
+	}
+	if err := migrate(db, dialect); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqlStore{db: db, dialect: dialect}, nil
+}
+
+// CreateUser will create a new user so long as none of the IDs already exist; the unique key on
+// user_ids is what enforces this
+func (s *sqlStore) CreateUser(user *domain.User, plainPass string) errors.Error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.InternalServerError("com.HailoOSS.service.login.createuser.sql", fmt.Sprintf("Failed to begin transaction: %v", err))
+	}
+
+	// can happily "replay" a create request, but cannot overwrite something that exists with different data
+	existing, err := readSQLUser(tx, user.App, user.Uid)
+	if err != nil {
+		tx.Rollback()
+		return errors.InternalServerError("com.HailoOSS.service.login.createuser.testblat", fmt.Sprintf("Failed to test for existing: %v", err))
+	}
+	if err := testBlat(existing, user, plainPass); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := writeSQLUser(tx, user); err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			return s.indexInUse(user)
+		}
+		return errors.InternalServerError("com.HailoOSS.service.login.createuser.sql", fmt.Sprintf("Create error writing to DB: %v", err))
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.InternalServerError("com.HailoOSS.service.login.createuser.sql", fmt.Sprintf("Create error committing to DB: %v", err))
+	}
+
+	return nil
+}
+
+// ReadUser returns a user, fetched by UID or secondary ID
+func (s *sqlStore) ReadUser(app domain.Application, uid string) (*domain.User, error) {
+	user, err := readSQLUser(s.db, app, uid)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	// not a UID, maybe a secondary ID
+	var realUid string
+	err = s.db.QueryRow(`SELECT uid FROM user_ids WHERE app = $1 AND id = $2`, string(app), uid).Scan(&realUid)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	return readSQLUser(s.db, app, realUid)
+}
+
+// MultiReadUser returns all users found for the supplied IDs
+func (s *sqlStore) MultiReadUser(app domain.Application, uids []string) ([]*domain.User, error) {
+	ret := make([]*domain.User, 0, len(uids))
+	for _, uid := range uids {
+		user, err := s.ReadUser(app, uid)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			ret = append(ret, user)
+		}
+	}
+	return ret, nil
+}
+
+// UpdateUser will update details of an existing user, including adding/removing secondary IDs
+func (s *sqlStore) UpdateUser(user *domain.User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+
+	existingUser, err := readSQLUser(tx, user.App, user.Uid)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Failed to test for existing: %v", err)
+	}
+	if existingUser == nil {
+		tx.Rollback()
+		return fmt.Errorf("User %v:%s does not exist - cannot update", user.App, user.Uid)
+	}
+
+	if err := writeSQLUser(tx, user); err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			return s.indexInUse(user)
+		}
+		return fmt.Errorf("Update error writing to DB: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Update error committing to DB: %v", err)
+	}
+	return nil
+}
+
+// DeleteUserIndexes removes the user's primary row and the supplied secondary indexes
+func (s *sqlStore) DeleteUserIndexes(user *domain.User, uid string, ids []domain.Id) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE app = $1 AND uid = $2`, string(user.App), uid); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Failed to delete user: %v", err)
+	}
+	for _, id := range ids {
+		if _, err := tx.Exec(`DELETE FROM user_ids WHERE app = $1 AND id = $2`, string(user.App), string(id)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Failed to delete index '%s': %v", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ReadUserList returns users ordered (newest first) by created timestamp. The lastId is the UID of
+// the last user on the previous page.
+func (s *sqlStore) ReadUserList(app domain.Application, start, end time.Time, count int, lastId string) ([]*domain.User, string, error) {
+	// start from the top unless we know where the last page finished
+	cursorCreated, cursorUid := int64(math.MaxInt64), ""
+	if lastId != "" {
+		last, err := readSQLUser(s.db, app, lastId)
+		if err != nil {
+			return nil, "", err
+		}
+		if last != nil {
+			cursorCreated, cursorUid = timeToSQL(last.Created), last.Uid
+		}
+	}
+
+	rows, err := s.db.Query(`SELECT `+sqlUserColumns+` FROM users
+		WHERE app = $1 AND created >= $2 AND created <= $3 AND (created < $4 OR (created = $4 AND uid > $5))
+		ORDER BY created DESC, uid ASC LIMIT $6`,
+		string(app), timeToSQL(start), timeToSQL(end), cursorCreated, cursorUid, count)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	defer rows.Close()
+
+	users := make([]*domain.User, 0)
+	last := ""
+	for rows.Next() {
+		user, err := scanSQLUser(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		users = append(users, user)
+		last = user.Uid
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+	}
+
+	return users, last, nil
+}
+
+// ReadSession fetches a single session by ID
+func (s *sqlStore) ReadSession(sessId string) (*domain.Session, error) {
+	return readSQLSession(s.db, `SELECT data FROM sessions WHERE id = $1`, sessId)
+}
+
+// ReadActiveSessionFor fetches a single session by auth mechanism + device type + user ID
+func (s *sqlStore) ReadActiveSessionFor(authMechanism, deviceType, userId string) (*domain.Session, error) {
+	return readSQLSession(s.db, `SELECT s.data FROM session_index i JOIN sessions s ON s.id = i.session_id
+		WHERE i.auth_mechanism = $1 AND i.device_type = $2 AND i.uid = $3`, authMechanism, deviceType, userId)
+}
+
+// WriteSession is create/update combined for sessions
+func (s *sqlStore) WriteSession(sess *domain.Session) error {
+	data, err := encodeSessionData(sess)
+	if err != nil {
+		return fmt.Errorf("Write error marshaling session: %v", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+	stmts := []sqlStmt{
+		{`INSERT INTO sessions (id, auth_mechanism, device_type, uid, data) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (id) DO UPDATE SET auth_mechanism = excluded.auth_mechanism, device_type = excluded.device_type,
+			uid = excluded.uid, data = excluded.data`,
+			[]interface{}{sess.Id, sess.Token.AuthMechanism, sess.Token.DeviceType, sess.Token.Id, data}},
+		{`INSERT INTO session_index (auth_mechanism, device_type, uid, session_id) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (auth_mechanism, device_type, uid) DO UPDATE SET session_id = excluded.session_id`,
+			[]interface{}{sess.Token.AuthMechanism, sess.Token.DeviceType, sess.Token.Id, sess.Id}},
+	}
+	if sess.Token.Id != "" {
+		stmts = append(stmts, sqlStmt{`INSERT INTO user_sessions (uid, device_type, session_id) VALUES ($1, $2, $3)
+			ON CONFLICT (uid, session_id) DO UPDATE SET device_type = excluded.device_type`,
+			[]interface{}{sess.Token.Id, sess.Token.DeviceType, sess.Id}})
+	}
+	if err := execAll(tx, stmts); err != nil {
+		return fmt.Errorf("Write error writing to DB: %v", err)
+	}
+	return nil
+}
+
+// DeleteSession will remove all knowledge of a session. session_index only loses its row if it still
+// points at this session.
+func (s *sqlStore) DeleteSession(sessId string) error {
+	sess, err := s.ReadSession(sessId)
+	if err != nil {
+		return fmt.Errorf("Delete session failed - error reading existing session: %v", err)
+	}
+	if sess == nil {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+	if err := execAll(tx, []sqlStmt{
+		{`DELETE FROM sessions WHERE id = $1`, []interface{}{sessId}},
+		{`DELETE FROM session_index WHERE session_id = $1`, []interface{}{sessId}},
+		{`DELETE FROM user_sessions WHERE uid = $1 AND session_id = $2`, []interface{}{sess.Token.Id, sessId}},
+	}); err != nil {
+		return fmt.Errorf("Write error deleting from DB: %v", err)
+	}
+	return nil
+}
+
+// ReadActiveSessionIdsFor retrieves all active session IDs (mapped to their device type) for a user
+func (s *sqlStore) ReadActiveSessionIdsFor(userId string) (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT device_type, session_id FROM user_sessions WHERE uid = $1`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessionIds := make(map[string]string)
+	for rows.Next() {
+		var deviceType, sessId string
+		if err := rows.Scan(&deviceType, &sessId); err != nil {
+			return nil, err
+		}
+		sessionIds[sessId] = deviceType
+	}
+	return sessionIds, rows.Err()
+}
+
+// ReadEndpointAuth grabs a list of all authorised services that can make requests to the supplied service
+func (s *sqlStore) ReadEndpointAuth(service string) ([]*domain.EndpointAuth, error) {
+	rows, err := s.db.Query(`SELECT endpoint, allowed_service, role FROM endpoint_auths WHERE service = $1
+		ORDER BY endpoint, allowed_service LIMIT $2`, service, maxAuthedServices)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	defer rows.Close()
+
+	ret := make([]*domain.EndpointAuth, 0)
+	for rows.Next() {
+		epa := &domain.EndpointAuth{ServiceName: service}
+		if err := rows.Scan(&epa.EndpointName, &epa.AllowedService, &epa.Role); err != nil {
+			return nil, fmt.Errorf("Failed to read from DB: %v", err)
+		}
+		ret = append(ret, epa)
+	}
+	return ret, rows.Err()
+}
+
+// WriteEndpointAuths defines new rules that allow some service to call some endpoint
+func (s *sqlStore) WriteEndpointAuths(epas []*domain.EndpointAuth) error {
+	if len(epas) == 0 {
+		return fmt.Errorf("No rules to write")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+	for _, epa := range epas {
+		if _, err := tx.Exec(`INSERT INTO endpoint_auths (service, endpoint, allowed_service, role) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (service, endpoint, allowed_service) DO UPDATE SET role = excluded.role`,
+			epa.ServiceName, epa.EndpointName, epa.AllowedService, epa.Role); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Failed to write to DB: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteEndpointAuths will revoke these rules
+func (s *sqlStore) DeleteEndpointAuths(epas []*domain.EndpointAuth) error {
+	if len(epas) == 0 {
+		return fmt.Errorf("No rules to delete")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+	for _, epa := range epas {
+		if _, err := tx.Exec(`DELETE FROM endpoint_auths WHERE service = $1 AND endpoint = $2 AND allowed_service = $3`,
+			epa.ServiceName, epa.EndpointName, epa.AllowedService); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Failed to delete from DB: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// WriteLogin will record details of a user login
+func (s *sqlStore) WriteLogin(login *domain.Login) error {
+	meta, err := json.Marshal(login.Meta)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal login meta: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO logins (app, uid, logged_in, auth_mechanism, device_type, meta) VALUES ($1, $2, $3, $4, $5, $6)`,
+		string(login.App), login.Uid, timeToSQL(login.LoggedIn), login.AuthMechanism, login.DeviceType, string(meta)); err != nil {
+		return fmt.Errorf("Create error writing to DB: %v", err)
+	}
+	return nil
+}
+
+// ReadUserLogins will return a list of user logins (newest first) for a single user, within a time
+// range. The lastId is the sequence number of the last login on the previous page.
+func (s *sqlStore) ReadUserLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.Login, string, error) {
+	cursorLoggedIn, cursorSeq := int64(math.MaxInt64), int64(math.MaxInt64)
+	if lastId != "" {
+		err := s.db.QueryRow(`SELECT logged_in, seq FROM logins WHERE seq = $1`, lastId).Scan(&cursorLoggedIn, &cursorSeq)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+		}
+	}
+
+	rows, err := s.db.Query(`SELECT seq, logged_in, auth_mechanism, device_type, meta FROM logins
+		WHERE app = $1 AND uid = $2 AND logged_in >= $3 AND logged_in <= $4 AND (logged_in < $5 OR (logged_in = $5 AND seq < $6))
+		ORDER BY logged_in DESC, seq DESC LIMIT $7`,
+		string(app), uid, timeToSQL(start), timeToSQL(end), cursorLoggedIn, cursorSeq, count)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	defer rows.Close()
+
+	logins := make([]*domain.Login, 0)
+	last := ""
+	for rows.Next() {
+		var seq, loggedIn int64
+		var meta string
+		login := &domain.Login{App: app, Uid: uid}
+		if err := rows.Scan(&seq, &loggedIn, &login.AuthMechanism, &login.DeviceType, &meta); err != nil {
+			return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+		}
+		login.LoggedIn = sqlToTime(loggedIn)
+		if err := json.Unmarshal([]byte(meta), &login.Meta); err != nil {
+			return nil, "", fmt.Errorf("Failed to unmarshal login meta: %v", err)
+		}
+		logins = append(logins, login)
+		last = fmt.Sprintf("%d", seq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+	}
+
+	return logins, last, nil
+}
+
+// WriteFailedLogin will record details of a failed authentication attempt
+func (s *sqlStore) WriteFailedLogin(failed *domain.FailedLogin) error {
+	meta, err := json.Marshal(failed.Meta)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal failed login meta: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO failed_logins (app, uid, username, failed, reason, auth_mechanism, device_type, meta) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		string(failed.App), failed.Uid, failed.Username, timeToSQL(failed.Failed), failed.Reason, failed.AuthMechanism, failed.DeviceType, string(meta)); err != nil {
+		return fmt.Errorf("Create error writing to DB: %v", err)
+	}
+	return nil
+}
+
+// ReadUserFailedLogins will return a list of failed logins (newest first) for a single user, within a time
+// range, paginated as for ReadUserLogins
+func (s *sqlStore) ReadUserFailedLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.FailedLogin, string, error) {
+	cursorFailed, cursorSeq := int64(math.MaxInt64), int64(math.MaxInt64)
+	if lastId != "" {
+		err := s.db.QueryRow(`SELECT failed, seq FROM failed_logins WHERE seq = $1`, lastId).Scan(&cursorFailed, &cursorSeq)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+		}
+	}
+
+	rows, err := s.db.Query(`SELECT seq, username, failed, reason, auth_mechanism, device_type, meta FROM failed_logins
+		WHERE app = $1 AND uid = $2 AND failed >= $3 AND failed <= $4 AND (failed < $5 OR (failed = $5 AND seq < $6))
+		ORDER BY failed DESC, seq DESC LIMIT $7`,
+		string(app), uid, timeToSQL(start), timeToSQL(end), cursorFailed, cursorSeq, count)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	defer rows.Close()
+
+	failures := make([]*domain.FailedLogin, 0)
+	last := ""
+	for rows.Next() {
+		var seq, failedAt int64
+		var meta string
+		failed := &domain.FailedLogin{App: app, Uid: uid}
+		if err := rows.Scan(&seq, &failed.Username, &failedAt, &failed.Reason, &failed.AuthMechanism, &failed.DeviceType, &meta); err != nil {
+			return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+		}
+		failed.Failed = sqlToTime(failedAt)
+		if err := json.Unmarshal([]byte(meta), &failed.Meta); err != nil {
+			return nil, "", fmt.Errorf("Failed to unmarshal failed login meta: %v", err)
+		}
+		failures = append(failures, failed)
+		last = fmt.Sprintf("%d", seq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("Failed to read from DB: %v", err)
+	}
+
+	return failures, last, nil
+}
+
+// ReadLockout fetches a user's recent failed logins, ignoring those that have expired
+func (s *sqlStore) ReadLockout(app domain.Application, uid string) (*domain.Lockout, error) {
+	lockout := &domain.Lockout{App: app, Uid: uid}
+	var firstFailure, lastFailure, lockedUntil int64
+	err := s.db.QueryRow(`SELECT failures, first_failure, last_failure, locked_until FROM lockouts WHERE app = $1 AND uid = $2`,
+		string(app), uid).Scan(&lockout.Failures, &firstFailure, &lastFailure, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	lockout.FirstFailure, lockout.LastFailure, lockout.LockedUntil = sqlToTime(firstFailure), sqlToTime(lastFailure), sqlToTime(lockedUntil)
+	return liveLockout(lockout), nil
+}
+
+// WriteLockout stores a user's recent failed logins
+func (s *sqlStore) WriteLockout(lockout *domain.Lockout) error {
+	_, err := s.db.Exec(`INSERT INTO lockouts (app, uid, failures, first_failure, last_failure, locked_until) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (app, uid) DO UPDATE SET failures = excluded.failures, first_failure = excluded.first_failure,
+		last_failure = excluded.last_failure, locked_until = excluded.locked_until`,
+		string(lockout.App), lockout.Uid, lockout.Failures, timeToSQL(lockout.FirstFailure), timeToSQL(lockout.LastFailure),
+		timeToSQL(lockout.LockedUntil))
+	if err != nil {
+		return fmt.Errorf("Write error writing to DB: %v", err)
+	}
+	return nil
+}
+
+// DeleteLockout forgets a user's recent failed logins
+func (s *sqlStore) DeleteLockout(app domain.Application, uid string) error {
+	if _, err := s.db.Exec(`DELETE FROM lockouts WHERE app = $1 AND uid = $2`, string(app), uid); err != nil {
+		return fmt.Errorf("Write error deleting from DB: %v", err)
+	}
+	return nil
+}
+
+// TakeToken takes a token from the named bucket, within a transaction that locks its row
+func (s *sqlStore) TakeToken(key string, limit domain.RateLimit) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	bucket := &domain.TokenBucket{}
+	var updated int64
+	err = tx.QueryRow(`SELECT tokens, updated FROM rate_limits WHERE name = $1`+s.dialect.forUpdate, key).Scan(&bucket.Tokens, &updated)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	bucket.Updated = sqlToTime(updated)
+
+	ok := bucket.Take(limit, time.Now())
+	if _, err := tx.Exec(`INSERT INTO rate_limits (name, tokens, updated) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET tokens = excluded.tokens, updated = excluded.updated`,
+		key, bucket.Tokens, timeToSQL(bucket.Updated)); err != nil {
+		return false, fmt.Errorf("Write error writing to DB: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("Failed to commit: %v", err)
+	}
+	return ok, nil
+}
+
+// ReadRefreshToken fetches a refresh token by its hash
+func (s *sqlStore) ReadRefreshToken(hash string) (*domain.RefreshToken, error) {
+	rt := &domain.RefreshToken{Hash: hash}
+	var created, expires, used int64
+	err := s.db.QueryRow(`SELECT session_id, created, expires, used FROM refresh_tokens WHERE hash = $1`, hash).Scan(
+		&rt.SessionId, &created, &expires, &used)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	rt.Created, rt.Expires, rt.Used = sqlToTime(created), sqlToTime(expires), sqlToTime(used)
+	return rt, nil
+}
+
+// WriteRefreshToken stores a refresh token
+func (s *sqlStore) WriteRefreshToken(rt *domain.RefreshToken) error {
+	_, err := s.db.Exec(`INSERT INTO refresh_tokens (hash, session_id, created, expires, used) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (hash) DO UPDATE SET session_id = excluded.session_id, created = excluded.created,
+		expires = excluded.expires, used = excluded.used`,
+		rt.Hash, rt.SessionId, timeToSQL(rt.Created), timeToSQL(rt.Expires), timeToSQL(rt.Used))
+	if err != nil {
+		return fmt.Errorf("Write error writing to DB: %v", err)
+	}
+	return nil
+}
+
+// ReadPasswordResetToken fetches a password reset token by its hash
+func (s *sqlStore) ReadPasswordResetToken(hash string) (*domain.PasswordResetToken, error) {
+	prt := &domain.PasswordResetToken{Hash: hash}
+	var app string
+	var created, expires, passwordChanged, used int64
+	err := s.db.QueryRow(`SELECT app, uid, created, expires, password_changed, used FROM password_reset_tokens
+		WHERE hash = $1`, hash).Scan(&app, &prt.Uid, &created, &expires, &passwordChanged, &used)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	prt.App = domain.Application(app)
+	prt.Created, prt.Expires, prt.Used = sqlToTime(created), sqlToTime(expires), sqlToTime(used)
+	prt.PasswordChanged = sqlToTime(passwordChanged)
+	return prt, nil
+}
+
+// WritePasswordResetToken stores a password reset token
+func (s *sqlStore) WritePasswordResetToken(prt *domain.PasswordResetToken) error {
+	_, err := s.db.Exec(`INSERT INTO password_reset_tokens (hash, app, uid, created, expires, password_changed, used)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (hash) DO UPDATE SET app = excluded.app, uid = excluded.uid, created = excluded.created,
+		expires = excluded.expires, password_changed = excluded.password_changed, used = excluded.used`,
+		prt.Hash, string(prt.App), prt.Uid, timeToSQL(prt.Created), timeToSQL(prt.Expires),
+		timeToSQL(prt.PasswordChanged), timeToSQL(prt.Used))
+	if err != nil {
+		return fmt.Errorf("Write error writing to DB: %v", err)
+	}
+	return nil
+}
+
+// scanSessions returns sessions in ID order
+func (s *sqlStore) scanSessions(after string, count int) ([]*sweptSession, error) {
+	rows, err := s.db.Query(`SELECT id, data FROM sessions WHERE id > $1 ORDER BY id LIMIT $2`, after, count)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	defer rows.Close()
+
+	ret := make([]*sweptSession, 0)
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("Failed to read from DB: %v", err)
+		}
+		sess, err := decodeSessionData(data)
+		if err != nil {
+			sess = nil
+		}
+		ret = append(ret, &sweptSession{key: id, sess: sess})
+	}
+	return ret, rows.Err()
+}
+
+// scanUserSessions returns users' session ID -> device type maps in UID order
+func (s *sqlStore) scanUserSessions(after string, count int) ([]*sweptUserSessions, error) {
+	rows, err := s.db.Query(`SELECT uid, device_type, session_id FROM user_sessions WHERE uid IN (
+		SELECT DISTINCT uid FROM user_sessions WHERE uid > $1 ORDER BY uid LIMIT $2
+	) ORDER BY uid`, after, count)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	defer rows.Close()
+
+	ret := make([]*sweptUserSessions, 0)
+	for rows.Next() {
+		var uid, deviceType, sessId string
+		if err := rows.Scan(&uid, &deviceType, &sessId); err != nil {
+			return nil, fmt.Errorf("Failed to read from DB: %v", err)
+		}
+		if len(ret) == 0 || ret[len(ret)-1].uid != uid {
+			ret = append(ret, &sweptUserSessions{uid: uid, sessionIds: make(map[string]string)})
+		}
+		ret[len(ret)-1].sessionIds[sessId] = deviceType
+	}
+	return ret, rows.Err()
+}
+
+// deleteUserSession removes a single user_sessions entry
+func (s *sqlStore) deleteUserSession(uid, deviceType, sessId string) error {
+	_, err := s.db.Exec(`DELETE FROM user_sessions WHERE uid = $1 AND session_id = $2`, uid, sessId)
+	return err
+}
+
+func (s *sqlStore) readCheckpoint(name string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM checkpoints WHERE name = $1`, name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *sqlStore) writeCheckpoint(name string, data []byte) error {
+	_, err := s.db.Exec(`INSERT INTO checkpoints (name, data) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET data = excluded.data`, name, data)
+	return err
+}
+
+// indexInUse builds the error for a secondary ID clash, naming the user that got there first
+func (s *sqlStore) indexInUse(user *domain.User) errors.Error {
+	for _, id := range user.Ids {
+		var other string
+		err := s.db.QueryRow(`SELECT uid FROM user_ids WHERE app = $1 AND id = $2`, string(user.App), string(id)).Scan(&other)
+		if err == nil && other != user.Uid {
+			return errors.BadRequest("com.HailoOSS.service.login.createuser.indexinuse", fmt.Sprintf("Index '%s' is already linked to another user '%s'", id, other))
+		}
+	}
+	return errors.BadRequest("com.HailoOSS.service.login.createuser.indexinuse", "Index is already linked to another user")
+}
+
+// readSQLUser fetches a user by UID only, returning nil if not found
+func readSQLUser(q sqlQuerier, app domain.Application, uid string) (*domain.User, error) {
+	user, err := scanSQLUser(q.QueryRow(`SELECT `+sqlUserColumns+` FROM users WHERE app = $1 AND uid = $2`, string(app), uid))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+// writeSQLUser upserts a user and replaces their secondary IDs
+func writeSQLUser(q sqlQuerier, user *domain.User) error {
+	stored := marshalUser(user)
+	_, err := q.Exec(`INSERT INTO users (`+sqlUserColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (app, uid) DO UPDATE SET ids = excluded.ids, created = excluded.created, roles = excluded.roles,
+		password_history = excluded.password_history, password = excluded.password, password_change = excluded.password_change,
+		account_expiration_date = excluded.account_expiration_date, status = excluded.status,
+		totp_secret = excluded.totp_secret, totp_pending_secret = excluded.totp_pending_secret, recovery_codes = excluded.recovery_codes,
+		totp_last_step = excluded.totp_last_step`,
+		stored.App, stored.Uid, string(stored.Ids), timeToSQL(stored.Created), string(stored.Roles), string(stored.PasswordHistory),
+		stored.Password, timeToSQL(stored.PasswordChange), stored.AccountExpirationDate, stored.Status,
+		stored.TotpSecret, stored.TotpPendingSecret, string(stored.RecoveryCodes), stored.TotpLastStep)
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.Exec(`DELETE FROM user_ids WHERE app = $1 AND uid = $2`, stored.App, stored.Uid); err != nil {
+		return err
+	}
+	for _, id := range user.Ids {
+		if _, err := q.Exec(`INSERT INTO user_ids (app, id, uid) VALUES ($1, $2, $3)`, stored.App, string(id), stored.Uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlStmt is a single statement plus its arguments
+type sqlStmt struct {
+	query string
+	args  []interface{}
+}
+
+// execAll runs statements in order then commits, rolling back if any fail
+func execAll(tx *sql.Tx, stmts []sqlStmt) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// sqlScanner is satisfied by both *sql.Row and *sql.Rows
+type sqlScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSQLUser(row sqlScanner) (*domain.User, error) {
+	stored := &storedUser{}
+	var created, passwordChange int64
+	var ids, roles, passwordHistory, recoveryCodes string
+	err := row.Scan(&stored.App, &stored.Uid, &ids, &created, &roles, &passwordHistory, &stored.Password,
+		&passwordChange, &stored.AccountExpirationDate, &stored.Status, &stored.TotpSecret, &stored.TotpPendingSecret,
+		&recoveryCodes, &stored.TotpLastStep)
+	if err != nil {
+		return nil, err
+	}
+	stored.Ids, stored.Roles, stored.PasswordHistory = []byte(ids), []byte(roles), []byte(passwordHistory)
+	stored.RecoveryCodes = []byte(recoveryCodes)
+	stored.Created, stored.PasswordChange = sqlToTime(created), sqlToTime(passwordChange)
+	return fromStoredUser(stored), nil
+}
+
+func readSQLSession(q sqlQuerier, query string, args ...interface{}) (*domain.Session, error) {
+	var data []byte
+	err := q.QueryRow(query, args...).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read from DB: %v", err)
+	}
+	sess, err := decodeSessionData(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal session: %v", err)
+	}
+	return sess, nil
+}
+
+// isUniqueViolation spots a unique key violation without needing to import the driver, since both
+// PostgreSQL ("duplicate key value violates unique constraint") and SQLite ("UNIQUE constraint
+// failed") say so
+func isUniqueViolation(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "unique constraint")
+}
+
+// timeToSQL turns a time into UNIX nanoseconds where zero time -> 0
+func timeToSQL(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// sqlToTime turns UNIX nanoseconds into a time.Time where 0 -> zero time
+func sqlToTime(t int64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, t)
+}
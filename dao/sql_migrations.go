@@ -0,0 +1,297 @@
+package dao
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	log "github.com/cihub/seelog"
+)
+
+/*
+ Tables (all timestamps are UNIX nanoseconds, 0 meaning "not set"):
+  users          [app, uid] -> created + everything else about the user
+  user_ids       [app, id] -> uid; the unique key is what stops two users sharing a secondary ID
+  sessions       [id] -> auth mechanism, device type, uid + the same JSON we store in C*
+  session_index  [auth mech, device type, uid] -> session ID, the most recent session for a device type
+  user_sessions  [uid, session ID] -> device type
+  logins         [seq] -> app, uid, logged in + meta; seq is the pagination ID
+  failed_logins  [seq] -> app, uid, username, failed, reason + meta; as for logins
+  endpoint_auths [service, endpoint, allowed service] -> role
+  checkpoints    [name] -> progress of a background job
+  lockouts       [app, uid] -> recent failed logins
+  rate_limits    [name] -> token bucket
+  refresh_tokens [hash] -> session ID, created, expires, used
+  password_reset_tokens [hash] -> app, uid, created, expires, user's password change when issued, used
+*/
+
+// sqlDialect holds the few bits of DDL that differ between databases
+type sqlDialect struct {
+	// serial is the column definition for an auto-incrementing primary key
+	serial string
+	// blob is the column type for raw bytes
+	blob string
+	// forUpdate is appended to a SELECT to lock the rows read until the end of the transaction; SQLite
+	// doesn't need it as it only has a single writer
+	forUpdate string
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"postgres": {serial: "BIGSERIAL PRIMARY KEY", blob: "BYTEA", forUpdate: " FOR UPDATE"},
+	"sqlite3":  {serial: "INTEGER PRIMARY KEY AUTOINCREMENT", blob: "BLOB"},
+}
+
+// sqlMigration is a single, numbered schema change. Once released, a migration must never be edited;
+// add a new one instead.
+type sqlMigration struct {
+	version     int
+	description string
+	stmts       []string
+}
+
+// sqlMigrations are applied in order; "{serial}" and "{blob}" are replaced per dialect
+var sqlMigrations = []sqlMigration{
+	{
+		version:     1,
+		description: "users and secondary IDs",
+		stmts: []string{
+			`CREATE TABLE users (
+				app TEXT NOT NULL,
+				uid TEXT NOT NULL,
+				ids TEXT NOT NULL,
+				created BIGINT NOT NULL,
+				roles TEXT NOT NULL,
+				password_history TEXT NOT NULL,
+				password {blob},
+				password_change BIGINT NOT NULL,
+				account_expiration_date TEXT NOT NULL,
+				status TEXT NOT NULL,
+				PRIMARY KEY (app, uid)
+			)`,
+			`CREATE INDEX users_created ON users (app, created)`,
+			`CREATE TABLE user_ids (
+				app TEXT NOT NULL,
+				id TEXT NOT NULL,
+				uid TEXT NOT NULL,
+				PRIMARY KEY (app, id)
+			)`,
+			`CREATE INDEX user_ids_uid ON user_ids (app, uid)`,
+		},
+	},
+	{
+		version:     2,
+		description: "sessions",
+		stmts: []string{
+			`CREATE TABLE sessions (
+				id TEXT NOT NULL PRIMARY KEY,
+				auth_mechanism TEXT NOT NULL,
+				device_type TEXT NOT NULL,
+				uid TEXT NOT NULL,
+				data {blob} NOT NULL
+			)`,
+			`CREATE TABLE session_index (
+				auth_mechanism TEXT NOT NULL,
+				device_type TEXT NOT NULL,
+				uid TEXT NOT NULL,
+				session_id TEXT NOT NULL,
+				PRIMARY KEY (auth_mechanism, device_type, uid)
+			)`,
+			`CREATE INDEX session_index_session_id ON session_index (session_id)`,
+			`CREATE TABLE user_sessions (
+				uid TEXT NOT NULL,
+				device_type TEXT NOT NULL,
+				session_id TEXT NOT NULL,
+				PRIMARY KEY (uid, device_type)
+			)`,
+		},
+	},
+	{
+		version:     3,
+		description: "logins",
+		stmts: []string{
+			`CREATE TABLE logins (
+				seq {serial},
+				app TEXT NOT NULL,
+				uid TEXT NOT NULL,
+				logged_in BIGINT NOT NULL,
+				auth_mechanism TEXT NOT NULL,
+				device_type TEXT NOT NULL,
+				meta TEXT NOT NULL
+			)`,
+			`CREATE INDEX logins_user ON logins (app, uid, logged_in)`,
+		},
+	},
+	{
+		version:     4,
+		description: "endpoint auths",
+		stmts: []string{
+			`CREATE TABLE endpoint_auths (
+				service TEXT NOT NULL,
+				endpoint TEXT NOT NULL,
+				allowed_service TEXT NOT NULL,
+				role TEXT NOT NULL,
+				PRIMARY KEY (service, endpoint, allowed_service)
+			)`,
+		},
+	},
+	{
+		version:     5,
+		description: "checkpoints",
+		stmts: []string{
+			`CREATE TABLE checkpoints (
+				name TEXT NOT NULL PRIMARY KEY,
+				data {blob} NOT NULL
+			)`,
+		},
+	},
+	{
+		version:     6,
+		description: "TOTP enrolment",
+		stmts: []string{
+			`ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE users ADD COLUMN totp_pending_secret TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     7,
+		description: "MFA recovery codes",
+		stmts: []string{
+			`ALTER TABLE users ADD COLUMN recovery_codes TEXT NOT NULL DEFAULT '[]'`,
+		},
+	},
+	{
+		version:     8,
+		description: "lockouts",
+		stmts: []string{
+			`CREATE TABLE lockouts (
+				app TEXT NOT NULL,
+				uid TEXT NOT NULL,
+				failures INTEGER NOT NULL,
+				first_failure BIGINT NOT NULL,
+				last_failure BIGINT NOT NULL,
+				locked_until BIGINT NOT NULL,
+				PRIMARY KEY (app, uid)
+			)`,
+		},
+	},
+	{
+		version:     9,
+		description: "rate limits",
+		stmts: []string{
+			`CREATE TABLE rate_limits (
+				name TEXT NOT NULL PRIMARY KEY,
+				tokens DOUBLE PRECISION NOT NULL,
+				updated BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		version:     10,
+		description: "failed logins",
+		stmts: []string{
+			`CREATE TABLE failed_logins (
+				seq {serial},
+				app TEXT NOT NULL,
+				uid TEXT NOT NULL,
+				username TEXT NOT NULL,
+				failed BIGINT NOT NULL,
+				reason TEXT NOT NULL,
+				auth_mechanism TEXT NOT NULL,
+				device_type TEXT NOT NULL,
+				meta TEXT NOT NULL
+			)`,
+			`CREATE INDEX failed_logins_user ON failed_logins (app, uid, failed)`,
+		},
+	},
+	{
+		version:     11,
+		description: "refresh tokens",
+		stmts: []string{
+			`CREATE TABLE refresh_tokens (
+				hash TEXT NOT NULL PRIMARY KEY,
+				session_id TEXT NOT NULL,
+				created BIGINT NOT NULL,
+				expires BIGINT NOT NULL,
+				used BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		version:     12,
+		description: "multiple sessions per device type",
+		stmts: []string{
+			`CREATE TABLE user_sessions_new (
+				uid TEXT NOT NULL,
+				device_type TEXT NOT NULL,
+				session_id TEXT NOT NULL,
+				PRIMARY KEY (uid, session_id)
+			)`,
+			`INSERT INTO user_sessions_new (uid, device_type, session_id)
+				SELECT uid, device_type, session_id FROM user_sessions`,
+			`DROP TABLE user_sessions`,
+			`ALTER TABLE user_sessions_new RENAME TO user_sessions`,
+		},
+	},
+	{
+		version:     13,
+		description: "password reset tokens",
+		stmts: []string{
+			`CREATE TABLE password_reset_tokens (
+				hash TEXT NOT NULL PRIMARY KEY,
+				app TEXT NOT NULL,
+				uid TEXT NOT NULL,
+				created BIGINT NOT NULL,
+				expires BIGINT NOT NULL,
+				password_changed BIGINT NOT NULL,
+				used BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		version:     14,
+		description: "TOTP replay protection",
+		stmts: []string{
+			`ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+}
+
+// migrate brings the schema up to date, applying each outstanding migration in its own transaction
+func migrate(db *sql.DB, dialect sqlDialect) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("Failed to create schema_migrations: %v", err)
+	}
+
+	current := 0
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("Failed to read schema version: %v", err)
+	}
+
+	replacer := strings.NewReplacer("{serial}", dialect.serial, "{blob}", dialect.blob)
+	for _, m := range sqlMigrations {
+		if m.version <= current {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("Failed to begin migration %d: %v", m.version, err)
+		}
+		for _, stmt := range m.stmts {
+			if _, err := tx.Exec(replacer.Replace(stmt)); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("Migration %d (%s) failed: %v", m.version, m.description, err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Failed to record migration %d: %v", m.version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("Failed to commit migration %d: %v", m.version, err)
+		}
+		log.Infof("[DAO] Applied SQL migration %d: %s", m.version, m.description)
+	}
+
+	return nil
+}
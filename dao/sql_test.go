@@ -0,0 +1,93 @@
+package dao
+
+import (
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/HailoOSS/login-service/domain"
+)
+
+func newSQLiteStore(t *testing.T) Store {
+	s, err := NewSQLStore("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create SQLite store: %v", err)
+	}
+	return s
+}
+
+func TestSQLUserIndexes(t *testing.T) {
+	testStoreUserIndexes(t, newSQLiteStore(t))
+}
+
+func TestSQLUserMfa(t *testing.T) {
+	testStoreUserMfa(t, newSQLiteStore(t))
+}
+
+func TestSQLLockouts(t *testing.T) {
+	testStoreLockouts(t, newSQLiteStore(t))
+}
+
+func TestSQLTakeToken(t *testing.T) {
+	testStoreTakeToken(t, newSQLiteStore(t))
+}
+
+func TestSQLReadUserList(t *testing.T) {
+	testStoreReadUserList(t, newSQLiteStore(t))
+}
+
+func TestSQLSessions(t *testing.T) {
+	testStoreSessions(t, newSQLiteStore(t))
+}
+
+func TestSQLMultipleSessions(t *testing.T) {
+	testStoreMultipleSessions(t, newSQLiteStore(t))
+}
+
+func TestSQLReadUserLogins(t *testing.T) {
+	testStoreReadUserLogins(t, newSQLiteStore(t))
+}
+
+func TestSQLReadUserFailedLogins(t *testing.T) {
+	testStoreReadUserFailedLogins(t, newSQLiteStore(t))
+}
+
+func TestSQLActiveKeyId(t *testing.T) {
+	testStoreActiveKeyId(t, newSQLiteStore(t))
+}
+
+func TestSQLRefreshTokens(t *testing.T) {
+	testStoreRefreshTokens(t, newSQLiteStore(t))
+}
+
+func TestSQLPasswordResetTokens(t *testing.T) {
+	testStorePasswordResetTokens(t, newSQLiteStore(t))
+}
+
+func TestSQLEndpointAuths(t *testing.T) {
+	s := newSQLiteStore(t)
+
+	epas := []*domain.EndpointAuth{
+		{ServiceName: "com.HailoOSS.service.foo", EndpointName: "bar", AllowedService: "com.HailoOSS.service.baz", Role: "ADMIN"},
+		{ServiceName: "com.HailoOSS.service.foo", EndpointName: "bar", AllowedService: "com.HailoOSS.service.qux", Role: "ADMIN"},
+	}
+	assert.NoError(t, s.WriteEndpointAuths(epas))
+	read, err := s.ReadEndpointAuth("com.HailoOSS.service.foo")
+	assert.NoError(t, err)
+	assert.Equal(t, epas, read)
+
+	assert.NoError(t, s.DeleteEndpointAuths(epas[:1]))
+	read, err = s.ReadEndpointAuth("com.HailoOSS.service.foo")
+	assert.NoError(t, err)
+	assert.Equal(t, epas[1:], read)
+}
+
+func TestSQLMigrateIsIdempotent(t *testing.T) {
+	s := newSQLiteStore(t).(*sqlStore)
+	assert.NoError(t, migrate(s.db, sqlDialects["sqlite3"]))
+
+	var version int
+	assert.NoError(t, s.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	assert.Equal(t, sqlMigrations[len(sqlMigrations)-1].version, version)
+}
@@ -0,0 +1,235 @@
+package dao
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/service/config"
+)
+
+const (
+	// DefaultStore is the name of the storage backend we use unless told otherwise
+	DefaultStore = "cassandra"
+)
+
+// Store is a storage backend for everything the login service persists: users (plus their secondary
+// ID indexes and created-time index), sessions (plus the per-user device map), endpoint auths and the
+// login time series. LDAP users are not stored, so are dealt with before we ever reach the Store.
+type Store interface {
+	// CreateUser stores a new user, so long as none of its IDs are in use by another user
+	CreateUser(user *domain.User, plainPass string) errors.Error
+	// ReadUser fetches a single user by UID or secondary ID, returning nil if not found
+	ReadUser(app domain.Application, uid string) (*domain.User, error)
+	// MultiReadUser fetches many users; those not found are omitted from the result
+	MultiReadUser(app domain.Application, uids []string) ([]*domain.User, error)
+	// UpdateUser updates an existing user, including adding/removing secondary ID indexes
+	UpdateUser(user *domain.User) error
+	// DeleteUserIndexes removes a user's primary row and the supplied secondary ID indexes
+	DeleteUserIndexes(user *domain.User, uid string, ids []domain.Id) error
+	// ReadUserList returns users ordered (newest first) by created timestamp, paginated via lastId
+	ReadUserList(app domain.Application, start, end time.Time, count int, lastId string) ([]*domain.User, string, error)
+
+	// ReadSession fetches a single session by ID, returning nil if not found
+	ReadSession(sessId string) (*domain.Session, error)
+	// ReadActiveSessionFor fetches the most recently written session for auth mechanism + device type + user ID
+	ReadActiveSessionFor(authMechanism, deviceType, userId string) (*domain.Session, error)
+	// WriteSession is create/update combined for sessions
+	WriteSession(sess *domain.Session) error
+	// DeleteSession removes all knowledge of a session
+	DeleteSession(sessId string) error
+	// ReadActiveSessionIdsFor returns all active session IDs for a user, mapped to their device type
+	ReadActiveSessionIdsFor(userId string) (map[string]string, error)
+
+	// ReadEndpointAuth returns all rules granting access to the supplied service
+	ReadEndpointAuth(service string) ([]*domain.EndpointAuth, error)
+	// WriteEndpointAuths stores new rules
+	WriteEndpointAuths(epas []*domain.EndpointAuth) error
+	// DeleteEndpointAuths removes rules
+	DeleteEndpointAuths(epas []*domain.EndpointAuth) error
+
+	// WriteLogin records a single login
+	WriteLogin(login *domain.Login) error
+	// ReadUserLogins returns a user's logins (newest first) within a time range, paginated via lastId
+	ReadUserLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.Login, string, error)
+	// WriteFailedLogin records a single failed authentication attempt
+	WriteFailedLogin(failed *domain.FailedLogin) error
+	// ReadUserFailedLogins returns a user's failed logins (newest first) within a time range, paginated via lastId
+	ReadUserFailedLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.FailedLogin, string, error)
+
+	// ReadLockout fetches a user's recent failed logins, returning nil if there are none (or they have expired)
+	ReadLockout(app domain.Application, uid string) (*domain.Lockout, error)
+	// WriteLockout stores a user's recent failed logins, until lockout.Expires()
+	WriteLockout(lockout *domain.Lockout) error
+	// DeleteLockout forgets a user's recent failed logins, unlocking them
+	DeleteLockout(app domain.Application, uid string) error
+
+	// TakeToken takes a token from the named bucket, returning false if it is empty. It must be atomic
+	// across all instances of the service.
+	TakeToken(key string, limit domain.RateLimit) (bool, error)
+
+	// ReadRefreshToken fetches a refresh token by its hash, returning nil if not found
+	ReadRefreshToken(hash string) (*domain.RefreshToken, error)
+	// WriteRefreshToken is create/update combined for refresh tokens, which are kept until they expire
+	WriteRefreshToken(rt *domain.RefreshToken) error
+
+	// ReadPasswordResetToken fetches a password reset token by its hash, returning nil if not found
+	ReadPasswordResetToken(hash string) (*domain.PasswordResetToken, error)
+	// WritePasswordResetToken is create/update combined for password reset tokens, which are kept until they
+	// expire
+	WritePasswordResetToken(prt *domain.PasswordResetToken) error
+}
+
+var (
+	defaultStore Store = newCassandraStore()
+
+	// storeFactories are the storage backends that can be selected at startup, by name
+	storeFactories = map[string]func() (Store, error){
+		"cassandra": func() (Store, error) { return newCassandraStore(), nil },
+		"memory":    func() (Store, error) { return NewMemoryStore(), nil },
+		"postgres":  func() (Store, error) { return NewSQLStore("postgres", sqlDSN()) },
+		"sqlite3":   func() (Store, error) { return NewSQLStore("sqlite3", sqlDSN()) },
+	}
+)
+
+// sqlDSN is the data source name used by the SQL backends
+func sqlDSN() string {
+	return config.AtPath("hailo", "service", "login", "sql", "dsn").AsString("")
+}
+
+// SelectStore switches all DAO operations to use the named storage backend
+func SelectStore(name string) error {
+	factory, ok := storeFactories[name]
+	if !ok {
+		return fmt.Errorf("Unknown storage backend '%s'", name)
+	}
+	s, err := factory()
+	if err != nil {
+		return fmt.Errorf("Failed to initialise storage backend '%s': %v", name, err)
+	}
+	log.Infof("[DAO] Using storage backend '%s'", name)
+	SetStore(s)
+	return nil
+}
+
+// SetStore switches all DAO operations to use the supplied Store
+func SetStore(s Store) {
+	defaultStore = s
+}
+
+// ReadSession wraps defaultStore.ReadSession, treating dead sessions as not found
+func ReadSession(sessId string) (*domain.Session, error) {
+	sess, err := defaultStore.ReadSession(sessId)
+	return liveSession(sess), err
+}
+
+// ReadActiveSessionFor wraps defaultStore.ReadActiveSessionFor, treating dead sessions as not found
+func ReadActiveSessionFor(authMechanism, deviceType, userId string) (*domain.Session, error) {
+	sess, err := defaultStore.ReadActiveSessionFor(authMechanism, deviceType, userId)
+	return liveSession(sess), err
+}
+
+// liveSession returns nil for a session that is past its LiveUntil. C* will usually have got there first via
+// the TTL, which is set from the same time, but not every Store has TTLs.
+func liveSession(sess *domain.Session) *domain.Session {
+	if sess == nil {
+		return nil
+	}
+	if until := sess.LiveUntil(); !until.IsZero() && !until.After(time.Now()) {
+		return nil
+	}
+	return sess
+}
+
+// WriteSession wraps defaultStore.WriteSession
+func WriteSession(sess *domain.Session) error {
+	return defaultStore.WriteSession(sess)
+}
+
+// DeleteSession wraps defaultStore.DeleteSession
+func DeleteSession(sessId string) error {
+	return defaultStore.DeleteSession(sessId)
+}
+
+// ReadActiveSessionIdsFor wraps defaultStore.ReadActiveSessionIdsFor
+func ReadActiveSessionIdsFor(userId string) (map[string]string, error) {
+	return defaultStore.ReadActiveSessionIdsFor(userId)
+}
+
+// ReadEndpointAuth wraps defaultStore.ReadEndpointAuth
+func ReadEndpointAuth(service string) ([]*domain.EndpointAuth, error) {
+	return defaultStore.ReadEndpointAuth(service)
+}
+
+// WriteEndpointAuths wraps defaultStore.WriteEndpointAuths
+func WriteEndpointAuths(epas []*domain.EndpointAuth) error {
+	return defaultStore.WriteEndpointAuths(epas)
+}
+
+// DeleteEndpointAuths wraps defaultStore.DeleteEndpointAuths
+func DeleteEndpointAuths(epas []*domain.EndpointAuth) error {
+	return defaultStore.DeleteEndpointAuths(epas)
+}
+
+// WriteLogin wraps defaultStore.WriteLogin
+func WriteLogin(login *domain.Login) error {
+	return defaultStore.WriteLogin(login)
+}
+
+// ReadUserLogins wraps defaultStore.ReadUserLogins
+func ReadUserLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.Login, string, error) {
+	return defaultStore.ReadUserLogins(app, uid, start, end, count, lastId)
+}
+
+// WriteFailedLogin wraps defaultStore.WriteFailedLogin
+func WriteFailedLogin(failed *domain.FailedLogin) error {
+	return defaultStore.WriteFailedLogin(failed)
+}
+
+// ReadUserFailedLogins wraps defaultStore.ReadUserFailedLogins
+func ReadUserFailedLogins(app domain.Application, uid string, start, end time.Time, count int, lastId string) ([]*domain.FailedLogin, string, error) {
+	return defaultStore.ReadUserFailedLogins(app, uid, start, end, count, lastId)
+}
+
+// ReadLockout wraps defaultStore.ReadLockout
+func ReadLockout(app domain.Application, uid string) (*domain.Lockout, error) {
+	return defaultStore.ReadLockout(app, uid)
+}
+
+// WriteLockout wraps defaultStore.WriteLockout
+func WriteLockout(lockout *domain.Lockout) error {
+	return defaultStore.WriteLockout(lockout)
+}
+
+// DeleteLockout wraps defaultStore.DeleteLockout
+func DeleteLockout(app domain.Application, uid string) error {
+	return defaultStore.DeleteLockout(app, uid)
+}
+
+// TakeToken wraps defaultStore.TakeToken
+func TakeToken(key string, limit domain.RateLimit) (bool, error) {
+	return defaultStore.TakeToken(key, limit)
+}
+
+// ReadRefreshToken wraps defaultStore.ReadRefreshToken
+func ReadRefreshToken(hash string) (*domain.RefreshToken, error) {
+	return defaultStore.ReadRefreshToken(hash)
+}
+
+// WriteRefreshToken wraps defaultStore.WriteRefreshToken
+func WriteRefreshToken(rt *domain.RefreshToken) error {
+	return defaultStore.WriteRefreshToken(rt)
+}
+
+// ReadPasswordResetToken wraps defaultStore.ReadPasswordResetToken
+func ReadPasswordResetToken(hash string) (*domain.PasswordResetToken, error) {
+	return defaultStore.ReadPasswordResetToken(hash)
+}
+
+// WritePasswordResetToken wraps defaultStore.WritePasswordResetToken
+func WritePasswordResetToken(prt *domain.PasswordResetToken) error {
+	return defaultStore.WritePasswordResetToken(prt)
+}
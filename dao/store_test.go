@@ -0,0 +1,443 @@
+package dao
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/HailoOSS/login-service/domain"
+)
+
+func TestSelectStore(t *testing.T) {
+	defer SetStore(defaultStore)
+
+	assert.NoError(t, SelectStore(DefaultStore))
+	assert.IsType(t, &cassandraStore{}, defaultStore)
+
+	assert.Error(t, SelectStore("carrierpigeon"), "Expecting unknown backend to be rejected")
+	assert.IsType(t, &cassandraStore{}, defaultStore, "Failed selection should leave the current backend in place")
+}
+
+// The following are run against each Store implementation
+
+func storeTestUser(uid string, created time.Time, ids ...domain.Id) *domain.User {
+	return &domain.User{
+		App:      domain.Application("test"),
+		Uid:      uid,
+		Ids:      ids,
+		Created:  created,
+		Roles:    []string{"CUSTOMER"},
+		Password: []byte("hashed"),
+	}
+}
+
+func testStoreUserIndexes(t *testing.T, s Store) {
+	app := domain.Application("test")
+
+	u := storeTestUser("mem1", time.Unix(1378740807, 0), "mem1@example.com", "+447100000001")
+	assert.NoError(t, s.CreateUser(u, ""))
+
+	for _, id := range []string{"mem1", "mem1@example.com", "+447100000001"} {
+		found, err := s.ReadUser(app, id)
+		assert.NoError(t, err)
+		if assert.NotNil(t, found, "Expecting to find user by '%s'", id) {
+			assert.Equal(t, "mem1", found.Uid)
+		}
+	}
+
+	// someone else can't take an index that's in use
+	clash := storeTestUser("mem2", time.Unix(1378740808, 0), "mem1@example.com")
+	err := s.CreateUser(clash, "")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "com.HailoOSS.service.login.createuser.indexinuse", err.Code())
+	}
+
+	// dropping an ID on update removes the index
+	u.Ids = []domain.Id{"mem1@example.com"}
+	assert.NoError(t, s.UpdateUser(u))
+	found, _ := s.ReadUser(app, "+447100000001")
+	assert.Nil(t, found)
+
+	assert.NoError(t, s.DeleteUserIndexes(u, u.Uid, u.Ids))
+	found, _ = s.ReadUser(app, "mem1@example.com")
+	assert.Nil(t, found)
+	found, _ = s.ReadUser(app, "mem1")
+	assert.Nil(t, found)
+}
+
+func testStoreUserMfa(t *testing.T, s Store) {
+	app := domain.Application("test")
+
+	u := storeTestUser("mfa1", time.Unix(1378740807, 0))
+	assert.NoError(t, s.CreateUser(u, ""))
+	found, err := s.ReadUser(app, "mfa1")
+	if assert.NoError(t, err) && assert.NotNil(t, found) {
+		assert.Empty(t, found.TotpSecret)
+		assert.Empty(t, found.RecoveryCodes)
+	}
+
+	u.TotpSecret, u.TotpPendingSecret = "GEZDGNBVGY3TQOJQ", "GEZDGNBVGY3TQOJR"
+	u.RecoveryCodes = [][]byte{[]byte("hash1"), []byte("hash2")}
+	u.TotpLastStep = 46296296
+	assert.NoError(t, s.UpdateUser(u))
+
+	found, err = s.ReadUser(app, "mfa1")
+	if assert.NoError(t, err) && assert.NotNil(t, found) {
+		assert.Equal(t, "GEZDGNBVGY3TQOJQ", found.TotpSecret)
+		assert.Equal(t, "GEZDGNBVGY3TQOJR", found.TotpPendingSecret)
+		assert.Equal(t, [][]byte{[]byte("hash1"), []byte("hash2")}, found.RecoveryCodes)
+		assert.Equal(t, int64(46296296), found.TotpLastStep)
+	}
+}
+
+func testStoreLockouts(t *testing.T, s Store) {
+	app := domain.Application("ADMIN")
+
+	found, err := s.ReadLockout(app, "dave")
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+
+	now := time.Now().Round(time.Millisecond)
+	lockout := &domain.Lockout{
+		App:          app,
+		Uid:          "dave",
+		Failures:     5,
+		FirstFailure: now.Add(-time.Minute),
+		LastFailure:  now,
+		LockedUntil:  now.Add(time.Hour),
+	}
+	assert.NoError(t, s.WriteLockout(lockout))
+	found, err = s.ReadLockout(app, "dave")
+	if assert.NoError(t, err) && assert.NotNil(t, found) {
+		assert.Equal(t, 5, found.Failures)
+		assert.True(t, lockout.LockedUntil.Equal(found.LockedUntil))
+		assert.True(t, lockout.FirstFailure.Equal(found.FirstFailure))
+	}
+
+	// other apps are separate
+	found, _ = s.ReadLockout(domain.Application("DRIVER"), "dave")
+	assert.Nil(t, found)
+
+	assert.NoError(t, s.DeleteLockout(app, "dave"))
+	found, _ = s.ReadLockout(app, "dave")
+	assert.Nil(t, found)
+
+	// expired records are ignored
+	lockout.FirstFailure, lockout.LockedUntil = now.Add(-2*time.Hour), now.Add(-time.Hour)
+	assert.NoError(t, s.WriteLockout(lockout))
+	found, _ = s.ReadLockout(app, "dave")
+	assert.Nil(t, found)
+}
+
+func testStoreTakeToken(t *testing.T, s Store) {
+	limit := domain.RateLimit{Burst: 2, Interval: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		ok, err := s.TakeToken("ip/test/127.0.0.1", limit)
+		assert.NoError(t, err)
+		assert.True(t, ok, "Expecting request %d to be allowed", i)
+	}
+	ok, err := s.TakeToken("ip/test/127.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.False(t, ok, "Expecting request beyond the burst to be limited")
+
+	// buckets are independent
+	ok, err = s.TakeToken("ip/test/127.0.0.2", limit)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func testStoreReadUserList(t *testing.T, s Store) {
+	app := domain.Application("test")
+
+	for i, uid := range []string{"a", "b", "c"} {
+		assert.NoError(t, s.CreateUser(storeTestUser(uid, time.Unix(int64(1000+i), 0), domain.Id(uid+"@example.com")), ""))
+	}
+
+	users, last, err := s.ReadUserList(app, time.Unix(0, 0), time.Unix(2000, 0), 2, "")
+	assert.NoError(t, err)
+	if assert.Len(t, users, 2) {
+		assert.Equal(t, "c", users[0].Uid)
+		assert.Equal(t, "b", users[1].Uid)
+	}
+	assert.Equal(t, "b", last)
+
+	users, _, err = s.ReadUserList(app, time.Unix(0, 0), time.Unix(2000, 0), 2, last)
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "a", users[0].Uid)
+	}
+}
+
+func testStoreSessions(t *testing.T, s Store) {
+	sess := &domain.Session{
+		Id:      "sess1",
+		Created: time.Now(),
+		Token: domain.Token{
+			Created:       time.Now(),
+			AuthMechanism: "h2.test",
+			DeviceType:    "cli",
+			Id:            "mem1",
+			Expires:       time.Now().Add(time.Hour),
+		},
+	}
+	assert.NoError(t, s.WriteSession(sess))
+
+	found, err := s.ReadSession("sess1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "mem1", found.Token.Id)
+	}
+	found, err = s.ReadActiveSessionFor("h2.test", "cli", "mem1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "sess1", found.Id)
+	}
+	ids, err := s.ReadActiveSessionIdsFor("mem1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"sess1": "cli"}, ids)
+
+	assert.NoError(t, s.DeleteSession("sess1"))
+	found, _ = s.ReadSession("sess1")
+	assert.Nil(t, found)
+	found, _ = s.ReadActiveSessionFor("h2.test", "cli", "mem1")
+	assert.Nil(t, found)
+	ids, _ = s.ReadActiveSessionIdsFor("mem1")
+	assert.Len(t, ids, 0)
+}
+
+func testStoreMultipleSessions(t *testing.T, s Store) {
+	for i, id := range []string{"multi1", "multi2", "multi3"} {
+		assert.NoError(t, s.WriteSession(&domain.Session{
+			Id:      id,
+			Created: time.Unix(int64(1000+i), 0),
+			Token: domain.Token{
+				Created:       time.Unix(int64(1000+i), 0),
+				AuthMechanism: "h2.test",
+				DeviceType:    []string{"cli", "cli", "web"}[i],
+				Id:            "mem2",
+				Expires:       time.Now().Add(time.Hour),
+			},
+		}))
+	}
+
+	ids, err := s.ReadActiveSessionIdsFor("mem2")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"multi1": "cli", "multi2": "cli", "multi3": "web"}, ids)
+	found, err := s.ReadActiveSessionFor("h2.test", "cli", "mem2")
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "multi2", found.Id)
+	}
+
+	// deleting an older session leaves the device type's latest alone
+	assert.NoError(t, s.DeleteSession("multi1"))
+	found, _ = s.ReadActiveSessionFor("h2.test", "cli", "mem2")
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "multi2", found.Id)
+	}
+	ids, _ = s.ReadActiveSessionIdsFor("mem2")
+	assert.Equal(t, map[string]string{"multi2": "cli", "multi3": "web"}, ids)
+}
+
+func testStoreReadUserLogins(t *testing.T, s Store) {
+	app := domain.Application("test")
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, s.WriteLogin(&domain.Login{
+			App:      app,
+			Uid:      "mem1",
+			LoggedIn: time.Unix(int64(1000+i), 0),
+		}))
+	}
+
+	logins, last, err := s.ReadUserLogins(app, "mem1", time.Unix(0, 0), time.Unix(2000, 0), 2, "")
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 2) {
+		assert.Equal(t, int64(1002), logins[0].LoggedIn.Unix())
+	}
+
+	logins, _, err = s.ReadUserLogins(app, "mem1", time.Unix(0, 0), time.Unix(2000, 0), 2, last)
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, int64(1000), logins[0].LoggedIn.Unix())
+	}
+}
+
+func testStoreReadUserFailedLogins(t *testing.T, s Store) {
+	app := domain.Application("test")
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, s.WriteFailedLogin(&domain.FailedLogin{
+			App:      app,
+			Uid:      "mem1",
+			Username: "mem1@example.com",
+			Failed:   time.Unix(int64(1000+i), 0),
+			Reason:   domain.FailedLoginBadCredentials,
+			Meta:     map[string]string{"ip": "127.0.0.1"},
+		}))
+	}
+	assert.NoError(t, s.WriteLogin(&domain.Login{App: app, Uid: "mem1", LoggedIn: time.Unix(1500, 0)}))
+
+	failures, last, err := s.ReadUserFailedLogins(app, "mem1", time.Unix(0, 0), time.Unix(2000, 0), 2, "")
+	assert.NoError(t, err)
+	if assert.Len(t, failures, 2) {
+		assert.Equal(t, int64(1002), failures[0].Failed.Unix())
+		assert.Equal(t, "mem1@example.com", failures[0].Username)
+		assert.Equal(t, domain.FailedLoginBadCredentials, failures[0].Reason)
+		assert.Equal(t, "127.0.0.1", failures[0].Meta["ip"])
+	}
+
+	failures, _, err = s.ReadUserFailedLogins(app, "mem1", time.Unix(0, 0), time.Unix(2000, 0), 2, last)
+	assert.NoError(t, err)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, int64(1000), failures[0].Failed.Unix())
+	}
+
+	// successful logins are kept apart
+	logins, _, err := s.ReadUserLogins(app, "mem1", time.Unix(0, 0), time.Unix(2000, 0), 10, "")
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+}
+
+func testStoreActiveKeyId(t *testing.T, s Store) {
+	defer SetStore(defaultStore)
+	SetStore(s)
+
+	kid, err := ReadActiveKeyId()
+	assert.NoError(t, err)
+	assert.Equal(t, "", kid)
+
+	assert.NoError(t, WriteActiveKeyId("2016-01"))
+	assert.NoError(t, WriteActiveKeyId("2016-02"))
+	kid, err = ReadActiveKeyId()
+	assert.NoError(t, err)
+	assert.Equal(t, "2016-02", kid)
+
+	rotations, err := ReadKeyRotations()
+	assert.NoError(t, err)
+	if assert.Len(t, rotations, 2) {
+		assert.Equal(t, "2016-01", rotations[0].KeyId)
+		assert.False(t, rotations[1].Promoted.IsZero())
+	}
+
+	// we only remember so many
+	for i := 0; i < maxKeyRotations; i++ {
+		assert.NoError(t, WriteActiveKeyId(fmt.Sprintf("k%d", i)))
+	}
+	rotations, err = ReadKeyRotations()
+	assert.NoError(t, err)
+	assert.Len(t, rotations, maxKeyRotations)
+}
+
+func TestReadSessionIgnoresDeadSessions(t *testing.T) {
+	defer SetStore(defaultStore)
+	SetStore(NewMemoryStore())
+
+	sess := &domain.Session{
+		Id:      "dead",
+		Created: time.Now().Add(-9 * time.Hour),
+		Token: domain.Token{
+			Created:       time.Now().Add(-9 * time.Hour),
+			AuthMechanism: "h2.test",
+			DeviceType:    "cli",
+			Id:            "mem1",
+			Expires:       time.Now().Add(-time.Hour),
+		},
+	}
+	assert.NoError(t, WriteSession(sess))
+
+	found, err := ReadSession("dead")
+	assert.NoError(t, err)
+	assert.Nil(t, found, "Expecting expired session to be treated as not found")
+	found, err = ReadActiveSessionFor("h2.test", "cli", "mem1")
+	assert.NoError(t, err)
+	assert.Nil(t, found, "Expecting expired session to be treated as not found")
+
+	// ...nor when it could be auto-renewed, but the renew window has long since passed
+	sess.Token.AutoRenew = time.Now().Add(-90 * time.Minute)
+	assert.NoError(t, WriteSession(sess))
+	found, err = ReadSession("dead")
+	assert.NoError(t, err)
+	assert.Nil(t, found, "Expecting a long expired session not to be renewable")
+
+	// ...but it is within a renew window of expiring, as C* would still have it
+	sess.Token.Expires = time.Now().Add(-10 * time.Minute)
+	sess.Token.AutoRenew = time.Now().Add(-40 * time.Minute)
+	assert.NoError(t, WriteSession(sess))
+	found, err = ReadSession("dead")
+	assert.NoError(t, err)
+	assert.NotNil(t, found, "Expecting renewable session to be found")
+}
+
+func testStoreRefreshTokens(t *testing.T, s Store) {
+	hash := domain.HashRefreshToken("refresh")
+	found, err := s.ReadRefreshToken(hash)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+
+	now := time.Now().Round(time.Millisecond)
+	rt := &domain.RefreshToken{
+		Hash:      hash,
+		SessionId: "sess1",
+		Created:   now,
+		Expires:   now.Add(24 * time.Hour),
+	}
+	assert.NoError(t, s.WriteRefreshToken(rt))
+	found, err = s.ReadRefreshToken(hash)
+	if assert.NoError(t, err) && assert.NotNil(t, found) {
+		assert.Equal(t, "sess1", found.SessionId)
+		assert.True(t, rt.Expires.Equal(found.Expires))
+		assert.False(t, found.IsUsed())
+	}
+
+	rt.Used = now.Add(time.Minute)
+	assert.NoError(t, s.WriteRefreshToken(rt))
+	found, _ = s.ReadRefreshToken(hash)
+	if assert.NotNil(t, found) {
+		assert.True(t, found.IsUsed())
+		assert.True(t, rt.Used.Equal(found.Used))
+	}
+
+	found, _ = s.ReadRefreshToken(domain.HashRefreshToken("other"))
+	assert.Nil(t, found)
+}
+
+func testStorePasswordResetTokens(t *testing.T, s Store) {
+	hash := domain.HashPasswordResetToken("reset")
+	found, err := s.ReadPasswordResetToken(hash)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+
+	now := time.Now().Round(time.Millisecond)
+	prt := &domain.PasswordResetToken{
+		Hash:            hash,
+		App:             "DRIVER",
+		Uid:             "dave",
+		Created:         now,
+		Expires:         now.Add(30 * time.Minute),
+		PasswordChanged: now.Add(-time.Hour),
+	}
+	assert.NoError(t, s.WritePasswordResetToken(prt))
+	found, err = s.ReadPasswordResetToken(hash)
+	if assert.NoError(t, err) && assert.NotNil(t, found) {
+		assert.Equal(t, domain.Application("DRIVER"), found.App)
+		assert.Equal(t, "dave", found.Uid)
+		assert.True(t, prt.Expires.Equal(found.Expires))
+		assert.True(t, prt.PasswordChanged.Equal(found.PasswordChanged))
+		assert.False(t, found.IsUsed())
+	}
+
+	prt.Used = now.Add(time.Minute)
+	assert.NoError(t, s.WritePasswordResetToken(prt))
+	found, _ = s.ReadPasswordResetToken(hash)
+	if assert.NotNil(t, found) {
+		assert.True(t, found.IsUsed())
+		assert.True(t, prt.Used.Equal(found.Used))
+	}
+
+	found, _ = s.ReadPasswordResetToken(domain.HashPasswordResetToken("other"))
+	assert.Nil(t, found)
+}
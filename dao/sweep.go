@@ -0,0 +1,268 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/login-service/sessinvalidator"
+	"github.com/HailoOSS/service/config"
+	"github.com/HailoOSS/service/sync"
+)
+
+const (
+	sweepBatch          = 100
+	sweepCheckpointName = "sessionsweeper"
+	sweepLockPath       = "sessionsweeper"
+	// sweepRenewWindow is how long after expiry we leave sessions alone, so we never race an auto-renewal
+	sweepRenewWindow = 30 * time.Minute
+)
+
+const (
+	SweepPhaseIdle         = "idle"
+	SweepPhaseSessions     = "sessions"
+	SweepPhaseUserSessions = "userSessions"
+)
+
+// sweepable is implemented by storage backends that the session sweeper can garbage-collect. Scans
+// are in whatever order the backend finds natural, resuming after the key (or UID) given.
+type sweepable interface {
+	scanSessions(after string, count int) ([]*sweptSession, error)
+	scanUserSessions(after string, count int) ([]*sweptUserSessions, error)
+	deleteUserSession(uid, deviceType, sessId string) error
+	checkpointer
+}
+
+// checkpointer is implemented by storage backends that can store small named blobs, for background jobs
+// to record their progress and the like
+type checkpointer interface {
+	readCheckpoint(name string) ([]byte, error)
+	writeCheckpoint(name string, data []byte) error
+}
+
+// sweptSession is a single session row found by a scan; sess is nil if it could not be unmarshaled
+type sweptSession struct {
+	key  string
+	sess *domain.Session
+}
+
+// sweptUserSessions is a single user's session ID -> device type map found by a scan
+type sweptUserSessions struct {
+	uid        string
+	sessionIds map[string]string
+}
+
+// SweepProgress records how far the sweeper has got. It is checkpointed after every batch, so a pass
+// interrupted by a restart carries on where it left off.
+type SweepProgress struct {
+	Phase               string
+	Position            string
+	Passes              int64
+	Started             time.Time
+	Finished            time.Time
+	Checkpointed        time.Time
+	SessionsScanned     int64
+	SessionsDeleted     int64
+	UserSessionsScanned int64
+	UserSessionsDeleted int64
+}
+
+// RunSweeper launches the sweeper, which makes one pass every `hailo.service.login.sweeper.interval`.
+// Only one instance (per region) sweeps at a time.
+func RunSweeper() {
+	if _, ok := defaultStore.(sweepable); !ok {
+		log.Info("[Sweeper] Storage backend cannot be swept")
+		return
+	}
+
+	log.Info("[Sweeper] Launching session sweeper...")
+	go func() {
+		for {
+			interval := config.AtPath("hailo", "service", "login", "sweeper", "interval").AsDuration("1h")
+			if lock, err := sync.RegionLock([]byte(sweepLockPath)); err != nil {
+				log.Debugf("[Sweeper] Not sweeping, failed to get lock: %v", err)
+			} else {
+				// another instance may have finished a pass while we waited for the lock
+				if progress, err := SweepStatus(); err != nil {
+					log.Errorf("[Sweeper] Failed to read progress: %v", err)
+				} else if progress.Phase != SweepPhaseIdle || time.Since(progress.Finished) >= interval {
+					if err := SweepSessions(); err != nil {
+						log.Errorf("[Sweeper] Sweep failed: %v", err)
+					}
+				}
+				lock.Unlock()
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// SweepSessions makes a single pass over all sessions then all userSessions, resuming from the last
+// checkpoint if a previous pass was interrupted. It deletes sessions that expired more than
+// sweepRenewWindow ago and userSessions entries that point at missing sessions, broadcasting expiry
+// for each.
+func SweepSessions() error {
+	sw, ok := defaultStore.(sweepable)
+	if !ok {
+		return fmt.Errorf("Storage backend cannot be swept")
+	}
+
+	progress, err := readSweepProgress(sw)
+	if err != nil {
+		return err
+	}
+	if progress.Phase == SweepPhaseIdle {
+		*progress = SweepProgress{
+			Phase:   SweepPhaseSessions,
+			Passes:  progress.Passes,
+			Started: time.Now(),
+		}
+	} else {
+		log.Infof("[Sweeper] Resuming %s sweep from '%s'", progress.Phase, progress.Position)
+	}
+
+	for progress.Phase == SweepPhaseSessions {
+		// prevent this getting carried away
+		time.Sleep(sweepBatchInterval())
+
+		batch, err := sw.scanSessions(progress.Position, sweepBatch)
+		if err != nil {
+			return fmt.Errorf("Failed to scan sessions: %v", err)
+		}
+		for _, swept := range batch {
+			if err := sweepSession(swept, progress); err != nil {
+				return err
+			}
+			progress.Position = swept.key
+		}
+		if len(batch) < sweepBatch {
+			progress.Phase, progress.Position = SweepPhaseUserSessions, ""
+		}
+		if err := writeSweepProgress(sw, progress); err != nil {
+			return err
+		}
+	}
+
+	for progress.Phase == SweepPhaseUserSessions {
+		time.Sleep(sweepBatchInterval())
+
+		batch, err := sw.scanUserSessions(progress.Position, sweepBatch)
+		if err != nil {
+			return fmt.Errorf("Failed to scan userSessions: %v", err)
+		}
+		for _, swept := range batch {
+			if err := sweepUserSessions(sw, swept, progress); err != nil {
+				return err
+			}
+			progress.Position = swept.uid
+		}
+		if len(batch) < sweepBatch {
+			progress.Phase, progress.Position = SweepPhaseIdle, ""
+			progress.Finished = time.Now()
+			progress.Passes++
+		}
+		if err := writeSweepProgress(sw, progress); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("[Sweeper] Pass complete: deleted %d of %d sessions and %d of %d userSessions entries",
+		progress.SessionsDeleted, progress.SessionsScanned, progress.UserSessionsDeleted, progress.UserSessionsScanned)
+	return nil
+}
+
+// SweepStatus returns the sweeper's last checkpointed progress
+func SweepStatus() (*SweepProgress, error) {
+	sw, ok := defaultStore.(sweepable)
+	if !ok {
+		return nil, fmt.Errorf("Storage backend cannot be swept")
+	}
+	return readSweepProgress(sw)
+}
+
+func sweepSession(swept *sweptSession, progress *SweepProgress) error {
+	progress.SessionsScanned++
+	if swept.sess == nil {
+		log.Warnf("[Sweeper] Failed to unmarshal session: %v", swept.key)
+		return nil
+	}
+	// C* has sessions under authMech§deviceType§uid too; deleting by ID deals with those, and any left
+	// dangling will go with their TTL
+	if swept.key != swept.sess.Id || !isSweepable(swept.sess) {
+		return nil
+	}
+
+	if err := defaultStore.DeleteSession(swept.sess.Id); err != nil {
+		return fmt.Errorf("Failed to delete session %s: %v", swept.sess.Id, err)
+	}
+	progress.SessionsDeleted++
+	sessinvalidator.BroadcastSessionExpiry(swept.sess.Id)
+	return nil
+}
+
+func sweepUserSessions(sw sweepable, swept *sweptUserSessions, progress *SweepProgress) error {
+	for sessId, deviceType := range swept.sessionIds {
+		progress.UserSessionsScanned++
+
+		sess, err := defaultStore.ReadSession(sessId)
+		if err != nil {
+			return fmt.Errorf("Failed to read session %s: %v", sessId, err)
+		}
+		switch {
+		case sess == nil:
+			if err := sw.deleteUserSession(swept.uid, deviceType, sessId); err != nil {
+				return fmt.Errorf("Failed to delete userSessions entry %s/%s: %v", swept.uid, sessId, err)
+			}
+		case isSweepable(sess):
+			if err := defaultStore.DeleteSession(sessId); err != nil {
+				return fmt.Errorf("Failed to delete session %s: %v", sessId, err)
+			}
+		default:
+			continue
+		}
+		progress.UserSessionsDeleted++
+		sessinvalidator.BroadcastSessionExpiry(sessId)
+	}
+	return nil
+}
+
+// isSweepable tests if a session's token expired long enough ago that it can never be used again, and it
+// can't be refreshed either
+func isSweepable(sess *domain.Session) bool {
+	return !sess.Token.Expires.IsZero() && sess.Token.Expires.Add(sweepRenewWindow).Before(time.Now()) &&
+		!sess.CanRefresh()
+}
+
+func sweepBatchInterval() time.Duration {
+	return config.AtPath("hailo", "service", "login", "sweeper", "batchInterval").AsDuration("500ms")
+}
+
+func readSweepProgress(sw sweepable) (*SweepProgress, error) {
+	data, err := sw.readCheckpoint(sweepCheckpointName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read checkpoint: %v", err)
+	}
+	progress := &SweepProgress{Phase: SweepPhaseIdle}
+	if len(data) == 0 {
+		return progress, nil
+	}
+	if err := json.Unmarshal(data, progress); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal checkpoint: %v", err)
+	}
+	return progress, nil
+}
+
+func writeSweepProgress(sw sweepable, progress *SweepProgress) error {
+	progress.Checkpointed = time.Now()
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal checkpoint: %v", err)
+	}
+	if err := sw.writeCheckpoint(sweepCheckpointName, data); err != nil {
+		return fmt.Errorf("Failed to write checkpoint: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package dao
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/gossie/src/gossie"
+	"github.com/HailoOSS/service/cassandra"
+)
+
+/*
+ CF structure:
+  ROW KEY      COL         VALUE
+ [job name]   [progress]  JSON
+*/
+
+const checkpointColumn = "progress"
+
+// scanSessions range scans the sessions CF
+func (s *cassandraStore) scanSessions(after string, count int) ([]*sweptSession, error) {
+	rows, err := s.rangeGet(cfSessions, after, count)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*sweptSession, 0, len(rows))
+	for _, row := range rows {
+		sess, err := unmarshalSession(row)
+		if err != nil {
+			sess = nil
+		}
+		ret = append(ret, &sweptSession{key: string(row.Key), sess: sess})
+	}
+	return ret, nil
+}
+
+// scanUserSessions range scans the userSessions CF
+func (s *cassandraStore) scanUserSessions(after string, count int) ([]*sweptUserSessions, error) {
+	rows, err := s.rangeGet(cfUserSessions, after, count)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*sweptUserSessions, 0, len(rows))
+	for _, row := range rows {
+		ret = append(ret, &sweptUserSessions{uid: string(row.Key), sessionIds: unmarshalUserSessions(row)})
+	}
+	return ret, nil
+}
+
+// deleteUserSession removes a single session's column from a user's row in userSessions
+func (s *cassandraStore) deleteUserSession(uid, deviceType, sessId string) error {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	writer := pool.Writer()
+	if err := deleteUserSessionColumns(pool, writer, uid, deviceType, sessId); err != nil {
+		return err
+	}
+	if err := writer.Run(); err != nil {
+		return fmt.Errorf("Write error deleting from C*: %v", err)
+	}
+	return nil
+}
+
+func (s *cassandraStore) readCheckpoint(name string) ([]byte, error) {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	row, err := pool.Reader().Cf(cfCheckpoints).Columns([][]byte{[]byte(checkpointColumn)}).Get([]byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from C*: %v", err)
+	}
+	if row == nil || len(row.Columns) == 0 {
+		return nil, nil
+	}
+	return row.Columns[0].Value, nil
+}
+
+func (s *cassandraStore) writeCheckpoint(name string, data []byte) error {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	writer := pool.Writer()
+	writer.Insert(cfCheckpoints, &gossie.Row{
+		Key: []byte(name),
+		Columns: []*gossie.Column{{
+			Name:  []byte(checkpointColumn),
+			Value: data,
+		}},
+	})
+	if err := writer.Run(); err != nil {
+		return fmt.Errorf("Write error writing to C*: %v", err)
+	}
+	return nil
+}
+
+// rangeGet fetches up to count rows after the supplied key, skipping range ghosts (deleted rows that
+// C* still returns, without columns)
+func (s *cassandraStore) rangeGet(cf, after string, count int) ([]*gossie.Row, error) {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+
+	// the start key is inclusive, so ask for one more and skip it
+	rows, err := pool.Reader().Cf(cf).RangeGet(&gossie.Range{
+		Start: []byte(after),
+		End:   []byte{},
+		Count: count + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from C*: %v", err)
+	}
+
+	ret := make([]*gossie.Row, 0, len(rows))
+	for _, row := range rows {
+		if (len(after) > 0 && string(row.Key) == after) || len(row.Columns) == 0 {
+			continue
+		}
+		if len(ret) == count {
+			break
+		}
+		ret = append(ret, row)
+	}
+	return ret, nil
+}
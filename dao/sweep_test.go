@@ -0,0 +1,100 @@
+package dao
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/service/config"
+)
+
+func sweepTestSession(id, uid string, expires time.Time) *domain.Session {
+	return &domain.Session{
+		Id:      id,
+		Created: expires.Add(-8 * time.Hour),
+		Token: domain.Token{
+			Created:       expires.Add(-8 * time.Hour),
+			AuthMechanism: "h2.test",
+			DeviceType:    "cli",
+			Id:            uid,
+			Expires:       expires,
+		},
+	}
+}
+
+// deleteSessionRow removes a session without touching userSessions, as a lost write might
+func deleteSessionRow(t *testing.T, s Store, id string) {
+	switch st := s.(type) {
+	case *memoryStore:
+		st.Lock()
+		delete(st.sessions, id)
+		st.Unlock()
+	case *sqlStore:
+		_, err := st.db.Exec(`DELETE FROM sessions WHERE id = $1`, id)
+		assert.NoError(t, err)
+	default:
+		t.Fatalf("Cannot delete session rows from %T", s)
+	}
+}
+
+func testSweepSessions(t *testing.T, s Store) {
+	defer SetStore(defaultStore)
+	SetStore(s)
+	config.Load(bytes.NewBufferString(`{"hailo": {"service": {"login": {"sweeper": {"batchInterval": "0s"}}}}}`))
+
+	live := sweepTestSession("live", "user1", time.Now().Add(time.Hour))
+	dead := sweepTestSession("dead", "user2", time.Now().Add(-2*time.Hour))
+	// recently expired, so might yet be auto-renewed
+	recent := sweepTestSession("recent", "user3", time.Now().Add(-10*time.Minute))
+	// leave this one dangling in userSessions
+	dangling := sweepTestSession("dangling", "user4", time.Now().Add(time.Hour))
+	for _, sess := range []*domain.Session{live, dead, recent, dangling} {
+		assert.NoError(t, s.WriteSession(sess))
+	}
+	deleteSessionRow(t, s, "dangling")
+
+	assert.NoError(t, SweepSessions())
+
+	for id, expected := range map[string]bool{"live": true, "dead": false, "recent": true} {
+		sess, err := s.ReadSession(id)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, sess != nil, "Unexpected state for session '%s'", id)
+	}
+	for uid, expected := range map[string]int{"user1": 1, "user2": 0, "user3": 1, "user4": 0} {
+		ids, err := s.ReadActiveSessionIdsFor(uid)
+		assert.NoError(t, err)
+		assert.Len(t, ids, expected, "Unexpected userSessions for '%s'", uid)
+	}
+
+	progress, err := SweepStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, SweepPhaseIdle, progress.Phase)
+	assert.Equal(t, int64(1), progress.Passes)
+	assert.Equal(t, int64(3), progress.SessionsScanned)
+	assert.Equal(t, int64(1), progress.SessionsDeleted)
+	assert.Equal(t, int64(1), progress.UserSessionsDeleted)
+	assert.False(t, progress.Finished.IsZero())
+
+	// an interrupted pass picks up where it left off -- here, after the dead session's user
+	assert.NoError(t, s.WriteSession(dead))
+	assert.NoError(t, writeSweepProgress(s.(sweepable), &SweepProgress{Phase: SweepPhaseUserSessions, Position: "user2", Passes: 1}))
+	assert.NoError(t, SweepSessions())
+
+	sess, err := s.ReadSession("dead")
+	assert.NoError(t, err)
+	assert.NotNil(t, sess, "Expecting resumed pass to skip sessions before the checkpoint")
+	progress, err = SweepStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), progress.Passes)
+}
+
+func TestMemorySweepSessions(t *testing.T) {
+	testSweepSessions(t, NewMemoryStore())
+}
+
+func TestSQLSweepSessions(t *testing.T) {
+	testSweepSessions(t, newSQLiteStore(t))
+}
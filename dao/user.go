@@ -7,8 +7,6 @@ import (
 	"github.com/HailoOSS/login-service/domain"
 	"github.com/HailoOSS/login-service/sessinvalidator"
 	"github.com/HailoOSS/platform/errors"
-	"github.com/HailoOSS/service/cassandra"
-	inst "github.com/HailoOSS/service/instrumentation"
 )
 
 // CreateUser will create a new user so long as none of the IDs already exist
@@ -19,36 +17,7 @@ func CreateUser(user *domain.User, plainPass string) errors.Error {
 		return errors.InternalServerError("com.HailoOSS.service.login.createuser.ldap", "Cant create LDAP user")
 	}
 
-	lock, err := lockUser(user)
-	defer lock.Unlock()
-	if err != nil {
-		return errors.InternalServerError("com.HailoOSS.service.login.createuser.lockerr", fmt.Sprintf("Failed to achieve ZK lock for `create` operation: %v", err))
-	}
-
-	pool, err := cassandra.ConnectionPool(Keyspace)
-	if err != nil {
-		return errors.InternalServerError("com.HailoOSS.service.login.createuser.cassandra", fmt.Sprintf("Failed to get connection pool: %v", err))
-	}
-
-	// test to see if exists -- can happily "replay" a create request, but cannot overwrite something that exists with different data
-	if err := testIndexes(pool, user); err != nil {
-		return err
-	}
-	if err := testBlat(user, plainPass); err != nil {
-		return err
-	}
-
-	writer := pool.Writer()
-	if err := writeUser(user, writer, nil); err != nil {
-		return errors.InternalServerError("com.HailoOSS.service.login.createuser.marshaling", fmt.Sprintf("Failed to marshal into mutation: %v", err))
-	}
-	t := time.Now()
-	if err := writer.Run(); err != nil {
-		return errors.InternalServerError("com.HailoOSS.service.login.createuser.cassandra", fmt.Sprintf("Create error writing to C*: %v", err))
-	}
-	inst.Timing(1.0, "cassandra.write.createuser", time.Since(t))
-
-	return nil
+	return defaultStore.CreateUser(user, plainPass)
 }
 
 // ReadUser returns a user, fetched by ID
@@ -63,7 +32,7 @@ func ReadUser(app domain.Application, uid string) (*domain.User, error) {
 		}
 		return users[0], nil
 	} else {
-		return readH2User(app, uid)
+		return defaultStore.ReadUser(app, uid)
 	}
 }
 
@@ -84,7 +53,7 @@ func MultiReadUser(app domain.Application, ids []string) ([]*domain.User, error)
 	users := []*domain.User{}
 
 	// Fetch H2 Users
-	h2Users, err := readH2Users(app, h2IDs)
+	h2Users, err := defaultStore.MultiReadUser(app, h2IDs)
 	if err != nil {
 		return nil, err
 	}
@@ -106,38 +75,7 @@ func UpdateUser(user *domain.User) error {
 		return fmt.Errorf("Cant update LDAP user")
 	}
 
-	lock, err := lockUser(user)
-	defer lock.Unlock()
-	if err != nil {
-		return fmt.Errorf("Failed to achieve ZK lock for `update` operation: %v", err)
-	}
-
-	// test to see if exists -- must exist if we're updating
-	existingUser, err := ReadUser(user.App, user.Uid)
-	if err != nil {
-		return fmt.Errorf("Failed to test for existing: %v", err)
-	}
-	if existingUser == nil {
-		return fmt.Errorf("User %v:%s does not exist - cannot update", user.App, user.Uid)
-	}
-
-	pool, err := cassandra.ConnectionPool(Keyspace)
-	if err != nil {
-		return fmt.Errorf("Failed to get connection pool: %v", err)
-	}
-	if err := testIndexes(pool, user); err != nil {
-		return err
-	}
-	writer := pool.Writer()
-	if err := writeUser(user, writer, existingUser); err != nil {
-		return fmt.Errorf("Failed to marshal into mutation: %v", err)
-	}
-	t := time.Now()
-	if err := writer.Run(); err != nil {
-		return fmt.Errorf("Create error writing to C*: %v", err)
-	}
-	inst.Timing(1.0, "cassandra.write.updateuser", time.Since(t))
-	return nil
+	return defaultStore.UpdateUser(user)
 }
 
 // DeleteUser deletes a user and expires all their active sessions
@@ -151,8 +89,8 @@ func DeleteUser(user *domain.User) error {
 	if err != nil {
 		return fmt.Errorf("Failed to get active sessions: %s", err.Error())
 	}
-	for deviceType, sessionId := range sessionIds {
-		if err = DeleteSession(sessionId, deviceType); err != nil {
+	for sessionId := range sessionIds {
+		if err = DeleteSession(sessionId); err != nil {
 			return fmt.Errorf("Failed to expire other sessions: %s", err.Error())
 		}
 		sessinvalidator.BroadcastSessionExpiry(sessionId)
@@ -167,42 +105,13 @@ func DeleteUser(user *domain.User) error {
 	return nil
 }
 
+// DeleteUserIndexes wraps defaultStore.DeleteUserIndexes
 func DeleteUserIndexes(user *domain.User, uid string, ids []domain.Id) error {
-	pool, err := cassandra.ConnectionPool(Keyspace)
-	if err != nil {
-		return fmt.Errorf("Failed to get connection pool: %s", err.Error())
-	}
-	writer := pool.Writer()
-	deleteUser(user, writer, uid, ids)
-	t := time.Now()
-	if err := writer.Run(); err != nil {
-		return fmt.Errorf("Write error deleting from C*: %s", err.Error())
-	}
-	inst.Timing(1.0, "cassandra.write.deleteuser", time.Since(t))
-
-	return nil
+	return defaultStore.DeleteUserIndexes(user, uid, ids)
 }
 
 // ReadUserList returns a timeseries list of all users, ordered by created
-// timestamp. This function will only return users stored in cassandra (H2 users).
+// timestamp. This function will only return stored users (H2 users).
 func ReadUserList(app domain.Application, start, end time.Time, count int, lastId string) ([]*domain.User, string, error) {
-	iter := userTs.ReversedIterator(start, end, lastId, string(app))
-	users := make([]*domain.User, 0)
-
-	for iter.Next() {
-		user := &domain.User{}
-		if err := iter.Item().Unmarshal(user); err != nil {
-			return nil, "", fmt.Errorf("Failed to unmarshal user: %v", err)
-		}
-		users = append(users, user)
-		if len(users) >= count {
-			break
-		}
-	}
-
-	if err := iter.Err(); err != nil {
-		return nil, "", fmt.Errorf("DAO read error: %v", err)
-	}
-
-	return users, iter.Last(), nil
+	return defaultStore.ReadUserList(app, start, end, count, lastId)
 }
@@ -66,7 +66,123 @@ func testIndexes(pool gossie.ConnectionPool, user *domain.User) errors.Error {
 	return nil
 }
 
-func readH2User(app domain.Application, uid string) (*domain.User, error) {
+// CreateUser will create a new user so long as none of the IDs already exist
+func (s *cassandraStore) CreateUser(user *domain.User, plainPass string) errors.Error {
+	lock, err := lockUser(user)
+	defer lock.Unlock()
+	if err != nil {
+		return errors.InternalServerError("com.HailoOSS.service.login.createuser.lockerr", fmt.Sprintf("Failed to achieve ZK lock for `create` operation: %v", err))
+	}
+
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return errors.InternalServerError("com.HailoOSS.service.login.createuser.cassandra", fmt.Sprintf("Failed to get connection pool: %v", err))
+	}
+
+	// test to see if exists -- can happily "replay" a create request, but cannot overwrite something that exists with different data
+	if err := testIndexes(pool, user); err != nil {
+		return err
+	}
+	existing, err := s.ReadUser(user.App, user.Uid)
+	if err != nil {
+		return errors.InternalServerError("com.HailoOSS.service.login.createuser.testblat", fmt.Sprintf("Failed to test for existing: %v", err))
+	}
+	if err := testBlat(existing, user, plainPass); err != nil {
+		return err
+	}
+
+	writer := pool.Writer()
+	if err := writeUser(user, writer, nil); err != nil {
+		return errors.InternalServerError("com.HailoOSS.service.login.createuser.marshaling", fmt.Sprintf("Failed to marshal into mutation: %v", err))
+	}
+	t := time.Now()
+	if err := writer.Run(); err != nil {
+		return errors.InternalServerError("com.HailoOSS.service.login.createuser.cassandra", fmt.Sprintf("Create error writing to C*: %v", err))
+	}
+	inst.Timing(1.0, "cassandra.write.createuser", time.Since(t))
+
+	return nil
+}
+
+// UpdateUser will update details of an existing user
+func (s *cassandraStore) UpdateUser(user *domain.User) error {
+	lock, err := lockUser(user)
+	defer lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("Failed to achieve ZK lock for `update` operation: %v", err)
+	}
+
+	// test to see if exists -- must exist if we're updating
+	existingUser, err := s.ReadUser(user.App, user.Uid)
+	if err != nil {
+		return fmt.Errorf("Failed to test for existing: %v", err)
+	}
+	if existingUser == nil {
+		return fmt.Errorf("User %v:%s does not exist - cannot update", user.App, user.Uid)
+	}
+
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return fmt.Errorf("Failed to get connection pool: %v", err)
+	}
+	if err := testIndexes(pool, user); err != nil {
+		return err
+	}
+	writer := pool.Writer()
+	if err := writeUser(user, writer, existingUser); err != nil {
+		return fmt.Errorf("Failed to marshal into mutation: %v", err)
+	}
+	t := time.Now()
+	if err := writer.Run(); err != nil {
+		return fmt.Errorf("Create error writing to C*: %v", err)
+	}
+	inst.Timing(1.0, "cassandra.write.updateuser", time.Since(t))
+	return nil
+}
+
+// DeleteUserIndexes removes the user's primary row, the supplied secondary indexes and the user's
+// entry in the created-time index
+func (s *cassandraStore) DeleteUserIndexes(user *domain.User, uid string, ids []domain.Id) error {
+	pool, err := cassandra.ConnectionPool(Keyspace)
+	if err != nil {
+		return fmt.Errorf("Failed to get connection pool: %s", err.Error())
+	}
+	writer := pool.Writer()
+	deleteUser(user, writer, uid, ids)
+	t := time.Now()
+	if err := writer.Run(); err != nil {
+		return fmt.Errorf("Write error deleting from C*: %s", err.Error())
+	}
+	inst.Timing(1.0, "cassandra.write.deleteuser", time.Since(t))
+
+	return nil
+}
+
+// ReadUserList returns a timeseries list of all users, ordered by created timestamp
+func (s *cassandraStore) ReadUserList(app domain.Application, start, end time.Time, count int, lastId string) ([]*domain.User, string, error) {
+	iter := userTs.ReversedIterator(start, end, lastId, string(app))
+	users := make([]*domain.User, 0)
+
+	for iter.Next() {
+		user := &domain.User{}
+		if err := iter.Item().Unmarshal(user); err != nil {
+			return nil, "", fmt.Errorf("Failed to unmarshal user: %v", err)
+		}
+		users = append(users, user)
+		if len(users) >= count {
+			break
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, "", fmt.Errorf("DAO read error: %v", err)
+	}
+
+	return users, iter.Last(), nil
+}
+
+// ReadUser returns a user, fetched by UID or secondary ID
+func (s *cassandraStore) ReadUser(app domain.Application, uid string) (*domain.User, error) {
 	pool, err := cassandra.ConnectionPool(Keyspace)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get connection pool: %v", err)
@@ -89,9 +205,9 @@ func readH2User(app domain.Application, uid string) (*domain.User, error) {
 	return user, nil
 }
 
-// readH2Users reads the users from cassandra, if a user isn't found then
+// MultiReadUser reads the users from cassandra, if a user isn't found then
 // the returned array length won't match ids length
-func readH2Users(app domain.Application, ids []string) ([]*domain.User, error) {
+func (s *cassandraStore) MultiReadUser(app domain.Application, ids []string) ([]*domain.User, error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
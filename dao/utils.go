@@ -58,14 +58,10 @@ func (c *blatComparison) diff(other *blatComparison) string {
 
 // testBlat makes sure we're not blitzing over the top of some pre-existing user, with some
 // ability to allow idempotence
-func testBlat(user *domain.User, plainPass string) errors.Error {
+func testBlat(existing, user *domain.User, plainPass string) errors.Error {
 	// the main problem with idempotence is the password hash, which will be different
 	// on subsequent attempts
 
-	existing, err := ReadUser(user.App, user.Uid)
-	if err != nil {
-		return errors.InternalServerError("com.HailoOSS.service.login.createuser.testblat", fmt.Sprintf("Failed to test for existing: %v", err))
-	}
 	if existing == nil {
 		return nil
 	}
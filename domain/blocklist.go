@@ -0,0 +1,281 @@
+package domain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/cihub/seelog"
+)
+
+// the blocklist lives next to the keys (it's a var only so tests can point it elsewhere). It is either a text
+// file with the hex SHA-1 of one password per line, optionally followed by `:<count>` as in the Have I Been
+// Pwned downloads, or a Bloom filter of the same hashes as written by BloomFilter.WriteTo.
+var blocklistFile = "/opt/hailo/login-service/password-blocklist"
+
+const (
+	bloomFilterMagic = "PWBF"
+	// minIdLengthToMatch is how long a user's ID must be for us to reject passwords merely containing it;
+	// shorter IDs only reject passwords that are the ID
+	minIdLengthToMatch = 4
+)
+
+// sha1Digest is the SHA-1 of a password, which is what the blocklist holds
+type sha1Digest [sha1.Size]byte
+
+// passwordBlocklist answers whether a password is on the blocklist, by its SHA-1
+type passwordBlocklist interface {
+	contains(digest sha1Digest) bool
+}
+
+var (
+	blocklistMtx sync.RWMutex
+	blocklist    passwordBlocklist
+)
+
+// LoadPasswordBlocklist (re)loads the blocklist from disk. Having no blocklist isn't an error, it just means
+// IsNotBlocklisted only checks passwords against the user's own IDs.
+func LoadPasswordBlocklist() error {
+	return loadPasswordBlocklist(blocklistFile)
+}
+
+func loadPasswordBlocklist(fn string) error {
+	var list passwordBlocklist
+	f, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		log.Warnf("[Domain] No password blocklist at %v", fn)
+	} else if err != nil {
+		return fmt.Errorf("Failed to open password blocklist: %v", err)
+	} else {
+		defer f.Close()
+		r := bufio.NewReader(f)
+		if magic, _ := r.Peek(len(bloomFilterMagic)); string(magic) == bloomFilterMagic {
+			var fi os.FileInfo
+			if fi, err = f.Stat(); err == nil {
+				list, err = readBloomFilter(r, fi.Size())
+			}
+		} else {
+			list, err = readHashList(r)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to load password blocklist: %v", err)
+		}
+	}
+
+	blocklistMtx.Lock()
+	defer blocklistMtx.Unlock()
+	blocklist = list
+	return nil
+}
+
+// isBlocklisted tests whether a password is on the blocklist
+func isBlocklisted(plain string) bool {
+	blocklistMtx.RLock()
+	defer blocklistMtx.RUnlock()
+	return blocklist != nil && blocklist.contains(sha1.Sum([]byte(plain)))
+}
+
+// containsUserIds tests whether a password contains (ignoring case) the user's UID, any of their IDs, or the
+// name part of any email address among them
+func containsUserIds(plain string, user *User) bool {
+	ids := []string{user.Uid}
+	for _, id := range user.Ids {
+		ids = append(ids, string(id))
+		if at := strings.LastIndex(string(id), "@"); at > 0 {
+			ids = append(ids, string(id)[:at])
+		}
+	}
+
+	plain = strings.ToLower(plain)
+	for _, id := range ids {
+		id = strings.ToLower(strings.TrimSpace(id))
+		switch {
+		case id == "":
+		case len([]rune(id)) < minIdLengthToMatch:
+			if plain == id {
+				return true
+			}
+		case strings.Contains(plain, id):
+			return true
+		}
+	}
+	return false
+}
+
+// hashList is a blocklist of SHA-1s, sorted so we can binary search it
+type hashList []sha1Digest
+
+func (l hashList) Len() int           { return len(l) }
+func (l hashList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l hashList) Less(i, j int) bool { return bytes.Compare(l[i][:], l[j][:]) < 0 }
+
+func (l hashList) contains(digest sha1Digest) bool {
+	i := sort.Search(len(l), func(i int) bool { return bytes.Compare(l[i][:], digest[:]) >= 0 })
+	return i < len(l) && l[i] == digest
+}
+
+// readHashList reads a text list of hex SHA-1s, skipping blank lines and # comments
+func readHashList(r io.Reader) (hashList, error) {
+	list := make(hashList, 0)
+	err := scanHashList(r, func(digest sha1Digest) error {
+		list = append(list, digest)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(list)
+	return list, nil
+}
+
+// CountHashList counts the SHA-1s in a text list, eg: to size a Bloom filter before building it
+func CountHashList(r io.Reader) (int, error) {
+	n := 0
+	err := scanHashList(r, func(sha1Digest) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// scanHashList calls fn with each SHA-1 in a text list in turn, stopping at the first error
+func scanHashList(r io.Reader, fn func(digest sha1Digest) error) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if colon := strings.Index(text, ":"); colon >= 0 {
+			text = text[:colon]
+		}
+		b, err := hex.DecodeString(text)
+		if err != nil || len(b) != sha1.Size {
+			return fmt.Errorf("Line %d is not a hex SHA-1", line)
+		}
+		var digest sha1Digest
+		copy(digest[:], b)
+		if err := fn(digest); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// BloomFilter is a compact blocklist. It never misses a password on the list, but wrongly reports a small
+// proportion of other passwords (the false positive rate it was sized for) as being on it.
+type BloomFilter struct {
+	// k is how many bits each password sets, of the m in bits
+	k    uint32
+	m    uint64
+	bits []byte
+}
+
+// NewBloomFilter mints an empty Bloom filter sized for n passwords at a false positive rate
+func NewBloomFilter(n int, falsePositiveRate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if m < 8 {
+		m = 8
+	}
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{k: k, m: m, bits: make([]byte, (m+7)/8)}
+}
+
+// BuildBloomFilter builds a Bloom filter sized for n passwords from a text list of hex SHA-1s, in the same
+// format as a blocklist file. The list is streamed, so it can be far bigger than memory; a list with more than
+// n hashes is an error, as the filter would miss its false positive rate.
+func BuildBloomFilter(r io.Reader, n int, falsePositiveRate float64) (*BloomFilter, error) {
+	b := NewBloomFilter(n, falsePositiveRate)
+	added := 0
+	err := scanHashList(r, func(digest sha1Digest) error {
+		if added++; added > n {
+			return fmt.Errorf("List has more than the %d hashes the Bloom filter was sized for", n)
+		}
+		b.add(digest)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// positions returns the bits a password's SHA-1 sets, using double hashing over two halves of the digest
+func (b *BloomFilter) positions(digest sha1Digest) []uint64 {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	ret := make([]uint64, b.k)
+	for i := range ret {
+		ret[i] = (h1 + uint64(i)*h2) % b.m
+	}
+	return ret
+}
+
+func (b *BloomFilter) add(digest sha1Digest) {
+	for _, p := range b.positions(digest) {
+		b.bits[p/8] |= 1 << (p % 8)
+	}
+}
+
+func (b *BloomFilter) contains(digest sha1Digest) bool {
+	for _, p := range b.positions(digest) {
+		if b.bits[p/8]&(1<<(p%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo writes the Bloom filter as the magic "PWBF", k as a big-endian uint32, m as a big-endian uint64
+// and then the m bits
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(bloomFilterMagic)+12)
+	copy(header, bloomFilterMagic)
+	binary.BigEndian.PutUint32(header[4:8], b.k)
+	binary.BigEndian.PutUint64(header[8:16], b.m)
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	n2, err := w.Write(b.bits)
+	return int64(n + n2), err
+}
+
+// readBloomFilter is the reverse of WriteTo, for a filter of size bytes in all
+func readBloomFilter(r io.Reader, size int64) (*BloomFilter, error) {
+	header := make([]byte, len(bloomFilterMagic)+12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("Failed to read Bloom filter header: %v", err)
+	}
+	if string(header[:4]) != bloomFilterMagic {
+		return nil, fmt.Errorf("Not a Bloom filter")
+	}
+	b := &BloomFilter{
+		k: binary.BigEndian.Uint32(header[4:8]),
+		m: binary.BigEndian.Uint64(header[8:16]),
+	}
+	if b.k == 0 || b.m == 0 {
+		return nil, fmt.Errorf("Bad Bloom filter parameters k=%d, m=%d", b.k, b.m)
+	}
+	// check m against what's actually there before trusting it with an allocation
+	if want := size - int64(len(header)); want < 0 || (b.m+7)/8 != uint64(want) {
+		return nil, fmt.Errorf("Bloom filter of %d bytes can't hold the m=%d bits its header claims", size, b.m)
+	}
+	b.bits = make([]byte, (b.m+7)/8)
+	if _, err := io.ReadFull(r, b.bits); err != nil {
+		return nil, fmt.Errorf("Failed to read Bloom filter bits: %v", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testBlocklist is a blocklist file in the Have I Been Pwned format
+func testBlocklist(passwords ...string) string {
+	lines := []string{"# test blocklist", ""}
+	for i, p := range passwords {
+		lines = append(lines, fmt.Sprintf("%X:%d", sha1.Sum([]byte(p)), i+1))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestIsNotBlocklisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blocklist")
+	if err != nil {
+		t.Fatalf("Failed to make blocklist directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer loadPasswordBlocklist(filepath.Join(dir, "missing"))
+
+	fn := filepath.Join(dir, "password-blocklist")
+	if err := ioutil.WriteFile(fn, []byte(testBlocklist("Password1", "letmein", "qwerty123")), 0600); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+	if err := loadPasswordBlocklist(fn); err != nil {
+		t.Fatalf("Failed to load blocklist: %v", err)
+	}
+
+	user := &User{App: "DRIVER", Uid: "LON1234", Ids: []Id{"dave.smith@example.com", "ab"}}
+	assertion := IsNotBlocklisted()
+	testCases := []struct {
+		s     string
+		valid bool
+	}{
+		{"Password1", false},
+		{"letmein", false},
+		{"LetMeIn", true}, // the blocklist is of exact passwords
+		{"correct horse battery staple", true},
+		{"mylon1234pass", false}, // contains the UID
+		{"DAVE.SMITH@example.com", false},
+		{"dave.smith99", false}, // the name part of an email
+		{"abracadabra", true},   // short IDs only match exactly
+		{"AB", false},
+	}
+	for _, tc := range testCases {
+		err := assertion(tc.s, user)
+		if (err == nil) != tc.valid {
+			t.Errorf("Expected password %v to be valid: %v, got error %v", tc.s, tc.valid, err)
+		}
+	}
+
+	// a policy with the assertion enforces it when setting a password
+	if err := user.SetPassword("qwerty123"); err == nil {
+		t.Error("Expected a blocklisted password to be rejected by the driver policy")
+	}
+
+	// an unparseable blocklist fails to load, rather than silently letting everything through
+	ioutil.WriteFile(fn, []byte("not a hash\n"), 0600)
+	if err := loadPasswordBlocklist(fn); err == nil {
+		t.Error("Expected a malformed blocklist to fail to load")
+	}
+}
+
+func TestBloomFilterBlocklist(t *testing.T) {
+	var passwords []string
+	for i := 0; i < 1000; i++ {
+		passwords = append(passwords, fmt.Sprintf("password%d", i))
+	}
+	n, err := CountHashList(strings.NewReader(testBlocklist(passwords...)))
+	if err != nil || n != len(passwords) {
+		t.Fatalf("Expected %d hashes in the list, counted %d (%v)", len(passwords), n, err)
+	}
+	filter, err := BuildBloomFilter(strings.NewReader(testBlocklist(passwords...)), n, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to build Bloom filter: %v", err)
+	}
+
+	// round trip through a file, as the service loads it
+	buf := &bytes.Buffer{}
+	if _, err := filter.WriteTo(buf); err != nil {
+		t.Fatalf("Failed to write Bloom filter: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "blocklist")
+	if err != nil {
+		t.Fatalf("Failed to make blocklist directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer loadPasswordBlocklist(filepath.Join(dir, "missing"))
+	fn := filepath.Join(dir, "password-blocklist")
+	ioutil.WriteFile(fn, buf.Bytes(), 0600)
+	if err := loadPasswordBlocklist(fn); err != nil {
+		t.Fatalf("Failed to load Bloom filter: %v", err)
+	}
+	if _, ok := blocklist.(*BloomFilter); !ok {
+		t.Fatalf("Expected the blocklist to be loaded as a Bloom filter, got %T", blocklist)
+	}
+
+	for _, p := range passwords {
+		if !isBlocklisted(p) {
+			t.Fatalf("Expected %v to be blocklisted", p)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if isBlocklisted(fmt.Sprintf("not-a-password%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("Expected about 1%% false positives, got %d in 10000", falsePositives)
+	}
+
+	ioutil.WriteFile(fn, buf.Bytes()[:buf.Len()-1], 0600)
+	if err := loadPasswordBlocklist(fn); err == nil {
+		t.Error("Expected a truncated Bloom filter to fail to load")
+	}
+
+	// a header claiming far more bits than the file holds is refused before anything is allocated for them
+	huge := append([]byte(nil), buf.Bytes()...)
+	binary.BigEndian.PutUint64(huge[8:16], 1<<62)
+	ioutil.WriteFile(fn, huge, 0600)
+	if err := loadPasswordBlocklist(fn); err == nil {
+		t.Error("Expected a Bloom filter claiming more bits than it has to fail to load")
+	}
+
+	if _, err := BuildBloomFilter(strings.NewReader(testBlocklist(passwords...)), n-1, 0.01); err == nil {
+		t.Error("Expected a list with more hashes than the filter was sized for to fail to build")
+	}
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"time"
+)
+
+// Lockout counts a single user's recent failed logins, so that we can lock them out after too many
+type Lockout struct {
+	App          Application
+	Uid          string
+	Failures     int
+	FirstFailure time.Time
+	LastFailure  time.Time
+	LockedUntil  time.Time
+}
+
+// IsLocked tests whether the user is locked out at time t
+func (l *Lockout) IsLocked(t time.Time) bool {
+	return t.Before(l.LockedUntil)
+}
+
+// Fail counts a failed login at time t against the application's policy, returning true if this failure
+// locked the user out
+func (l *Lockout) Fail(t time.Time) bool {
+	policy := policyFor(l.App)
+	if policy.LockoutThreshold <= 0 {
+		return false
+	}
+
+	// start counting afresh once the window has passed
+	if !l.IsLocked(t) && t.Sub(l.FirstFailure) >= policy.LockoutWindow {
+		l.Failures = 0
+		l.FirstFailure = t
+	}
+	l.Failures++
+	l.LastFailure = t
+
+	if l.Failures >= policy.LockoutThreshold && !l.IsLocked(t) {
+		l.LockedUntil = t.Add(policy.LockoutWindow)
+		return true
+	}
+	return false
+}
+
+// Expires returns when this record can be forgotten about, ie: when it no longer counts towards or
+// enforces a lockout
+func (l *Lockout) Expires() time.Time {
+	expires := l.FirstFailure.Add(policyFor(l.App).LockoutWindow)
+	if l.LockedUntil.After(expires) {
+		expires = l.LockedUntil
+	}
+	return expires
+}
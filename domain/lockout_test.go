@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutFail(t *testing.T) {
+	defer func(orig map[Application]Policy) { policies = orig }(policies)
+	policies = map[Application]Policy{
+		Application("ADMIN"):  {LockoutThreshold: 3, LockoutWindow: 10 * time.Minute},
+		Application("DRIVER"): {},
+	}
+
+	now := time.Unix(1378740807, 0)
+	l := &Lockout{App: Application("ADMIN"), Uid: "dave"}
+	if l.Fail(now) || l.Fail(now.Add(time.Minute)) {
+		t.Fatal("Expected not to lock before the threshold")
+	}
+	if l.IsLocked(now.Add(time.Minute)) {
+		t.Fatal("Expected not to be locked yet")
+	}
+	if !l.Fail(now.Add(2 * time.Minute)) {
+		t.Fatal("Expected third failure to lock")
+	}
+	if !l.IsLocked(now.Add(11 * time.Minute)) {
+		t.Error("Expected lockout to last for the window from the failure that locked it")
+	}
+	if l.Fail(now.Add(5 * time.Minute)) {
+		t.Error("Expected failures while locked not to lock again")
+	}
+	if l.IsLocked(now.Add(12 * time.Minute)) {
+		t.Error("Expected lockout to have ended")
+	}
+	if !l.Expires().Equal(now.Add(12 * time.Minute)) {
+		t.Errorf("Expected record to expire with the lockout, got %v", l.Expires())
+	}
+
+	// failures spread out more than the window never lock
+	l = &Lockout{App: Application("ADMIN"), Uid: "dave"}
+	for i := 0; i < 10; i++ {
+		if l.Fail(now.Add(time.Duration(i) * 6 * time.Minute)) {
+			t.Fatalf("Expected failure %d not to lock", i)
+		}
+	}
+
+	// policy without a threshold
+	l = &Lockout{App: Application("DRIVER"), Uid: "LON1234"}
+	for i := 0; i < 100; i++ {
+		l.Fail(now)
+	}
+	if l.IsLocked(now) || l.Failures != 0 {
+		t.Error("Expected no lockout without a threshold")
+	}
+}
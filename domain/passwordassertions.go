@@ -61,3 +61,17 @@ func HasNotBeenUsedIn(changes int) PasswordAssertion {
 		return nil
 	}
 }
+
+// IsNotBlocklisted mints a PasswordAssertion to test a password isn't on the blocklist of common and breached
+// passwords, and doesn't contain the user's UID or any of their IDs
+func IsNotBlocklisted() PasswordAssertion {
+	return func(newPass string, user *User) error {
+		if containsUserIds(newPass, user) {
+			return fmt.Errorf("must not contain your username, email address or other IDs")
+		}
+		if isBlocklisted(newPass) {
+			return fmt.Errorf("is too common, or has appeared in a data breach")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,337 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The algorithms passwords can be hashed with
+const (
+	HashBcrypt   = "bcrypt"
+	HashScrypt   = "scrypt"
+	HashArgon2id = "argon2id"
+)
+
+const (
+	passwordSaltSize = 16
+	passwordKeySize  = 32
+
+	scryptDefaultLogN = 15
+	scryptR           = 8
+	scryptP           = 1
+
+	argon2idDefaultTime   = 3
+	argon2idDefaultMemory = 64 * 1024
+	argon2idThreads       = 2
+
+	// The most work we'll do to check a stored hash, 16 times what the defaults (and so every policy) ask for.
+	// It's the parameters' product that's bounded, as that's what the time taken goes by, so a corrupt or planted
+	// hash can't have each login allocate a gigabyte and then spin over it for seconds.
+	scryptMaxWork     = 16 << scryptDefaultLogN * scryptR * scryptP // N * r * p
+	argon2idMaxMemory = 16 * argon2idDefaultMemory
+	argon2idMaxWork   = 16 * argon2idDefaultTime * argon2idDefaultMemory // t * m
+)
+
+// PasswordHashing is how an application hashes new passwords. Cost is the algorithm's work factor: the bcrypt
+// cost, the number of Argon2id passes or log2 of scrypt's N. Memory is Argon2id's memory in KiB, and ignored
+// by the others. Zero values mean bcrypt, and each algorithm's default cost.
+type PasswordHashing struct {
+	Algorithm string
+	Cost      int
+	Memory    uint32
+}
+
+// passwordHasher makes and checks hashes for a single algorithm
+type passwordHasher interface {
+	// strength ranks the algorithm, so we only ever rehash into a stronger one
+	strength() int
+	// withDefaults fills in any zero parameters
+	withDefaults(params PasswordHashing) PasswordHashing
+	hash(plain []byte, params PasswordHashing) ([]byte, error)
+	// compare returns nil if hash was made from plain
+	compare(hash, plain []byte) error
+	// params returns the parameters hash was made with
+	params(hash []byte) (PasswordHashing, error)
+}
+
+// hashers holds every algorithm we can check passwords against, keyed on the prefix of the hashes it makes
+var hashers = map[string]passwordHasher{
+	"$2a$":       bcryptHasher{},
+	"$2b$":       bcryptHasher{},
+	"$2y$":       bcryptHasher{},
+	"$scrypt$":   scryptHasher{},
+	"$argon2id$": argon2idHasher{},
+}
+
+// hasherNamed finds the hasher for an algorithm, as named in PasswordHashing
+func hasherNamed(algorithm string) (passwordHasher, error) {
+	switch algorithm {
+	case HashBcrypt, "":
+		return bcryptHasher{}, nil
+	case HashScrypt:
+		return scryptHasher{}, nil
+	case HashArgon2id:
+		return argon2idHasher{}, nil
+	}
+	return nil, fmt.Errorf("Unknown password hashing algorithm '%v'", algorithm)
+}
+
+// hasherFor finds the hasher that made a hash, peppered or not, or nil if we don't recognise it
+func hasherFor(hash []byte) passwordHasher {
+	_, hash, err := splitPepper(hash)
+	if err != nil {
+		return nil
+	}
+	for prefix, h := range hashers {
+		if strings.HasPrefix(string(hash), prefix) {
+			return h
+		}
+	}
+	return nil
+}
+
+// hashPassword hashes a new password the way an application's policy says, peppering it first if we have a
+// pepper
+func hashPassword(app Application, plain []byte) ([]byte, error) {
+	params := policyFor(app).PasswordHashing
+	h, err := hasherNamed(params.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	version, pepper := activePepper()
+	if version != 0 {
+		plain = applyPepper(pepper, plain)
+	}
+	hash, err := h.hash(plain, h.withDefaults(params))
+	if err != nil {
+		return nil, err
+	}
+	return pepperHash(version, hash), nil
+}
+
+// compareHash returns nil if hash, in any format we have a hasher for, was made from plain
+func compareHash(hash, plain []byte) error {
+	version, hash, err := splitPepper(hash)
+	if err != nil {
+		return err
+	}
+	h := hasherFor(hash)
+	if h == nil {
+		return fmt.Errorf("Unrecognised password hash format")
+	}
+	if version != 0 {
+		pepper, ok := pepperFor(version)
+		if !ok {
+			return fmt.Errorf("Unknown pepper version %v", version)
+		}
+		plain = applyPepper(pepper, plain)
+	}
+	return h.compare(hash, plain)
+}
+
+// needsRehash tests whether a hash was made with a weaker algorithm, or a lower cost, than an application's
+// policy now asks for, or with a pepper other than the current one
+func needsRehash(app Application, hash []byte) bool {
+	version, hash, err := splitPepper(hash)
+	have := hasherFor(hash)
+	want, wantErr := hasherNamed(policyFor(app).PasswordHashing.Algorithm)
+	if err != nil || have == nil || wantErr != nil {
+		return false
+	}
+	if current, _ := activePepper(); version != current {
+		return true
+	}
+	if have.strength() != want.strength() {
+		return have.strength() < want.strength()
+	}
+
+	params, err := have.params(hash)
+	if err != nil {
+		return false
+	}
+	wanted := want.withDefaults(policyFor(app).PasswordHashing)
+	return params.Cost < wanted.Cost || params.Memory < wanted.Memory
+}
+
+// bcryptHasher makes standard $2a$ bcrypt hashes
+type bcryptHasher struct{}
+
+func (bcryptHasher) strength() int { return 1 }
+
+func (bcryptHasher) withDefaults(params PasswordHashing) PasswordHashing {
+	if params.Cost == 0 {
+		params.Cost = bcryptCost
+	}
+	return PasswordHashing{Algorithm: HashBcrypt, Cost: params.Cost}
+}
+
+func (bcryptHasher) hash(plain []byte, params PasswordHashing) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(plain, params.Cost)
+}
+
+func (bcryptHasher) compare(hash, plain []byte) error {
+	return bcrypt.CompareHashAndPassword(hash, plain)
+}
+
+func (bcryptHasher) params(hash []byte) (PasswordHashing, error) {
+	cost, err := bcrypt.Cost(hash)
+	return PasswordHashing{Algorithm: HashBcrypt, Cost: cost}, err
+}
+
+// scryptHasher makes hashes of the form $scrypt$ln=15,r=8,p=1$<salt>$<key>
+type scryptHasher struct{}
+
+func (scryptHasher) strength() int { return 2 }
+
+func (scryptHasher) withDefaults(params PasswordHashing) PasswordHashing {
+	if params.Cost == 0 {
+		params.Cost = scryptDefaultLogN
+	}
+	return PasswordHashing{Algorithm: HashScrypt, Cost: params.Cost}
+}
+
+func (scryptHasher) hash(plain []byte, params PasswordHashing) ([]byte, error) {
+	salt, err := newPasswordSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(plain, salt, 1<<uint(params.Cost), scryptR, scryptP, passwordKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return encodePasswordHash(HashScrypt, fmt.Sprintf("ln=%d,r=%d,p=%d", params.Cost, scryptR, scryptP), salt, key), nil
+}
+
+func (scryptHasher) compare(hash, plain []byte) error {
+	settings, salt, key, err := decodePasswordHash(HashScrypt, hash)
+	if err != nil {
+		return err
+	}
+	if settings["ln"] <= 0 || settings["ln"] > 30 || settings["r"] <= 0 || settings["p"] <= 0 {
+		return fmt.Errorf("Bad scrypt parameters")
+	}
+	// divided down rather than multiplied up, so that huge parameters can't overflow their way past
+	if limit := scryptMaxWork >> uint(settings["ln"]); settings["r"] > limit || settings["p"] > limit/settings["r"] {
+		return fmt.Errorf("scrypt parameters exceed our limits")
+	}
+	derived, err := scrypt.Key(plain, salt, 1<<uint(settings["ln"]), settings["r"], settings["p"], len(key))
+	if err != nil {
+		return err
+	}
+	return compareKeys(key, derived)
+}
+
+func (scryptHasher) params(hash []byte) (PasswordHashing, error) {
+	settings, _, _, err := decodePasswordHash(HashScrypt, hash)
+	return PasswordHashing{Algorithm: HashScrypt, Cost: settings["ln"]}, err
+}
+
+// argon2idHasher makes hashes in the reference implementation's format,
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type argon2idHasher struct{}
+
+func (argon2idHasher) strength() int { return 3 }
+
+func (argon2idHasher) withDefaults(params PasswordHashing) PasswordHashing {
+	if params.Cost == 0 {
+		params.Cost = argon2idDefaultTime
+	}
+	if params.Memory == 0 {
+		params.Memory = argon2idDefaultMemory
+	}
+	return PasswordHashing{Algorithm: HashArgon2id, Cost: params.Cost, Memory: params.Memory}
+}
+
+func (argon2idHasher) hash(plain []byte, params PasswordHashing) ([]byte, error) {
+	salt, err := newPasswordSalt()
+	if err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey(plain, salt, uint32(params.Cost), params.Memory, argon2idThreads, passwordKeySize)
+	settings := fmt.Sprintf("v=%d$m=%d,t=%d,p=%d", argon2.Version, params.Memory, params.Cost, argon2idThreads)
+	return encodePasswordHash(HashArgon2id, settings, salt, key), nil
+}
+
+func (argon2idHasher) compare(hash, plain []byte) error {
+	settings, salt, key, err := decodePasswordHash(HashArgon2id, hash)
+	if err != nil {
+		return err
+	}
+	if settings["v"] != argon2.Version || settings["t"] <= 0 || settings["m"] <= 0 || settings["p"] <= 0 || settings["p"] > 255 {
+		return fmt.Errorf("Bad Argon2id parameters")
+	}
+	if settings["m"] > argon2idMaxMemory || settings["t"] > argon2idMaxWork/settings["m"] {
+		return fmt.Errorf("Argon2id parameters exceed our limits")
+	}
+	derived := argon2.IDKey(plain, salt, uint32(settings["t"]), uint32(settings["m"]), uint8(settings["p"]), uint32(len(key)))
+	return compareKeys(key, derived)
+}
+
+func (argon2idHasher) params(hash []byte) (PasswordHashing, error) {
+	settings, _, _, err := decodePasswordHash(HashArgon2id, hash)
+	return PasswordHashing{Algorithm: HashArgon2id, Cost: settings["t"], Memory: uint32(settings["m"])}, err
+}
+
+func newPasswordSalt() ([]byte, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("Failed to generate salt: %v", err)
+	}
+	return salt, nil
+}
+
+// encodePasswordHash formats a hash as $<algorithm>$<settings>$<salt>$<key>, where settings are name=value pairs
+// separated by commas (or $), and salt and key are unpadded base64
+func encodePasswordHash(algorithm, settings string, salt, key []byte) []byte {
+	b64 := base64.RawStdEncoding.EncodeToString
+	return []byte("$" + algorithm + "$" + settings + "$" + b64(salt) + "$" + b64(key))
+}
+
+// decodePasswordHash is the reverse of encodePasswordHash
+func decodePasswordHash(algorithm string, hash []byte) (map[string]int, []byte, []byte, error) {
+	parts := strings.Split(string(hash), "$")
+	if len(parts) < 5 || parts[0] != "" || parts[1] != algorithm {
+		return nil, nil, nil, fmt.Errorf("Malformed %v hash", algorithm)
+	}
+
+	settings := make(map[string]int)
+	for _, part := range parts[2 : len(parts)-2] {
+		for _, kv := range strings.Split(part, ",") {
+			nv := strings.SplitN(kv, "=", 2)
+			if len(nv) != 2 {
+				return nil, nil, nil, fmt.Errorf("Malformed %v hash setting '%v'", algorithm, kv)
+			}
+			i, err := strconv.Atoi(nv[1])
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("Malformed %v hash setting '%v'", algorithm, kv)
+			}
+			settings[nv[0]] = i
+		}
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[len(parts)-2])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Malformed %v hash salt: %v", algorithm, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[len(parts)-1])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, fmt.Errorf("Malformed %v hash key", algorithm)
+	}
+	return settings, salt, key, nil
+}
+
+// compareKeys compares derived keys in constant time, returning the same error as bcrypt on a mismatch
+func compareKeys(want, got []byte) error {
+	if subtle.ConstantTimeCompare(want, got) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
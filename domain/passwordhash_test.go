@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	testCases := []struct {
+		params PasswordHashing
+		prefix string
+	}{
+		{PasswordHashing{Algorithm: HashBcrypt, Cost: bcrypt.MinCost}, "$2a$"},
+		{PasswordHashing{Algorithm: HashScrypt, Cost: 10}, "$scrypt$ln=10,r=8,p=1$"},
+		{PasswordHashing{Algorithm: HashArgon2id, Cost: 1, Memory: 1024}, "$argon2id$v=19$m=1024,t=1,p=2$"},
+	}
+	for _, tc := range testCases {
+		h, err := hasherNamed(tc.params.Algorithm)
+		if err != nil {
+			t.Fatalf("No hasher for %v: %v", tc.params.Algorithm, err)
+		}
+		hash, err := h.hash([]byte("foobarbaz"), tc.params)
+		if err != nil {
+			t.Fatalf("Failed to hash with %v: %v", tc.params.Algorithm, err)
+		}
+		if !strings.HasPrefix(string(hash), tc.prefix) {
+			t.Errorf("Expected %v hash to start %v, got %s", tc.params.Algorithm, tc.prefix, hash)
+		}
+		if err := compareHash(hash, []byte("foobarbaz")); err != nil {
+			t.Errorf("Expected %v hash to match its password: %v", tc.params.Algorithm, err)
+		}
+		if err := compareHash(hash, []byte("foobarbax")); err == nil {
+			t.Errorf("Expected %v hash not to match another password", tc.params.Algorithm)
+		}
+		if params, err := h.params(hash); err != nil || params != tc.params {
+			t.Errorf("Expected %v hash parameters %+v, got %+v (%v)", tc.params.Algorithm, tc.params, params, err)
+		}
+	}
+
+	if err := compareHash([]byte("$argon2id$v=19$m=1024$garbage"), []byte("foobarbaz")); err == nil {
+		t.Error("Expected a malformed hash not to match")
+	}
+	if _, err := hasherNamed("md5"); err == nil {
+		t.Error("Expected no hasher for an unknown algorithm")
+	}
+}
+
+func TestPasswordHashLimits(t *testing.T) {
+	// none of these should be worked through, or we'd be here a while
+	hashes := []string{
+		"$scrypt$ln=20,r=1024,p=1$c2FsdA$a2V5",
+		"$scrypt$ln=10,r=8,p=1000000$c2FsdA$a2V5",
+		"$argon2id$v=19$m=67108864,t=1,p=2$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1000000,p=2$c2FsdA$a2V5",
+		// each within bounds, but not all together
+		"$scrypt$ln=20,r=8,p=1$c2FsdA$a2V5",
+		"$scrypt$ln=15,r=64,p=8$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1048576,t=48,p=2$c2FsdA$a2V5",
+	}
+	for _, hash := range hashes {
+		if err := compareHash([]byte(hash), []byte("foobarbaz")); err == nil {
+			t.Errorf("Expected %v to be refused", hash)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash := func(params PasswordHashing) []byte {
+		h, _ := hasherNamed(params.Algorithm)
+		b, err := h.hash([]byte("foobarbaz"), params)
+		if err != nil {
+			t.Fatalf("Failed to hash with %v: %v", params.Algorithm, err)
+		}
+		return b
+	}
+
+	testCases := []struct {
+		app    Application
+		hash   []byte
+		rehash bool
+	}{
+		// admins are on Argon2id (made cheap for tests)
+		{"ADMIN", hash(PasswordHashing{Algorithm: HashBcrypt, Cost: bcrypt.MinCost}), true},
+		{"ADMIN", hash(PasswordHashing{Algorithm: HashScrypt, Cost: 10}), true},
+		{"ADMIN", hash(PasswordHashing{Algorithm: HashArgon2id, Cost: 1, Memory: 512}), true},
+		{"ADMIN", hash(PasswordHashing{Algorithm: HashArgon2id, Cost: 1, Memory: 1024}), false},
+		{"ADMIN", hash(PasswordHashing{Algorithm: HashArgon2id, Cost: 2, Memory: 1024}), false},
+		// drivers are on bcrypt, and never downgraded
+		{"DRIVER", hash(PasswordHashing{Algorithm: HashBcrypt, Cost: bcrypt.MinCost}), false},
+		{"DRIVER", hash(PasswordHashing{Algorithm: HashArgon2id, Cost: 1, Memory: 1024}), false},
+		// H1 hashes are migrated separately
+		{"DRIVER", []byte("0123456789abcdef0123456789abcdef"), false},
+	}
+	for i, tc := range testCases {
+		if rehash := needsRehash(tc.app, tc.hash); rehash != tc.rehash {
+			t.Errorf("Case %d: expected needsRehash for %v %.20s... to be %v", i, tc.app, tc.hash, tc.rehash)
+		}
+	}
+}
+
+func TestUserRehash(t *testing.T) {
+	u := &User{App: "ADMIN", Uid: "dave"}
+	old, _ := bcrypt.GenerateFromPassword([]byte("Foobarbaz1"), bcrypt.MinCost)
+	u.Password = old
+	u.PasswordHistory = [][]byte{[]byte("older"), old}
+	if !u.NeedsRehash() {
+		t.Fatal("Expected a bcrypt hash to need rehashing for an admin")
+	}
+
+	if err := u.Rehash([]byte("Foobarbaz1")); err != nil {
+		t.Fatalf("Failed to rehash: %v", err)
+	}
+	if u.NeedsRehash() {
+		t.Error("Expected no further rehash")
+	}
+	if err := u.PasswordMatches([]byte("Foobarbaz1")); err != nil {
+		t.Errorf("Expected the rehashed password to match: %v", err)
+	}
+	if string(u.PasswordHistory[1]) != string(u.Password) || string(u.PasswordHistory[0]) != "older" {
+		t.Error("Expected the rehash to replace the current password in the history, and nothing else")
+	}
+	if !u.InPasswordHistory([]byte("Foobarbaz1"), 1) {
+		t.Error("Expected the history to recognise an Argon2id hash")
+	}
+	if !u.PasswordChange.IsZero() {
+		t.Error("Expected a rehash not to count as a password change")
+	}
+}
@@ -1,5 +1,9 @@
 package domain
 
+import (
+	"time"
+)
+
 // policies stores our hard-coded list of policies per-application
 // we're hardcoding right now because we don't trust config service to be secure,
 // we don't expect them to change often and if they do we want it to be more than
@@ -9,12 +13,36 @@ var policies map[Application]Policy = map[Application]Policy{
 	Application("DRIVER"): {
 		NewPasswordChecks: []PasswordAssertion{
 			MinimumPasswordLength(5),
+			IsNotBlocklisted(),
 		},
+		LockoutThreshold: 10,
+		LockoutWindow:    15 * time.Minute,
+		// drivers and passengers share IPs behind carrier NAT
+		IpRateLimit:       RateLimit{Burst: 60, Interval: time.Second},
+		UsernameRateLimit: RateLimit{Burst: 10, Interval: 6 * time.Second},
+		RefreshTokenTtl:   30 * 24 * time.Hour,
+		// drivers and passengers stay signed in for as long as they keep using the app, within reason
+		TokenTtl:           8 * time.Hour,
+		TokenRenewWindow:   30 * time.Minute,
+		MaxSessionLifetime: 90 * 24 * time.Hour,
+		NoAutoRenewRoles:   []string{"ADMIN"},
+		PasswordResetTtl:   30 * time.Minute,
 	},
 	Application("PASSENGER"): {
 		NewPasswordChecks: []PasswordAssertion{
 			MinimumPasswordLength(5),
+			IsNotBlocklisted(),
 		},
+		LockoutThreshold:   10,
+		LockoutWindow:      15 * time.Minute,
+		IpRateLimit:        RateLimit{Burst: 60, Interval: time.Second},
+		UsernameRateLimit:  RateLimit{Burst: 10, Interval: 6 * time.Second},
+		RefreshTokenTtl:    30 * 24 * time.Hour,
+		TokenTtl:           8 * time.Hour,
+		TokenRenewWindow:   30 * time.Minute,
+		MaxSessionLifetime: 90 * 24 * time.Hour,
+		NoAutoRenewRoles:   []string{"ADMIN"},
+		PasswordResetTtl:   30 * time.Minute,
 	},
 	Application("ADMIN"): {
 		NewPasswordChecks: []PasswordAssertion{
@@ -23,8 +51,25 @@ var policies map[Application]Policy = map[Application]Policy{
 			HasLowerCaseChar(),
 			HasNumericChar(),
 			HasNotBeenUsedIn(4),
+			IsNotBlocklisted(),
+			// character classes alone let through the likes of Password1
+			HasMinimumStrength(StrengthSafelyUnguessable),
 		},
-		PasswordValidFor: 60,
+		PasswordValidFor:  60,
+		LockoutThreshold:  5,
+		LockoutWindow:     30 * time.Minute,
+		IpRateLimit:       RateLimit{Burst: 20, Interval: 3 * time.Second},
+		UsernameRateLimit: RateLimit{Burst: 5, Interval: 12 * time.Second},
+		// admins can't auto-renew, so refreshing is the only way to stay signed in for longer than a token
+		RefreshTokenTtl: 24 * time.Hour,
+		// admins tend to be signed in from several browsers at once
+		MaxSessionsPerDeviceType: 5,
+		IdleTimeout:              time.Hour,
+		// a working day, and a refresh token can take a session no further than the end of the next
+		TokenTtl:           8 * time.Hour,
+		MaxSessionLifetime: 24 * time.Hour,
+		NoAutoRenewRoles:   []string{"ADMIN"},
+		PasswordHashing:    PasswordHashing{Algorithm: HashArgon2id, Cost: 3, Memory: 64 * 1024},
 	},
 }
 
@@ -33,4 +78,12 @@ var defaultPolicy Policy = Policy{
 	NewPasswordChecks: []PasswordAssertion{
 		MinimumPasswordLength(5),
 	},
+	LockoutThreshold:  10,
+	LockoutWindow:     15 * time.Minute,
+	IpRateLimit:       RateLimit{Burst: 60, Interval: time.Second},
+	UsernameRateLimit: RateLimit{Burst: 10, Interval: 6 * time.Second},
+	RefreshTokenTtl:   7 * 24 * time.Hour,
+	TokenTtl:          8 * time.Hour,
+	TokenRenewWindow:  30 * time.Minute,
+	NoAutoRenewRoles:  []string{"ADMIN"},
 }
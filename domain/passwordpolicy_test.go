@@ -65,20 +65,21 @@ func TestSetElasticRidePassword(t *testing.T) {
 		s     string
 		valid bool
 	}{
-		{"password1", false},   // no upper
-		{"PASSWORD1", false},   // no lower
-		{"Password", false},    // no number
-		{"Ab345", false},       // too short
-		{"Password1", true},    // password policy ftw!	1st pass set
-		{"fooBar11!£", true},   // having chars is ok		2nd pass set
-		{"Password1", false},   // can't reuse this yet
-		{"Password12", true},   // ok						3rd pass set
-		{"Password1", false},   // can't reuse this yet
-		{"Password123", true},  // ok						4th pass set
-		{"Password1", false},   // can't reuse this yet
-		{"Password1234", true}, // ok						4th pass set
-		{"Password1", true},    // can finally reuse this!
-		{"Password1", false},   // but not again
+		{"password1", false},      // no upper
+		{"PASSWORD1", false},      // no lower
+		{"Password", false},       // no number
+		{"Ab345", false},          // too short
+		{"Password1", false},      // too easy to guess, for all it ticks the boxes
+		{"Harbour8Velvet", true},  // password policy ftw!	1st pass set
+		{"fooBar11!£", true},      // having chars is ok		2nd pass set
+		{"Harbour8Velvet", false}, // can't reuse this yet
+		{"Marble7Canyon", true},   // ok						3rd pass set
+		{"Harbour8Velvet", false}, // can't reuse this yet
+		{"Copper4Meadow", true},   // ok						4th pass set
+		{"Harbour8Velvet", false}, // can't reuse this yet
+		{"Lantern9Orchid", true},  // ok						4th pass set
+		{"Harbour8Velvet", true},  // can finally reuse this!
+		{"Harbour8Velvet", false}, // but not again
 	}
 
 	for _, tc := range testCases {
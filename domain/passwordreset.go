@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// PasswordResetToken lets a user who has forgotten their password set a new one, once. As with refresh
+// tokens, we only store its hash.
+type PasswordResetToken struct {
+	// Hash is the hex SHA-256 of the reset token sent to the user
+	Hash    string
+	App     Application
+	Uid     string
+	Created time.Time
+	Expires time.Time
+	// PasswordChanged is the user's PasswordChange when the token was issued; any change since (including
+	// completing another reset) invalidates the token
+	PasswordChanged time.Time
+	// Used is when the token was exchanged for a new password
+	Used time.Time
+}
+
+// HashPasswordResetToken returns the hash we store a reset token under
+func HashPasswordResetToken(plain string) string {
+	h := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(h[:])
+}
+
+// IsUsed tests whether the reset token has already been used
+func (r *PasswordResetToken) IsUsed() bool {
+	return !r.Used.IsZero()
+}
+
+// HasExpired tests whether the reset token has expired at time t
+func (r *PasswordResetToken) HasExpired(t time.Time) bool {
+	return !r.Expires.After(t)
+}
+
+// IsValidFor tests whether the reset token can still be used to reset a user's password at time t
+func (r *PasswordResetToken) IsValidFor(user *User, t time.Time) bool {
+	return !r.IsUsed() && !r.HasExpired(t) && user != nil && user.App == r.App && user.Uid == r.Uid &&
+		user.PasswordChange.Equal(r.PasswordChanged)
+}
+
+// PasswordResetTtl returns how long password reset tokens last for an application, 0 meaning users can't
+// reset their own passwords
+func PasswordResetTtl(app Application) time.Duration {
+	return policyFor(app).PasswordResetTtl
+}
@@ -0,0 +1,425 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Password strength is estimated as in a cut-down zxcvbn (https://github.com/dropbox/zxcvbn): we find the
+// parts of a password an attacker would guess as a whole (common passwords and words, the user's own IDs,
+// keyboard runs, sequences, repeats and years), estimate how many guesses each would take, then find the
+// cheapest way of guessing the whole password from those parts plus brute force.
+
+// Password strength scores, on zxcvbn's scale
+const (
+	// StrengthTooGuessable is under 10^3 guesses: risky
+	StrengthTooGuessable = iota
+	// StrengthVeryGuessable is under 10^6 guesses: protection from throttled online attacks
+	StrengthVeryGuessable
+	// StrengthSomewhatGuessable is under 10^8 guesses: protection from unthrottled online attacks
+	StrengthSomewhatGuessable
+	// StrengthSafelyUnguessable is under 10^10 guesses: moderate protection from an offline attack
+	StrengthSafelyUnguessable
+	// StrengthVeryUnguessable is 10^10 guesses or more: strong protection from an offline attack
+	StrengthVeryUnguessable
+)
+
+const (
+	bruteforceCardinality           = 10
+	minSubmatchGuessesSingleChar    = 10
+	minSubmatchGuessesMultiChar     = 50
+	minGuessesBeforeGrowingSequence = 10000
+	keyboardStartingPositions       = 94
+	keyboardAverageDegree           = 4.6
+	minYearSpace                    = 20
+	// maxStrengthInput is as much of a password as we look at; anything longer is very unguessable anyway
+	maxStrengthInput = 64
+)
+
+// commonPasswords are ranked by how often they turn up, so "123456" takes one guess
+var commonPasswords = rankWords(strings.Fields(`
+	123456 password 12345678 qwerty 123456789 12345 1234 111111 1234567 dragon 123123 baseball abc123
+	football monkey letmein 696969 shadow master 666666 qwertyuiop 123321 mustang 1234567890 michael
+	654321 superman 1qaz2wsx 7777777 121212 000000 qazwsx 123qwe killer trustno1 jordan jennifer zxcvbnm
+	asdfgh hunter buster soccer harley batman andrew tigger sunshine iloveyou 2000 charlie robert thomas
+	hockey ranger daniel starwars klaster 112233 george computer michelle jessica pepper 1111 zxcvbn
+	555555 11111111 131313 freedom 777777 pass maggie 159753 aaaaaa ginger princess joshua cheese amanda
+	summer love ashley nicole chelsea biteme matthew access yankees 987654321 dallas austin thunder taylor
+	matrix welcome admin administrator login passw0rd p@ssword changeme secret default guest root hello
+	london hailo taxi cab driver passenger ride elastic spring autumn winter monday friday january
+	december football1 password1 password123 qwerty123 welcome1 letmein1 abc iloveyou1 monkey1 dragon1
+`))
+
+// qwertyRows are the keyboard rows we look for runs along, unshifted
+var qwertyRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// l33tSubstitutions are the common l33t speak substitutions, tried one table at a time
+var l33tSubstitutions = []map[rune]rune{
+	{'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z'},
+	{'1': 'l', '|': 'l', '9': 'g', '%': 'x'},
+}
+
+// strengthMatch is a part of a password, from rune i to j inclusive, that takes a number of guesses
+type strengthMatch struct {
+	i, j       int
+	guesses    float64
+	bruteforce bool
+}
+
+// PasswordStrength scores how hard a password would be to guess for a user, from StrengthTooGuessable to
+// StrengthVeryUnguessable
+func PasswordStrength(plain string, user *User) int {
+	var inputs []string
+	if user != nil {
+		inputs = append(inputs, user.Uid, string(user.App))
+		for _, id := range user.Ids {
+			inputs = append(inputs, string(id))
+			if at := strings.LastIndex(string(id), "@"); at > 0 {
+				inputs = append(inputs, string(id)[:at])
+			}
+		}
+	}
+	return scoreFromGuesses(estimateGuesses([]rune(plain), rankWords(inputs)))
+}
+
+// HasMinimumStrength mints a PasswordAssertion to test a password is at least as hard to guess as a score
+// (see PasswordStrength)
+func HasMinimumStrength(score int) PasswordAssertion {
+	return func(newPass string, user *User) error {
+		if got := PasswordStrength(newPass, user); got < score {
+			return fmt.Errorf("is too easy to guess (strength %v, needs at least %v out of %v)", got, score,
+				StrengthVeryUnguessable)
+		}
+		return nil
+	}
+}
+
+func scoreFromGuesses(guesses float64) int {
+	const delta = 5
+	switch {
+	case guesses < 1e3+delta:
+		return StrengthTooGuessable
+	case guesses < 1e6+delta:
+		return StrengthVeryGuessable
+	case guesses < 1e8+delta:
+		return StrengthSomewhatGuessable
+	case guesses < 1e10+delta:
+		return StrengthSafelyUnguessable
+	}
+	return StrengthVeryUnguessable
+}
+
+// estimateGuesses finds the fewest guesses that would crack a password, as zxcvbn's
+// most_guessable_match_sequence: a sequence of l matches whose guesses multiply to pi costs l! * pi guesses,
+// plus a penalty for each extra match so that we don't favour chopping the password into tiny pieces
+func estimateGuesses(password []rune, userInputs map[string]int) float64 {
+	if len(password) > maxStrengthInput {
+		password = password[:maxStrengthInput]
+	}
+	n := len(password)
+	if n == 0 {
+		return 1
+	}
+
+	byEnd := make([][]strengthMatch, n)
+	for _, m := range findStrengthMatches(password, userInputs) {
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	// optimal[k][l] is the best sequence of l matches covering the password up to rune k
+	type candidate struct {
+		pi, g          float64
+		lastBruteforce bool
+	}
+	optimal := make([]map[int]candidate, n)
+	for k := range optimal {
+		optimal[k] = make(map[int]candidate)
+	}
+	update := func(m strengthMatch, l int, pi float64) {
+		pi *= matchGuesses(m, n)
+		g := factorial(l)*pi + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+		for otherL, other := range optimal[m.j] {
+			if otherL <= l && other.g <= g {
+				return
+			}
+		}
+		optimal[m.j][l] = candidate{pi: pi, g: g, lastBruteforce: m.bruteforce}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range byEnd[k] {
+			if m.i == 0 {
+				update(m, 1, 1)
+				continue
+			}
+			for l, prev := range optimal[m.i-1] {
+				update(m, l+1, prev.pi)
+			}
+		}
+
+		// brute force the whole password so far, or anything after a match that wasn't brute force itself
+		update(bruteforceMatch(0, k), 1, 1)
+		for i := 1; i <= k; i++ {
+			for l, prev := range optimal[i-1] {
+				if !prev.lastBruteforce {
+					update(bruteforceMatch(i, k), l+1, prev.pi)
+				}
+			}
+		}
+	}
+
+	best := math.Inf(1)
+	for _, c := range optimal[n-1] {
+		best = math.Min(best, c.g)
+	}
+	return best
+}
+
+// matchGuesses floors a match's guesses, so that parts of a password are never thought trivially guessable
+func matchGuesses(m strengthMatch, passwordLength int) float64 {
+	length := m.j - m.i + 1
+	if m.bruteforce {
+		if length == 1 {
+			return math.Max(m.guesses, minSubmatchGuessesSingleChar+1)
+		}
+		return math.Max(m.guesses, minSubmatchGuessesMultiChar+1)
+	}
+	if length < passwordLength {
+		if length == 1 {
+			return math.Max(m.guesses, minSubmatchGuessesSingleChar)
+		}
+		return math.Max(m.guesses, minSubmatchGuessesMultiChar)
+	}
+	return math.Max(m.guesses, 1)
+}
+
+func bruteforceMatch(i, j int) strengthMatch {
+	return strengthMatch{i: i, j: j, guesses: math.Pow(bruteforceCardinality, float64(j-i+1)), bruteforce: true}
+}
+
+func findStrengthMatches(password []rune, userInputs map[string]int) []strengthMatch {
+	var matches []strengthMatch
+	matches = append(matches, dictionaryMatches(password, userInputs)...)
+	matches = append(matches, keyboardMatches(password)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, repeatMatches(password, userInputs)...)
+	matches = append(matches, yearMatches(password)...)
+	return matches
+}
+
+// dictionaryMatches finds common passwords and the user's own IDs, forwards, reversed and in l33t speak
+func dictionaryMatches(password []rune, userInputs map[string]int) []strengthMatch {
+	lower := []rune(strings.ToLower(string(password)))
+	var matches []strengthMatch
+	lookup := func(word string) (int, bool) {
+		if rank, ok := userInputs[word]; ok {
+			return rank, true
+		}
+		rank, ok := commonPasswords[word]
+		return rank, ok
+	}
+
+	for i := range lower {
+		for j := i; j < len(lower); j++ {
+			token := password[i : j+1]
+			word := string(lower[i : j+1])
+			variations := uppercaseVariations(token)
+			if rank, ok := lookup(word); ok {
+				matches = append(matches, strengthMatch{i: i, j: j, guesses: float64(rank) * variations})
+			}
+			if reversed := reverseString(word); reversed != word {
+				if rank, ok := lookup(reversed); ok {
+					matches = append(matches, strengthMatch{i: i, j: j, guesses: float64(rank) * variations * 2})
+				}
+			}
+			for _, table := range l33tSubstitutions {
+				unleet, subs := unl33t(lower[i:j+1], table)
+				if subs == 0 {
+					continue
+				}
+				if rank, ok := lookup(unleet); ok {
+					matches = append(matches, strengthMatch{i: i, j: j, guesses: float64(rank) * variations * math.Pow(2, float64(subs))})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// keyboardMatches finds runs of three or more keys along a row of a qwerty keyboard, in either direction
+func keyboardMatches(password []rune) []strengthMatch {
+	lower := []rune(strings.ToLower(string(password)))
+	var matches []strengthMatch
+	for i := 0; i < len(lower)-2; i++ {
+		for _, row := range qwertyRows {
+			pos := strings.IndexRune(row, lower[i])
+			if pos < 0 {
+				continue
+			}
+			for _, dir := range []int{1, -1} {
+				j, p := i, pos
+				for j+1 < len(lower) && p+dir >= 0 && p+dir < len(row) && rune(row[p+dir]) == lower[j+1] {
+					j, p = j+1, p+dir
+				}
+				if length := j - i + 1; length >= 3 {
+					guesses := keyboardStartingPositions * keyboardAverageDegree * float64(length-1)
+					matches = append(matches, strengthMatch{i: i, j: j, guesses: guesses * uppercaseVariations(password[i:j+1])})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// sequenceMatches finds runs of three or more characters a constant step apart, eg: "abc", "9753"
+func sequenceMatches(password []rune) []strengthMatch {
+	var matches []strengthMatch
+	for i := 0; i < len(password)-2; i++ {
+		delta := int(password[i+1]) - int(password[i])
+		if delta == 0 || delta > 5 || delta < -5 {
+			continue
+		}
+		j := i + 1
+		for j+1 < len(password) && int(password[j+1])-int(password[j]) == delta {
+			j++
+		}
+		if j-i+1 < 3 {
+			continue
+		}
+		base := 26.0
+		if strings.ContainsRune("aAzZ019", password[i]) {
+			base = 4
+		} else if unicode.IsDigit(password[i]) {
+			base = 10
+		}
+		if delta < 0 {
+			base *= 2
+		}
+		matches = append(matches, strengthMatch{i: i, j: j, guesses: base * float64(j-i+1)})
+	}
+	return matches
+}
+
+// repeatMatches finds a part of the password repeated two or more times, eg: "aaa", "abcabc"
+func repeatMatches(password []rune, userInputs map[string]int) []strengthMatch {
+	var matches []strengthMatch
+	for i := range password {
+		best := strengthMatch{}
+		for size := 1; i+2*size <= len(password); size++ {
+			repeats := 1
+			for i+(repeats+1)*size <= len(password) &&
+				string(password[i+repeats*size:i+(repeats+1)*size]) == string(password[i:i+size]) {
+				repeats++
+			}
+			if repeats < 2 || i+repeats*size-1 <= best.j {
+				continue
+			}
+			base := password[i : i+size]
+			best = strengthMatch{i: i, j: i + repeats*size - 1, guesses: estimateGuesses(base, userInputs) * float64(repeats)}
+		}
+		if best.guesses > 0 {
+			matches = append(matches, best)
+		}
+	}
+	return matches
+}
+
+// yearMatches finds recent years, which take as many guesses as they are far from this one
+func yearMatches(password []rune) []strengthMatch {
+	var matches []strengthMatch
+	thisYear := time.Now().Year()
+	for i := 0; i+4 <= len(password); i++ {
+		year := 0
+		for _, r := range password[i : i+4] {
+			if r < '0' || r > '9' {
+				year = -1
+				break
+			}
+			year = year*10 + int(r-'0')
+		}
+		if year < 1900 || year > 2099 {
+			continue
+		}
+		guesses := math.Max(math.Abs(float64(year-thisYear)), minYearSpace)
+		matches = append(matches, strengthMatch{i: i, j: i + 3, guesses: guesses})
+	}
+	return matches
+}
+
+// uppercaseVariations is how many ways there are of capitalising a word as it has been: trying the first,
+// last or every letter in capitals only doubles the guesses, but anything else multiplies them more
+func uppercaseVariations(token []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range token {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(token[0]) || unicode.IsUpper(token[len(token)-1]))) {
+		return 2
+	}
+	variations := 0.0
+	for i := 1; i <= upper && i <= lower; i++ {
+		variations += binomial(upper+lower, i)
+	}
+	return variations
+}
+
+// unl33t undoes l33t substitutions in a word, returning it and how many characters were substituted
+func unl33t(word []rune, table map[rune]rune) (string, int) {
+	ret := make([]rune, len(word))
+	subs := 0
+	for i, r := range word {
+		if sub, ok := table[r]; ok {
+			ret[i] = sub
+			subs++
+		} else {
+			ret[i] = r
+		}
+	}
+	return string(ret), subs
+}
+
+// rankWords maps (lower case) words to their rank, from 1
+func rankWords(words []string) map[string]int {
+	ranked := make(map[string]int, len(words))
+	for i, w := range words {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if _, ok := ranked[w]; !ok && w != "" {
+			ranked[w] = i + 1
+		}
+	}
+	return ranked
+}
+
+func reverseString(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+func binomial(n, k int) float64 {
+	r := 1.0
+	for i := 1; i <= k; i++ {
+		r = r * float64(n-k+i) / float64(i)
+	}
+	return r
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"testing"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	user := &User{App: "ADMIN", Uid: "dave", Ids: []Id{"dave.smith@example.com"}}
+	testCases := []struct {
+		s     string
+		score int
+	}{
+		{"", StrengthTooGuessable},
+		{"Password1", StrengthTooGuessable}, // ticks every character class, but is about the first guess
+		{"P@ssw0rd", StrengthTooGuessable},  // l33t speak doesn't help
+		{"qwerty123", StrengthTooGuessable},
+		{"zxcvbnm", StrengthTooGuessable}, // keyboard run
+		{"abcdefgh", StrengthTooGuessable},
+		{"aaaaaaaa", StrengthTooGuessable},
+		{"19871987", StrengthTooGuessable},
+		{"Admin123", StrengthVeryGuessable},
+		{"dave1234", StrengthVeryGuessable},       // the user's own UID
+		{"Dave.Smith2024", StrengthVeryGuessable}, // and email address
+		{"fooBar11!£", StrengthSafelyUnguessable},
+		{"Harbour8Velvet", StrengthVeryUnguessable},
+		{"correct horse battery staple", StrengthVeryUnguessable},
+	}
+	for _, tc := range testCases {
+		if score := PasswordStrength(tc.s, user); score != tc.score {
+			t.Errorf("Expected password %q to score %v, got %v", tc.s, tc.score, score)
+		}
+	}
+
+	// the same password is stronger for someone it has nothing to do with
+	if score := PasswordStrength("Dave.Smith2024", &User{App: "ADMIN", Uid: "jane"}); score <= StrengthVeryGuessable {
+		t.Errorf("Expected another user's name to score higher, got %v", score)
+	}
+}
+
+func TestPasswordFailures(t *testing.T) {
+	user := &User{App: "ADMIN", Uid: "dave"}
+	failures := PasswordFailures("Password1", user)
+	if len(failures) != 1 {
+		t.Fatalf("Expected Password1 to fail only the strength check, got %v", failures)
+	}
+	if err := HasMinimumStrength(StrengthSafelyUnguessable)("Password1", user); err == nil ||
+		failures[0].Error() != err.Error() {
+		t.Errorf("Expected a strength failure, got %v", failures[0])
+	}
+
+	if failures := PasswordFailures("password", user); len(failures) != 3 {
+		t.Errorf("Expected failures for no uppercase, no number and strength, got %v", failures)
+	}
+	if failures := PasswordFailures("Harbour8Velvet", user); len(failures) != 0 {
+		t.Errorf("Expected a strong password to pass, got %v", failures)
+	}
+
+	// drivers have no minimum strength
+	if failures := PasswordFailures("Password1", &User{App: "DRIVER", Uid: "LON1234"}); len(failures) != 0 {
+		t.Errorf("Expected Password1 to pass the driver policy, got %v", failures)
+	}
+}
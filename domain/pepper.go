@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+)
+
+// the peppers live next to the signing keys, and for the same reason aren't configurable (it's a var only so
+// tests can point it elsewhere). Each pepper is a `<version>.pepper` file, where version is a positive integer;
+// new hashes use the highest version, and hashes made with any other are re-peppered on login.
+var pepperDir = "/opt/hailo/login-service/peppers"
+
+const (
+	pepperExt     = ".pepper"
+	pepperPrefix  = "$pepper$v="
+	minPepperSize = 16
+)
+
+var (
+	pepperMtx sync.RWMutex
+	// peppers holds the secrets mixed into passwords before they are hashed, by version; currentPepper is the
+	// version new hashes use, 0 meaning we don't pepper at all
+	peppers       = map[int][]byte{}
+	currentPepper int
+)
+
+// LoadPeppers (re)loads the peppers from disk. Having no pepper directory isn't an error, it just means
+// passwords aren't peppered.
+func LoadPeppers() error {
+	return loadPeppers(pepperDir)
+}
+
+// ReloadPeppersEvery keeps reloading the peppers, so that a new version is picked up without a restart
+func ReloadPeppersEvery(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if err := LoadPeppers(); err != nil {
+			log.Warnf("[Domain] Failed to reload peppers: %v", err)
+		}
+	}
+}
+
+func loadPeppers(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		files = nil
+	} else if err != nil {
+		return fmt.Errorf("Failed to read pepper directory '%v': %v", dir, err)
+	}
+
+	loaded := make(map[int][]byte)
+	current := 0
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != pepperExt {
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimSuffix(f.Name(), pepperExt))
+		if err != nil || version <= 0 {
+			return fmt.Errorf("Bad pepper file name '%v': the version must be a positive integer", f.Name())
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return fmt.Errorf("Failed to read pepper '%v': %v", f.Name(), err)
+		}
+		pepper := bytes.TrimSpace(b)
+		if len(pepper) < minPepperSize {
+			return fmt.Errorf("Pepper '%v' is too short: it must be at least %d bytes", f.Name(), minPepperSize)
+		}
+		loaded[version] = pepper
+		if version > current {
+			current = version
+		}
+	}
+
+	pepperMtx.Lock()
+	defer pepperMtx.Unlock()
+	if current != currentPepper {
+		log.Infof("[Domain] Peppering new password hashes with version %v", current)
+	}
+	peppers = loaded
+	currentPepper = current
+	return nil
+}
+
+// pepperFor returns a pepper by version, and whether we have it
+func pepperFor(version int) ([]byte, bool) {
+	pepperMtx.RLock()
+	defer pepperMtx.RUnlock()
+	pepper, ok := peppers[version]
+	return pepper, ok
+}
+
+// activePepper returns the version and secret new hashes are peppered with, version 0 meaning none
+func activePepper() (int, []byte) {
+	pepperMtx.RLock()
+	defer pepperMtx.RUnlock()
+	return currentPepper, peppers[currentPepper]
+}
+
+// applyPepper mixes a pepper into a plain text password. The HMAC is base64 encoded so that it's safe to hand
+// to any hasher (bcrypt stops at NUL bytes).
+func applyPepper(pepper, plain []byte) []byte {
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write(plain)
+	return []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// pepperHash records the pepper version a hash was made with, as $pepper$v=<version><hash>
+func pepperHash(version int, hash []byte) []byte {
+	if version == 0 {
+		return hash
+	}
+	return append([]byte(pepperPrefix+strconv.Itoa(version)), hash...)
+}
+
+// splitPepper is the reverse of pepperHash, returning version 0 for hashes that weren't peppered
+func splitPepper(hash []byte) (int, []byte, error) {
+	if !bytes.HasPrefix(hash, []byte(pepperPrefix)) {
+		return 0, hash, nil
+	}
+	rest := hash[len(pepperPrefix):]
+	end := bytes.IndexByte(rest, '$')
+	if end < 0 {
+		return 0, nil, fmt.Errorf("Malformed peppered hash")
+	}
+	version, err := strconv.Atoi(string(rest[:end]))
+	if err != nil || version <= 0 {
+		return 0, nil, fmt.Errorf("Malformed pepper version '%s'", rest[:end])
+	}
+	return version, rest[end:], nil
+}
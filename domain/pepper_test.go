@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withPepperDir loads peppers from a temporary directory for the duration of a test
+func withPepperDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "peppers")
+	if err != nil {
+		t.Fatalf("Failed to make pepper directory: %v", err)
+	}
+	return dir, func() {
+		os.RemoveAll(dir)
+		loadPeppers(dir)
+	}
+}
+
+func writeTestPepper(t *testing.T, dir, name, pepper string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(pepper), 0600); err != nil {
+		t.Fatalf("Failed to write pepper: %v", err)
+	}
+	if err := loadPeppers(dir); err != nil {
+		t.Fatalf("Failed to load peppers: %v", err)
+	}
+}
+
+func TestPepperRotation(t *testing.T) {
+	dir, cleanup := withPepperDir(t)
+	defer cleanup()
+
+	u := &User{App: "DRIVER", Uid: "dave"}
+	if err := u.SetPassword("Foobarbaz1"); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+	unpeppered := u.Password
+
+	// turning peppering on re-peppers existing hashes, which keep working until then
+	writeTestPepper(t, dir, "1.pepper", "first-secret-pepper-value\n")
+	if err := u.PasswordMatches([]byte("Foobarbaz1")); err != nil {
+		t.Fatalf("Expected an unpeppered hash to still match: %v", err)
+	}
+	if !u.NeedsRehash() {
+		t.Fatal("Expected an unpeppered hash to need re-peppering")
+	}
+	if err := u.Rehash([]byte("Foobarbaz1")); err != nil {
+		t.Fatalf("Failed to rehash: %v", err)
+	}
+	if !strings.HasPrefix(string(u.Password), "$pepper$v=1$2a$") {
+		t.Fatalf("Expected a version 1 peppered bcrypt hash, got %s", u.Password)
+	}
+	if u.NeedsRehash() {
+		t.Error("Expected no further rehash")
+	}
+	if err := u.PasswordMatches([]byte("Foobarbaz1")); err != nil {
+		t.Errorf("Expected the peppered password to match: %v", err)
+	}
+	if err := u.PasswordMatches([]byte("Foobarbaz2")); err == nil {
+		t.Error("Expected another password not to match")
+	}
+	if !u.InPasswordHistory([]byte("Foobarbaz1"), 1) {
+		t.Error("Expected the history to recognise a peppered hash")
+	}
+
+	// the pepper is what makes the hash: without it, it doesn't match the bare password
+	_, inner, _ := splitPepper(u.Password)
+	if err := compareHash(inner, []byte("Foobarbaz1")); err == nil {
+		t.Error("Expected a peppered hash not to match without its pepper")
+	}
+
+	// rotating moves new hashes to the new version, and re-peppers the old on login
+	writeTestPepper(t, dir, "2.pepper", "second-secret-pepper-value")
+	if err := u.PasswordMatches([]byte("Foobarbaz1")); err != nil {
+		t.Errorf("Expected a version 1 hash to match after rotation: %v", err)
+	}
+	if !u.NeedsRehash() {
+		t.Error("Expected a version 1 hash to need re-peppering")
+	}
+	if err := u.SetPassword("Foobarbaz2"); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+	if !strings.HasPrefix(string(u.Password), "$pepper$v=2$") {
+		t.Errorf("Expected a version 2 peppered hash, got %s", u.Password)
+	}
+	if !u.InPasswordHistory([]byte("Foobarbaz1"), 2) {
+		t.Error("Expected the history to recognise an older pepper version")
+	}
+
+	// a hash whose pepper has gone can't be checked at all
+	os.Remove(filepath.Join(dir, "1.pepper"))
+	if err := loadPeppers(dir); err != nil {
+		t.Fatalf("Failed to load peppers: %v", err)
+	}
+	if err := compareHash(u.PasswordHistory[0], []byte("Foobarbaz1")); err == nil {
+		t.Error("Expected a hash with an unknown pepper not to match")
+	}
+	if err := compareHash(unpeppered, []byte("Foobarbaz1")); err != nil {
+		t.Errorf("Expected an unpeppered hash to still match: %v", err)
+	}
+}
+
+func TestPasswordHistoryAfterPepperRetired(t *testing.T) {
+	dir, cleanup := withPepperDir(t)
+	defer cleanup()
+
+	writeTestPepper(t, dir, "1.pepper", "first-secret-pepper-value")
+	u := &User{App: "ADMIN", Uid: "dave"}
+	if err := u.SetPassword("Harbour8Velvet"); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+	writeTestPepper(t, dir, "2.pepper", "second-secret-pepper-value")
+	if err := u.SetPassword("Marble7Canyon"); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+	if err := u.Rehash([]byte("Marble7Canyon")); err != nil {
+		t.Fatalf("Failed to rehash: %v", err)
+	}
+
+	// logging in only re-peppers the current hash, so the history still needs version 1, and dropping it
+	// mustn't let the old password back in
+	os.Remove(filepath.Join(dir, "1.pepper"))
+	if err := loadPeppers(dir); err != nil {
+		t.Fatalf("Failed to load peppers: %v", err)
+	}
+	if !u.InPasswordHistory([]byte("Harbour8Velvet"), 4) {
+		t.Error("Expected history with an unknown pepper version to fail closed")
+	}
+	if err := u.SetPassword("Harbour8Velvet"); err == nil {
+		t.Error("Expected a historic password to still be refused once its pepper is retired")
+	}
+}
+
+func TestLoadPeppers(t *testing.T) {
+	dir, cleanup := withPepperDir(t)
+	defer cleanup()
+
+	if err := loadPeppers(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("Expected no pepper directory to mean no peppers: %v", err)
+	}
+	if version, _ := activePepper(); version != 0 {
+		t.Errorf("Expected no active pepper, got version %v", version)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "1.pepper"), []byte("short"), 0600)
+	if err := loadPeppers(dir); err == nil {
+		t.Error("Expected a short pepper to be rejected")
+	}
+	os.Remove(filepath.Join(dir, "1.pepper"))
+	ioutil.WriteFile(filepath.Join(dir, "new.pepper"), []byte("first-secret-pepper-value"), 0600)
+	if err := loadPeppers(dir); err == nil {
+		t.Error("Expected a pepper without a numeric version to be rejected")
+	}
+}
+
+func TestSplitPepper(t *testing.T) {
+	testCases := []struct {
+		hash    string
+		version int
+		inner   string
+		err     bool
+	}{
+		{"$2a$10$abc", 0, "$2a$10$abc", false},
+		{"$pepper$v=3$2a$10$abc", 3, "$2a$10$abc", false},
+		{"$pepper$v=3", 0, "", true},
+		{"$pepper$v=x$2a$10$abc", 0, "", true},
+		{"$pepper$v=0$2a$10$abc", 0, "", true},
+	}
+	for _, tc := range testCases {
+		version, inner, err := splitPepper([]byte(tc.hash))
+		if (err != nil) != tc.err || version != tc.version || string(inner) != tc.inner {
+			t.Errorf("Expected %v to split to %v, %v (error %v); got %v, %s (%v)", tc.hash, tc.version, tc.inner, tc.err,
+				version, inner, err)
+		}
+	}
+	if hash := string(pepperHash(3, []byte("$2a$10$abc"))); hash != "$pepper$v=3$2a$10$abc" {
+		t.Errorf("Expected pepperHash to prefix the version, got %v", hash)
+	}
+}
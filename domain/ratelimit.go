@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"time"
+)
+
+// RateLimit defines a token bucket: Burst requests at once, refilled at one every Interval. A zero Burst
+// means no limit.
+type RateLimit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+// TokenBucket is the state of a single rate limited thing (eg: an IP address)
+type TokenBucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills the bucket for the time passed since it was last updated, then takes a token from it,
+// returning false if there were none left
+func (b *TokenBucket) Take(limit RateLimit, t time.Time) bool {
+	if limit.Burst <= 0 || limit.Interval <= 0 {
+		return true
+	}
+
+	if b.Updated.IsZero() {
+		b.Tokens = float64(limit.Burst)
+	} else if elapsed := t.Sub(b.Updated); elapsed > 0 {
+		b.Tokens += float64(elapsed) / float64(limit.Interval)
+	}
+	if b.Tokens > float64(limit.Burst) {
+		b.Tokens = float64(limit.Burst)
+	}
+	b.Updated = t
+
+	if b.Tokens < 1 {
+		return false
+	}
+	b.Tokens--
+	return true
+}
+
+// Expires returns when the bucket will be full again, after which it can be forgotten about
+func (b *TokenBucket) Expires(limit RateLimit) time.Time {
+	return b.Updated.Add(time.Duration((float64(limit.Burst) - b.Tokens) * float64(limit.Interval)))
+}
+
+// IpRateLimit returns the limit on auth requests from a single IP address for an application
+func IpRateLimit(app Application) RateLimit {
+	return policyFor(app).IpRateLimit
+}
+
+// UsernameRateLimit returns the limit on auth requests for a single username within an application
+func UsernameRateLimit(app Application) RateLimit {
+	return policyFor(app).UsernameRateLimit
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	limit := RateLimit{Burst: 3, Interval: 10 * time.Second}
+	now := time.Unix(1378740807, 0)
+	b := &TokenBucket{}
+
+	for i := 0; i < 3; i++ {
+		if !b.Take(limit, now) {
+			t.Fatalf("Expected request %d within the burst to be allowed", i)
+		}
+	}
+	if b.Take(limit, now) {
+		t.Fatal("Expected request beyond the burst to be limited")
+	}
+	if b.Take(limit, now.Add(9*time.Second)) {
+		t.Error("Expected no token before the interval")
+	}
+	if !b.Take(limit, now.Add(10*time.Second)) {
+		t.Error("Expected a token after the interval")
+	}
+	if !b.Expires(limit).Equal(now.Add(40 * time.Second)) {
+		t.Errorf("Expected bucket to be full again in 3 intervals, got %v", b.Expires(limit))
+	}
+
+	// refilling never goes beyond the burst
+	if !b.Take(limit, now.Add(time.Hour)) || b.Tokens != 2 {
+		t.Errorf("Expected bucket to refill to the burst, got %v tokens", b.Tokens)
+	}
+
+	// no limit
+	for i := 0; i < 100; i++ {
+		if !b.Take(RateLimit{}, now) {
+			t.Fatal("Expected no limit")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"crypto/rand"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeLength is in characters, from recoveryCodeAlphabet; 10 of 32 gives 50 bits
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+// NewRecoveryCodes replaces the user's recovery codes with a fresh set and returns them in plain text. Only
+// the hashes are kept, so this is the one chance to show them to the user.
+func (u *User) NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([][]byte, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(normaliseRecoveryCode(code)), bcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hash)
+	}
+	u.RecoveryCodes = hashes
+	return codes, nil
+}
+
+// UseRecoveryCode tests code against the user's recovery codes, removing it if it matches. The caller must
+// persist the user for the code to be spent.
+func (u *User) UseRecoveryCode(code string) bool {
+	code = normaliseRecoveryCode(code)
+	if len(code) != recoveryCodeLength {
+		return false
+	}
+	for i, hash := range u.RecoveryCodes {
+		if err := bcrypt.CompareHashAndPassword(hash, []byte(code)); err == nil {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i:i], u.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// newRecoveryCode generates a code formatted for people, eg: "abcde-fghij"
+func newRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = recoveryCodeAlphabet[int(b[i])%len(recoveryCodeAlphabet)]
+	}
+	half := recoveryCodeLength / 2
+	return string(b[:half]) + "-" + string(b[half:]), nil
+}
+
+// normaliseRecoveryCode forgives case, dashes and spaces, which people get wrong copying codes out
+func normaliseRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+}
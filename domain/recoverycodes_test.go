@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	user := &User{App: Application("ADMIN"), Uid: "dave"}
+
+	codes, err := user.NewRecoveryCodes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(user.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("Expected %d codes, got %d (%d hashed)", recoveryCodeCount, len(codes), len(user.RecoveryCodes))
+	}
+	for _, code := range codes {
+		if string(user.RecoveryCodes[0]) == code {
+			t.Fatal("Expected codes to be stored hashed")
+		}
+	}
+
+	if user.UseRecoveryCode("aaaaa-aaaaa") {
+		t.Error("Expected unknown code to be rejected")
+	}
+
+	// people get case and dashes wrong
+	if !user.UseRecoveryCode(strings.ToUpper(strings.Replace(codes[3], "-", "", 1))) {
+		t.Error("Expected code to be accepted")
+	}
+	if len(user.RecoveryCodes) != recoveryCodeCount-1 {
+		t.Errorf("Expected code to be spent, %d left", len(user.RecoveryCodes))
+	}
+	if user.UseRecoveryCode(codes[3]) {
+		t.Error("Expected code to only be usable once")
+	}
+	if !user.UseRecoveryCode(codes[0]) {
+		t.Error("Expected other codes to still work")
+	}
+
+	// regenerating replaces the lot
+	if _, err := user.NewRecoveryCodes(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if user.UseRecoveryCode(codes[1]) {
+		t.Error("Expected old codes to be invalidated")
+	}
+}
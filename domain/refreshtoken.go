@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// RefreshToken is a long-lived credential that can be exchanged, once, for a new token for its session. We
+// only store its hash, so a leak of the store doesn't leak usable refresh tokens.
+type RefreshToken struct {
+	// Hash is the hex SHA-256 of the refresh token given to the client
+	Hash      string
+	SessionId string
+	Created   time.Time
+	Expires   time.Time
+	// Used is when the refresh token was exchanged for a new one; using it again means it has been stolen
+	Used time.Time
+}
+
+// HashRefreshToken returns the hash we store a refresh token under
+func HashRefreshToken(plain string) string {
+	h := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(h[:])
+}
+
+// IsUsed tests whether the refresh token has already been exchanged
+func (r *RefreshToken) IsUsed() bool {
+	return !r.Used.IsZero()
+}
+
+// HasExpired tests whether the refresh token has expired at time t
+func (r *RefreshToken) HasExpired(t time.Time) bool {
+	return !r.Expires.After(t)
+}
+
+// RefreshTokenTtl returns how long refresh tokens last for an application, 0 meaning they aren't issued
+func RefreshTokenTtl(app Application) time.Duration {
+	return policyFor(app).RefreshTokenTtl
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// What tokens get if their application's policy doesn't say
+const (
+	defaultTokenTtl         = 8 * time.Hour
+	defaultTokenRenewWindow = 30 * time.Minute
+)
+
+// TokenTtl returns how long an application's tokens last from being minted or renewed
+func TokenTtl(app Application) time.Duration {
+	if ttl := policyFor(app).TokenTtl; ttl > 0 {
+		return ttl
+	}
+	return defaultTokenTtl
+}
+
+// TokenRenewWindow returns how long before expiry an application's tokens may be auto-renewed
+func TokenRenewWindow(app Application) time.Duration {
+	if window := policyFor(app).TokenRenewWindow; window > 0 {
+		return window
+	}
+	return defaultTokenRenewWindow
+}
+
+// MaxSessionLifetime returns how long after login an application's sessions can be kept alive, 0 meaning
+// for ever
+func MaxSessionLifetime(app Application) time.Duration {
+	return policyFor(app).MaxSessionLifetime
+}
+
+// MaxTokenTtl returns the longest any application's tokens last
+func MaxTokenTtl() time.Duration {
+	max := TokenTtl(Application(""))
+	for app := range policies {
+		if ttl := TokenTtl(app); ttl > max {
+			max = ttl
+		}
+	}
+	return max
+}
+
+// AutoRenewAllowed tests whether tokens for a user with the supplied roles may be auto-renewed in an
+// application, which they may unless a role matches one of the policy's NoAutoRenewRoles
+func AutoRenewAllowed(app Application, roles []string) bool {
+	for _, r := range roles {
+		if roleMatchesAny(strings.TrimSpace(r), policyFor(app).NoAutoRenewRoles) {
+			return false
+		}
+	}
+	return true
+}
+
+// roleMatchesAny tests whether a role is any of the patterns or falls under one, so "ADMIN" matches both
+// "ADMIN" and "ADMIN.EXPERIMENT"
+func roleMatchesAny(role string, patterns []string) bool {
+	for _, p := range patterns {
+		if role == p || strings.HasPrefix(role, p+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoRenewAllowed(t *testing.T) {
+	testCases := []struct {
+		app   Application
+		roles []string
+		ok    bool
+	}{
+		{"DRIVER", []string{"DRIVER"}, true},
+		{"DRIVER", []string{"DRIVER", "ADMIN.EXPERIMENT"}, false},
+		{"ADMIN", []string{"ADMIN"}, false},
+		{"ADMIN", []string{" ADMIN "}, false},
+		{"ADMIN", []string{"ADMINISTRATOR"}, true},
+		{"SOMETHING", []string{"ADMIN.CREATEUSER"}, false},
+		{"SOMETHING", nil, true},
+	}
+	for _, tc := range testCases {
+		if ok := AutoRenewAllowed(tc.app, tc.roles); ok != tc.ok {
+			t.Errorf("Expected auto-renew allowed for %v with roles %v to be %v", tc.app, tc.roles, tc.ok)
+		}
+	}
+}
+
+func TestTokenLifetimes(t *testing.T) {
+	if ttl := TokenTtl("SOMETHING"); ttl != defaultTokenTtl {
+		t.Errorf("Expected the default token TTL for an unknown app, got %v", ttl)
+	}
+	if window := TokenRenewWindow("ADMIN"); window != defaultTokenRenewWindow {
+		t.Errorf("Expected the default renew window when the policy doesn't set one, got %v", window)
+	}
+	if max := MaxSessionLifetime("ADMIN"); max != 24*time.Hour {
+		t.Errorf("Expected admin sessions to live for a day at most, got %v", max)
+	}
+	for app := range policies {
+		if TokenTtl(app) > MaxTokenTtl() {
+			t.Errorf("Expected MaxTokenTtl to cover %v tokens", app)
+		}
+	}
+}
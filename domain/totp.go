@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP as per RFC 6238, with the parameters every authenticator app defaults to
+const (
+	totpIssuer      = "Hailo"
+	totpSecretBytes = 20
+	totpStep        = 30 * time.Second
+	totpDigits      = 6
+	// totpSkew is how many steps either side of now we accept, to allow for clock drift
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTotpSecret generates a random, base32 encoded, TOTP shared secret
+func NewTotpSecret() (string, error) {
+	b := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TotpCode computes the code for secret at time t
+func TotpCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTotpSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(totpStepAt(t))), nil
+}
+
+// ValidateTotp tests whether code is valid for secret at time t, give or take totpSkew steps
+func ValidateTotp(secret, code string, t time.Time) bool {
+	_, ok := MatchTotp(secret, code, t)
+	return ok
+}
+
+// MatchTotp finds the time step, within totpSkew steps of t, that code is valid for secret at, and whether
+// there is one. Remembering the step lets callers refuse to accept the same code twice.
+func MatchTotp(secret, code string, t time.Time) (int64, bool) {
+	if len(secret) == 0 || len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := decodeTotpSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+	now := totpStepAt(t)
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(step))), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// UseTotpCode tests whether code is currently valid for the user's confirmed TOTP secret, at a later time step
+// than the last code accepted, so that each code can only be used once (RFC 6238 section 5.2). The caller must
+// persist the user for the code to be spent.
+func (u *User) UseTotpCode(code string) bool {
+	step, ok := MatchTotp(u.TotpSecret, code, time.Now())
+	if !ok || step <= u.TotpLastStep {
+		return false
+	}
+	u.TotpLastStep = step
+	return true
+}
+
+// TotpUri builds the otpauth:// URI authenticator apps consume (usually via a QR code)
+func TotpUri(app Application, uid, secret string) string {
+	label := fmt.Sprintf("%s %s:%s", totpIssuer, app, uid)
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", totpIssuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprintf("%d", totpDigits))
+	v.Set("period", fmt.Sprintf("%d", int(totpStep/time.Second)))
+	return fmt.Sprintf("otpauth://totp/%s?%s", url.PathEscape(label), v.Encode())
+}
+
+// totpStepAt is the RFC 6238 time step counter at time t
+func totpStepAt(t time.Time) int64 {
+	return t.Unix() / int64(totpStep/time.Second)
+}
+
+func decodeTotpSecret(secret string) ([]byte, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TOTP secret: %v", err)
+	}
+	return key, nil
+}
+
+// hotp is RFC 4226 HOTP, truncated to totpDigits
+func hotp(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, bin%mod)
+}
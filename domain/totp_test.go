@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed from the RFC 6238 test vectors, "12345678901234567890", base32 encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCode(t *testing.T) {
+	// the RFC vectors are 8 digits; we use the last 6
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tc := range testCases {
+		code, err := TotpCode(rfc6238Secret, time.Unix(tc.unix, 0))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if code != tc.code {
+			t.Errorf("Expected code %v at %v, got %v", tc.code, tc.unix, code)
+		}
+	}
+}
+
+func TestValidateTotp(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := TotpCode(rfc6238Secret, now)
+
+	testCases := []struct {
+		secret string
+		code   string
+		at     time.Time
+		valid  bool
+	}{
+		{rfc6238Secret, code, now, true},
+		{strings.ToLower(rfc6238Secret), code, now, true},
+		{rfc6238Secret, code, now.Add(-totpStep), true}, // drift either side
+		{rfc6238Secret, code, now.Add(totpStep), true},
+		{rfc6238Secret, code, now.Add(3 * totpStep), false},
+		{rfc6238Secret, "000000", now, false},
+		{rfc6238Secret, "", now, false},
+		{rfc6238Secret, code + "1", now, false},
+		{"", code, now, false},
+		{"not base32!", code, now, false},
+	}
+
+	for i, tc := range testCases {
+		if valid := ValidateTotp(tc.secret, tc.code, tc.at); valid != tc.valid {
+			t.Errorf("Test case %d: expected %v, got %v", i, tc.valid, valid)
+		}
+	}
+}
+
+func TestUseTotpCode(t *testing.T) {
+	user := &User{TotpSecret: rfc6238Secret}
+	now := time.Now()
+	code, _ := TotpCode(rfc6238Secret, now)
+	previous, _ := TotpCode(rfc6238Secret, now.Add(-totpStep))
+
+	if !user.UseTotpCode(code) {
+		t.Fatal("Expected the current code to be accepted")
+	}
+	if user.TotpLastStep != totpStepAt(now) {
+		t.Errorf("Expected the last step to be %v, got %v", totpStepAt(now), user.TotpLastStep)
+	}
+	if user.UseTotpCode(code) {
+		t.Error("Expected a code not to be accepted twice")
+	}
+	if user.UseTotpCode(previous) {
+		t.Error("Expected a code from before the last accepted not to be accepted")
+	}
+}
+
+func TestNewTotpSecret(t *testing.T) {
+	secret, err := NewTotpSecret()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := TotpCode(secret, time.Now()); err != nil {
+		t.Errorf("Generated secret %v is unusable: %v", secret, err)
+	}
+	if other, _ := NewTotpSecret(); other == secret {
+		t.Error("Expected secrets to differ")
+	}
+}
+
+func TestTotpUri(t *testing.T) {
+	uri := TotpUri(Application("ADMIN"), "dave", rfc6238Secret)
+	expected := "otpauth://totp/Hailo%20ADMIN:dave?algorithm=SHA1&digits=6&issuer=Hailo&period=30&secret=" + rfc6238Secret
+	if uri != expected {
+		t.Errorf("Expected %v, got %v", expected, uri)
+	}
+}
+
+func TestMfaRequired(t *testing.T) {
+	defer func(orig map[Application]Policy) { policies = orig }(policies)
+	policies = map[Application]Policy{
+		Application("ADMIN"): {RequireMfa: true},
+	}
+
+	if !(&User{App: Application("ADMIN")}).MfaRequired() {
+		t.Error("Expected MFA to be required for ADMIN")
+	}
+	if (&User{App: Application("DRIVER")}).MfaRequired() {
+		t.Error("Expected MFA not to be required without a policy")
+	}
+}
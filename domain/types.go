@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +25,15 @@ const (
 	h1PasswordSalt        = "2103ccff866295b95e057e9c3a75ceaf"
 )
 
+// Reasons an authentication attempt failed, as recorded in FailedLogin
+const (
+	FailedLoginUnknownUser    = "unknownUser"
+	FailedLoginBadCredentials = "badCredentials"
+	FailedLoginMfaInvalid     = "mfaInvalid"
+	FailedLoginLocked         = "locked"
+	FailedLoginRateLimited    = "rateLimited"
+)
+
 var (
 	bcryptCost = bcrypt.DefaultCost
 )
@@ -40,14 +50,24 @@ type Token struct {
 	Expires       time.Time
 	AutoRenew     time.Time
 	Roles         []string
-	Signature     string
+	// KeyId identifies the key the token was signed with; empty for tokens signed with the legacy key
+	KeyId string
+	// Algorithm is the signature algorithm; empty for the legacy RSA PKCS#1 v1.5 with SHA-1
+	Algorithm string
+	Signature string
 }
 
 // Session represents a user's session and comprises a random unique ID and a token
 type Session struct {
-	Id      string
+	Id string
+	// Created is when the user logged in; renewing or refreshing the token mints it afresh, but this stays put
 	Created time.Time
 	Token   Token
+	// RefreshExpires is when the session's refresh token expires, keeping the session alive until then even
+	// if its token has expired; zero if no refresh token has been issued
+	RefreshExpires time.Time
+	// LastSeen is when the session was last read, updated at most once a minute; zero if it hasn't been
+	LastSeen time.Time
 }
 
 // Application represents some top-level namespace within which users can register
@@ -65,6 +85,14 @@ type User struct {
 	PasswordChange        time.Time
 	Status                string
 	AccountExpirationDate string
+	// TotpSecret is the user's confirmed TOTP shared secret; once set, logins require a code
+	TotpSecret string
+	// TotpPendingSecret is a secret issued by enrolment that has not yet been confirmed with a code
+	TotpPendingSecret string
+	// TotpLastStep is the time step of the last TOTP code accepted; codes for it or earlier are refused
+	TotpLastStep int64
+	// RecoveryCodes are bcrypt hashes of single-use codes that can stand in for a TOTP code
+	RecoveryCodes [][]byte
 }
 
 // Login represents a single successful login action by a user
@@ -77,6 +105,25 @@ type Login struct {
 	Meta          map[string]string
 }
 
+// FailedLogin represents a single failed authentication attempt
+type FailedLogin struct {
+	App Application
+	// Uid is the UID of the user, or the username tried if there is no such user (or we didn't look)
+	Uid           string
+	Username      string
+	Failed        time.Time
+	Reason        string
+	AuthMechanism string
+	DeviceType    string
+	Meta          map[string]string
+}
+
+// KeyRotation records a signing key being promoted to sign all new tokens
+type KeyRotation struct {
+	KeyId    string
+	Promoted time.Time
+}
+
 // EndpointAuth represents a single service being allowed `Role` access to a single endpoint
 type EndpointAuth struct {
 	// ServiceName and EndpointName identify the single endpoint that we are allowing access to
@@ -101,6 +148,42 @@ type Policy struct {
 	NewPasswordChecks []PasswordAssertion
 	// PasswordValidFor defines a number of DAYS users can use a password for before it times out
 	PasswordValidFor int
+	// RequireMfa makes TOTP enrolment mandatory; users without it cannot log in until they enrol
+	RequireMfa bool
+	// LockoutThreshold is how many failed logins within LockoutWindow lock a user out, 0 meaning never
+	LockoutThreshold int
+	// LockoutWindow is both the period failed logins are counted over and how long a lockout lasts
+	LockoutWindow time.Duration
+	// IpRateLimit limits auth requests from a single IP address. Each limit set costs every auth request a ZK
+	// lock plus a C* read and write (and a rate limited one another set, to decide whether to record it), so
+	// leave a limit at zero rather than setting one too generous to ever bite.
+	IpRateLimit RateLimit
+	// UsernameRateLimit limits auth requests for a single username
+	UsernameRateLimit RateLimit
+	// RefreshTokenTtl is how long a refresh token lasts (each refresh issuing a new one), 0 meaning refresh
+	// tokens are not issued
+	RefreshTokenTtl time.Duration
+	// MaxSessionsPerDeviceType is how many sessions a user may have at once on each device type, the oldest
+	// being expired to make room for a new one; 0 means 1
+	MaxSessionsPerDeviceType int
+	// IdleTimeout expires sessions that haven't been seen for this long, however long their token has left;
+	// 0 means sessions never go idle
+	IdleTimeout time.Duration
+	// TokenTtl is how long a token lasts from being minted or renewed, 0 meaning the default (8 hours)
+	TokenTtl time.Duration
+	// TokenRenewWindow is how long before expiry a token may be auto-renewed, 0 meaning the default (30 minutes)
+	TokenRenewWindow time.Duration
+	// MaxSessionLifetime is how long after login a session can be kept alive by renewing its token, 0 meaning
+	// for ever
+	MaxSessionLifetime time.Duration
+	// NoAutoRenewRoles are role patterns whose holders' tokens are never auto-renewed; a pattern also
+	// matches the roles beneath it
+	NoAutoRenewRoles []string
+	// PasswordHashing is how new passwords are hashed; existing hashes that are weaker are rehashed on login
+	PasswordHashing PasswordHashing
+	// PasswordResetTtl is how long a self-service password reset token lasts, 0 meaning users can't reset
+	// their own passwords
+	PasswordResetTtl time.Duration
 }
 
 // METHODS
@@ -116,12 +199,82 @@ func (a Application) ToAuthMechanism() string {
 // Copy makes a copy of a token and returns a new one
 func (s *Session) Copy() *Session {
 	return &Session{
-		Id:      s.Id,
-		Created: s.Created,
-		Token:   s.Token,
+		Id:             s.Id,
+		Created:        s.Created,
+		Token:          s.Token,
+		RefreshExpires: s.RefreshExpires,
+		LastSeen:       s.LastSeen,
 	}
 }
 
+// CanRefresh tests whether the session's token can still be refreshed with a refresh token
+func (s *Session) CanRefresh() bool {
+	return s.RefreshExpires.After(time.Now())
+}
+
+// LiveUntil returns when a session becomes unusable: when its token expires, or a renew window after that if
+// the token can be auto-renewed, or when its refresh token expires if that's later, and never beyond its
+// lifetime; zero if never
+func (s *Session) LiveUntil() time.Time {
+	if s.Token.Expires.IsZero() {
+		return time.Time{}
+	}
+	until := s.Token.Expires
+	if !s.Token.AutoRenew.IsZero() {
+		until = until.Add(TokenRenewWindow(s.Token.Application()))
+	}
+	if s.RefreshExpires.After(until) {
+		until = s.RefreshExpires
+	}
+	if ends := s.LifetimeEnds(); !ends.IsZero() && ends.Before(until) {
+		until = ends
+	}
+	return until
+}
+
+// IsIdle tests whether a session has gone unseen for longer than its application's idle timeout. Minting
+// or renewing its token counts as seeing it.
+func (s *Session) IsIdle(now time.Time) bool {
+	timeout := IdleTimeout(s.Token.Application())
+	if timeout <= 0 {
+		return false
+	}
+	seen := s.LastSeen
+	if s.Token.Created.After(seen) {
+		seen = s.Token.Created
+	}
+	return now.Sub(seen) > timeout
+}
+
+// LifetimeEnds returns when the session must end, however its token is renewed or refreshed, under its
+// application's MaxSessionLifetime; zero if never
+func (s *Session) LifetimeEnds() time.Time {
+	max := MaxSessionLifetime(s.Token.Application())
+	if max <= 0 || s.Created.IsZero() {
+		return time.Time{}
+	}
+	return s.Created.Add(max)
+}
+
+// HasOutlived tests whether the session has reached the end of its lifetime at time t
+func (s *Session) HasOutlived(t time.Time) bool {
+	ends := s.LifetimeEnds()
+	return !ends.IsZero() && !t.Before(ends)
+}
+
+// IdleTimeout returns how long a session of an application may go unseen before it is expired
+func IdleTimeout(app Application) time.Duration {
+	return policyFor(app).IdleTimeout
+}
+
+// MaxSessionsPerDeviceType returns how many sessions a user of an application may have on each device type
+func MaxSessionsPerDeviceType(app Application) int {
+	if max := policyFor(app).MaxSessionsPerDeviceType; max > 1 {
+		return max
+	}
+	return 1
+}
+
 // TOKEN
 
 // DecodedSig returns base64 decoded bytes of the signature component
@@ -148,11 +301,62 @@ func (t *Token) CanAutoRenew() bool {
 	return true
 }
 
+// HasExpired tests to see if this token's expiry time has passed
+func (t *Token) HasExpired() bool {
+	return !t.Expires.IsZero() && t.Expires.Before(time.Now())
+}
+
 // String for stringer
 func (t *Token) String() string {
 	return t.dataComponent() + ":sig=" + t.Signature
 }
 
+// ParseToken reads a token back from its string form. It doesn't verify the signature.
+func ParseToken(s string) (*Token, error) {
+	t := &Token{}
+	for _, part := range strings.Split(s, ":") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Malformed token component '%v'", part)
+		}
+		k, v := kv[0], kv[1]
+		var err error
+		switch k {
+		case "am":
+			t.AuthMechanism = v
+		case "d":
+			t.DeviceType = v
+		case "id":
+			t.Id = v
+		case "ct":
+			t.Created, err = unixStringToTime(v)
+		case "et":
+			t.Expires, err = unixStringToTime(v)
+		case "rt":
+			t.AutoRenew, err = unixStringToTime(v)
+		case "r":
+			if v != "" {
+				t.Roles = strings.Split(v, ",")
+			}
+		case "kid":
+			t.KeyId = v
+		case "alg":
+			t.Algorithm = v
+		case "sig":
+			t.Signature = v
+		default:
+			return nil, fmt.Errorf("Unknown token component '%v'", k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Bad token component '%v': %v", k, err)
+		}
+	}
+	if t.Signature == "" {
+		return nil, fmt.Errorf("Token has no signature")
+	}
+	return t, nil
+}
+
 // String for stringer
 func (t *Token) Application() Application {
 	return Application(strings.TrimLeft(t.AuthMechanism, "h2."))
@@ -170,6 +374,8 @@ func (t *Token) Copy() *Token {
 		Expires:       t.Expires,
 		AutoRenew:     t.AutoRenew,
 		Roles:         copyRoles,
+		KeyId:         t.KeyId,
+		Algorithm:     t.Algorithm,
 		Signature:     t.Signature,
 	}
 }
@@ -201,17 +407,28 @@ func (t *Token) dataComponent() string {
 	buf.WriteString(":r=")
 	buf.WriteString(strings.Join(t.Roles, ","))
 
+	// only present for tokens signed from the keyring, so legacy tokens (and their signatures) are unchanged
+	if t.KeyId != "" {
+		buf.WriteString(":kid=")
+		buf.WriteString(t.KeyId)
+	}
+	// likewise only present for tokens not signed with the legacy algorithm
+	if t.Algorithm != "" {
+		buf.WriteString(":alg=")
+		buf.WriteString(t.Algorithm)
+	}
+
 	return buf.String()
 }
 
 // USER
 
-// SetPassword will set the user's password, hashing it using bcrypt and storing the hash
+// SetPassword will set the user's password, hashing it as the application's policy says and storing the hash
 func (u *User) SetPassword(plain string) error {
 	if err := TestPolicy(plain, u); err.AnyErrors() {
 		return err
 	}
-	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcryptCost)
+	hash, err := hashPassword(u.App, []byte(plain))
 	if err != nil {
 		return err
 	}
@@ -229,8 +446,8 @@ func (u *User) SetPassword(plain string) error {
 
 // PasswordMatches tests whether the un-hashed pass p matches our stored hashed version
 func (u *User) PasswordMatches(p []byte) error {
-	if strings.HasPrefix(string(u.Password), "$2a$") {
-		return bcrypt.CompareHashAndPassword(u.Password, p)
+	if hasherFor(u.Password) != nil {
+		return compareHash(u.Password, p)
 	}
 
 	// try h1 driver format
@@ -252,14 +469,42 @@ func (u *User) PasswordMatches(p []byte) error {
 
 // OldHashFormat tests whether we have the old h1 driver hash format
 func (u *User) OldHashFormat() bool {
-	return len(u.Password) == 32 && !strings.HasPrefix(string(u.Password), "$2a$")
+	return len(u.Password) == 32 && hasherFor(u.Password) == nil
 }
 
-// InPasswordHistory tests whether the un-hashed pass p matches a stored value in our last N history items
+// NeedsRehash tests whether the user's password hash is weaker than the application's policy now asks for
+func (u *User) NeedsRehash() bool {
+	return needsRehash(u.App, u.Password)
+}
+
+// Rehash rehashes the user's (already verified) password as the application's policy says, without counting
+// it as a password change
+func (u *User) Rehash(plain []byte) error {
+	hash, err := hashPassword(u.App, plain)
+	if err != nil {
+		return err
+	}
+	for i, old := range u.PasswordHistory {
+		if bytes.Equal(old, u.Password) {
+			u.PasswordHistory[i] = hash
+		}
+	}
+	u.Password = hash
+	return nil
+}
+
+// InPasswordHistory tests whether the un-hashed pass p matches a stored value in our last N history items. An
+// item peppered with a version we no longer have can't be checked, so it fails closed: p is taken to match.
 func (u *User) InPasswordHistory(p []byte, n int) bool {
 	length := len(u.PasswordHistory)
 	for i, counter := length-1, 0; i >= 0; i-- {
-		if err := bcrypt.CompareHashAndPassword(u.PasswordHistory[i], p); err == nil {
+		if version, _, err := splitPepper(u.PasswordHistory[i]); err == nil && version != 0 {
+			if _, ok := pepperFor(version); !ok {
+				log.Warnf("[Domain] Cannot check password history for user %v: pepper version %v is not loaded", u.Uid, version)
+				return true
+			}
+		}
+		if err := compareHash(u.PasswordHistory[i], p); err == nil {
 			return true
 		}
 		counter++
@@ -307,6 +552,16 @@ func (u *User) RevokeRoles(roles []string) {
 	u.Roles = newRoles
 }
 
+// MfaEnrolled tests whether the user has confirmed TOTP enrolment
+func (u *User) MfaEnrolled() bool {
+	return len(u.TotpSecret) > 0
+}
+
+// MfaRequired will determine if this user must be enrolled in TOTP before they are granted access
+func (u *User) MfaRequired() bool {
+	return policyFor(u.App).RequireMfa
+}
+
 func (u *User) IsDisabled() bool {
 	return u.Status == "disabled"
 }
@@ -353,18 +608,40 @@ func timeToUnixString(t time.Time) string {
 	return fmt.Sprintf("%v", t.Unix())
 }
 
+func unixStringToTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(i, 0), nil
+}
+
 // POLICY
 
 // Test will check if a new password is valid for a policy
 func (p *Policy) Test(newPass string, user *User) *multierror.MultiError {
 	errs := multierror.New()
+	for _, err := range p.Failures(newPass, user) {
+		errs.Add(err)
+	}
+
+	return errs
+}
+
+// Failures returns the error from each of a policy's checks that a new password fails, in the order the
+// policy defines them
+func (p *Policy) Failures(newPass string, user *User) []error {
+	failures := make([]error, 0)
 	for _, assertion := range p.NewPasswordChecks {
 		if err := assertion(newPass, user); err != nil {
-			errs.Add(err)
+			failures = append(failures, err)
 		}
 	}
 
-	return errs
+	return failures
 }
 
 // MustChangePassword will see if a user should be forced to change their password,
@@ -384,21 +661,31 @@ func (p *Policy) MustChangePassword(user *User) bool {
 // TestPolicy will test if a password is valid against the policy defined for this
 // user's application, or against the default policy if none defined for this application
 func TestPolicy(newPass string, user *User) *multierror.MultiError {
-	policy, ok := policies[user.App]
-	if !ok {
-		policy = defaultPolicy
-	}
+	policy := policyFor(user.App)
 	return policy.Test(newPass, user)
 }
 
+// PasswordFailures lists the checks a password fails in the policy defined for this user's application, or in
+// the default policy if none defined for this application
+func PasswordFailures(newPass string, user *User) []error {
+	policy := policyFor(user.App)
+	return policy.Failures(newPass, user)
+}
+
 // MustChangePassword will test if a user needs to change their password using the policy defined for this
 // user's application, or against the default policy if none defined for this application
 func MustChangePassword(user *User) bool {
-	policy, ok := policies[user.App]
+	policy := policyFor(user.App)
+	return policy.MustChangePassword(user)
+}
+
+// policyFor returns the policy defined for an application, or the default policy if none defined
+func policyFor(app Application) Policy {
+	policy, ok := policies[app]
 	if !ok {
 		policy = defaultPolicy
 	}
-	return policy.MustChangePassword(user)
+	return policy
 }
 
 // ValidateRoleSet will validate a user roleset is valid (what a shock)
@@ -11,6 +11,10 @@ import (
 
 func init() {
 	bcryptCost = bcrypt.MinCost
+	// and likewise keep Argon2id cheap
+	admin := policies["ADMIN"]
+	admin.PasswordHashing = PasswordHashing{Algorithm: HashArgon2id, Cost: 1, Memory: 1024}
+	policies["ADMIN"] = admin
 }
 
 func mintToken() Token {
@@ -56,6 +60,64 @@ func TestTokenDataComponent(t *testing.T) {
 	}
 }
 
+func TestTokenDataComponentWithKeyId(t *testing.T) {
+	token := mintToken()
+	token.KeyId = "2016-01"
+	s := token.dataComponent()
+	expected := `am=admin:d=cli:id=dave:ct=1378377733:et=1378406533:rt=:r=ADMIN:kid=2016-01`
+	if s != expected {
+		t.Fatalf("Unexpected token dataComponent: %v", s)
+	}
+	if c := token.Copy(); c.KeyId != token.KeyId {
+		t.Errorf("Expecting copy to keep the key ID, got '%v'", c.KeyId)
+	}
+}
+
+func TestTokenDataComponentWithAlgorithm(t *testing.T) {
+	token := mintToken()
+	token.KeyId = "2016-01"
+	token.Algorithm = "ES256"
+	s := token.dataComponent()
+	expected := `am=admin:d=cli:id=dave:ct=1378377733:et=1378406533:rt=:r=ADMIN:kid=2016-01:alg=ES256`
+	if s != expected {
+		t.Fatalf("Unexpected token dataComponent: %v", s)
+	}
+	if c := token.Copy(); c.Algorithm != token.Algorithm {
+		t.Errorf("Expecting copy to keep the algorithm, got '%v'", c.Algorithm)
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	token := mintToken()
+	token.KeyId = "2016-01"
+	token.Algorithm = "ES256"
+	token.AutoRenew = time.Unix(1378402933, 0)
+	token.Sign([]byte("signature"))
+
+	parsed, err := ParseToken(token.String())
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	if parsed.String() != token.String() {
+		t.Errorf("Expecting parsed token %v to match %v", parsed.String(), token.String())
+	}
+	if string(parsed.DecodedSig()) != "signature" {
+		t.Errorf("Unexpected signature '%s'", parsed.DecodedSig())
+	}
+
+	for _, s := range []string{
+		"",
+		"am=admin:d=cli:id=dave",
+		"am=admin:d=cli:id=dave:ct=yesterday:sig=c2ln",
+		"am=admin:d=cli:id=dave:bogus=1:sig=c2ln",
+		"am=admin:d=cli:id:sig=c2ln",
+	} {
+		if _, err := ParseToken(s); err == nil {
+			t.Errorf("Expecting '%v' to fail to parse", s)
+		}
+	}
+}
+
 func TestTokenDataToSign(t *testing.T) {
 	token := mintToken()
 	s := token.DataToSign()
@@ -122,6 +184,44 @@ func TestTokenAutoRenew(t *testing.T) {
 	}
 }
 
+func TestSessionIsIdle(t *testing.T) {
+	now := time.Now()
+	sess := &Session{Token: Token{AuthMechanism: "h2.ADMIN", Created: now.Add(-3 * time.Hour)}}
+	if !sess.IsIdle(now) {
+		t.Fatal("Expecting an admin session unseen since its token was minted 3h ago to be idle")
+	}
+	sess.LastSeen = now.Add(-10 * time.Minute)
+	if sess.IsIdle(now) {
+		t.Fatal("Expecting a recently seen session not to be idle")
+	}
+
+	// drivers don't have an idle timeout
+	sess = &Session{Token: Token{AuthMechanism: "h2.DRIVER", Created: now.Add(-3 * time.Hour)}}
+	if sess.IsIdle(now) {
+		t.Fatal("Expecting a driver session never to be idle")
+	}
+}
+
+func TestSessionLifetime(t *testing.T) {
+	login := time.Unix(1378377732, 0)
+	sess := &Session{Created: login, Token: Token{AuthMechanism: "h2.ADMIN", Created: login.Add(20 * time.Hour)}}
+	if ends := sess.LifetimeEnds(); !ends.Equal(login.Add(24 * time.Hour)) {
+		t.Fatalf("Expecting an admin session to end a day after login, got %v", ends)
+	}
+	if sess.HasOutlived(login.Add(23 * time.Hour)) {
+		t.Error("Expecting the session to live for the whole day")
+	}
+	if !sess.HasOutlived(login.Add(24 * time.Hour)) {
+		t.Error("Expecting the session to have ended after a day, however recently its token was minted")
+	}
+
+	// without a login time there's nothing to go on
+	sess.Created = time.Time{}
+	if !sess.LifetimeEnds().IsZero() || sess.HasOutlived(login.Add(48*time.Hour)) {
+		t.Error("Expecting a session with no login time to live for ever")
+	}
+}
+
 func TestGrantRoles(t *testing.T) {
 	testCases := []struct {
 		actOn   []string
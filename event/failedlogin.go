@@ -0,0 +1,37 @@
+package event
+
+import (
+	"encoding/json"
+
+	nsq "github.com/HailoOSS/service/nsq"
+	log "github.com/cihub/seelog"
+)
+
+const (
+	failedLoginTopicName = "login.failedlogin"
+)
+
+// FailedLoginEvent is published for every failed authentication attempt, for security monitoring
+type FailedLoginEvent struct {
+	Application string            `json:"application,omitempty"`
+	Uid         string            `json:"uid,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	FailedAt    string            `json:"failedAt,omitempty"`
+	Reason      string            `json:"reason,omitempty"`
+	Mech        string            `json:"mech,omitempty"`
+	DeviceType  string            `json:"deviceType,omitempty"`
+	Meta        map[string]string `json:"meta,omitempty"`
+}
+
+func (e *FailedLoginEvent) Publish() {
+	bytes, err := json.Marshal(e)
+
+	if err != nil {
+		log.Errorf("[FailedLoginEvent] Cannot marshal event %v - Error: %v", e, err)
+		return
+	}
+
+	if err := nsq.Publish(failedLoginTopicName, bytes); err != nil {
+		log.Errorf("[FailedLoginEvent] Unable to publish to nsq: %v", err)
+	}
+}
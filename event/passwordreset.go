@@ -0,0 +1,36 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+
+	nsq "github.com/HailoOSS/service/nsq"
+)
+
+const (
+	passwordResetTopicName = "login.passwordreset"
+)
+
+// PasswordResetEvent asks for a password reset token to be delivered to a user, via whichever of their IDs
+// (email address, phone number) the subscriber can send to
+type PasswordResetEvent struct {
+	Application string   `json:"application,omitempty"`
+	Uid         string   `json:"uid,omitempty"`
+	Ids         []string `json:"ids,omitempty"`
+	Token       string   `json:"token,omitempty"`
+	Expires     string   `json:"expires,omitempty"`
+}
+
+// Publish publishes the event, returning an error (rather than just logging it, as the other events do)
+// because the user is left waiting for a token that will never arrive
+func (e *PasswordResetEvent) Publish() error {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("Cannot marshal password reset event: %v", err)
+	}
+
+	if err := nsq.Publish(passwordResetTopicName, bytes); err != nil {
+		return fmt.Errorf("Unable to publish password reset event to nsq: %v", err)
+	}
+	return nil
+}
@@ -55,6 +55,12 @@ func authViaOauth(request *auth.Request) (*auth.Response, errors.Error) {
 	provider := request.GetProvider()
 
 	sess, err := auther.OAuth(app, deviceType, username, token, provider, meta)
+	switch err {
+	case auther.ErrorAccountIsLocked:
+		return nil, errors.Forbidden(constants.AccountLockedErrCode, err.Error())
+	case auther.ErrorRateLimited:
+		return nil, errors.Forbidden(constants.RateLimitedErrCode, err.Error())
+	}
 	if err != nil {
 		return nil, errors.InternalServerError(constants.OauthUnknownErrCode, err.Error())
 	}
@@ -63,9 +69,19 @@ func authViaOauth(request *auth.Request) (*auth.Response, errors.Error) {
 		return nil, errors.Forbidden(constants.OauthUserNotFoundErrCode, "User could not be found")
 	}
 
+	sess, refreshToken, rtErr := issueRefreshToken("auth", request.GetRefreshToken(), sess)
+	if rtErr != nil {
+		return nil, rtErr
+	}
+
 	rsp := &auth.Response{
-		SessId: proto.String(sess.Id),
-		Token:  proto.String(sess.Token.String()),
+		SessId:       proto.String(sess.Id),
+		Token:        proto.String(sess.Token.String()),
+		RefreshToken: refreshToken,
+	}
+	var jwtErr errors.Error
+	if rsp.Jwt, jwtErr = sessionJWT("auth", request.GetJwt(), sess); jwtErr != nil {
+		return nil, jwtErr
 	}
 
 	return rsp, nil
@@ -78,6 +94,8 @@ func authViaH2(req *server.Request, request *auth.Request) (*auth.Response, erro
 	password := []byte(request.GetPassword())
 	meta := protoToMap(request.GetMeta())
 	newPassword := []byte(request.GetNewPassword())
+	totpCode := request.GetTotpCode()
+	recoveryCode := request.GetRecoveryCode()
 	noExpire := request.GetNoExpire()
 
 	var currentSession *domain.Session = nil
@@ -89,11 +107,24 @@ func authViaH2(req *server.Request, request *auth.Request) (*auth.Response, erro
 		currentSession = existingSession
 	}
 
-	sess, err := auther.Auth(app, deviceType, username, password, newPassword, meta, currentSession)
+	sess, err := auther.Auth(app, deviceType, username, password, newPassword, totpCode, recoveryCode, meta, currentSession)
 	if err == auther.ErrorChangePassword {
 		// need a different code for change password
 		return nil, errors.InternalServerError("com.HailoOSS.service.login.auth.change-password", err.Error())
 	}
+	switch err {
+	case auther.ErrorAccountIsLocked:
+		return nil, errors.Forbidden(constants.AccountLockedErrCode, err.Error())
+	case auther.ErrorRateLimited:
+		return nil, errors.Forbidden(constants.RateLimitedErrCode, err.Error())
+	case auther.ErrorMfaRequired:
+		// the password was right, but the client needs to come back with (or enrol in) a second factor
+		return nil, errors.Forbidden("com.HailoOSS.service.login.auth.mfa-required", err.Error())
+	case auther.ErrorMfaInvalid:
+		return nil, errors.Forbidden("com.HailoOSS.service.login.auth.mfa-invalid", err.Error())
+	case auther.ErrorMfaEnrolmentRequired:
+		return nil, errors.Forbidden("com.HailoOSS.service.login.auth.mfa-enrolment-required", err.Error())
+	}
 	if err != nil {
 		return nil, errors.InternalServerError("com.HailoOSS.service.login.auth.auther", err.Error())
 	}
@@ -102,9 +133,19 @@ func authViaH2(req *server.Request, request *auth.Request) (*auth.Response, erro
 		return nil, errors.Forbidden(constants.BadCredentialsErrCode, "Bad credentials")
 	}
 
+	sess, refreshToken, rtErr := issueRefreshToken("auth", request.GetRefreshToken(), sess)
+	if rtErr != nil {
+		return nil, rtErr
+	}
+
 	rsp := &auth.Response{
-		SessId: proto.String(sess.Id),
-		Token:  proto.String(sess.Token.String()),
+		SessId:       proto.String(sess.Id),
+		Token:        proto.String(sess.Token.String()),
+		RefreshToken: refreshToken,
+	}
+	var jwtErr errors.Error
+	if rsp.Jwt, jwtErr = sessionJWT("auth", request.GetJwt(), sess); jwtErr != nil {
+		return nil, jwtErr
 	}
 	return rsp, nil
 }
@@ -32,6 +32,10 @@ func AuthAs(req *server.Request) (proto.Message, errors.Error) {
 		SessId: proto.String(sess.Id),
 		Token:  proto.String(sess.Token.String()),
 	}
+	var jwtErr errors.Error
+	if rsp.Jwt, jwtErr = sessionJWT("authas", request.GetJwt(), sess); jwtErr != nil {
+		return nil, jwtErr
+	}
 
 	return rsp, nil
 }
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/auther"
+	cpr "github.com/HailoOSS/login-service/proto/completepasswordreset"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// CompletePasswordReset exchanges a reset token for a new password, logging the user out everywhere
+func CompletePasswordReset(req *server.Request) (proto.Message, errors.Error) {
+	request := &cpr.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.completepasswordreset.unmarshal", err.Error())
+	}
+
+	err := auther.CompletePasswordReset(request.GetResetToken(), request.GetNewPassword())
+	if _, ok := err.(*auther.PasswordRejectedError); ok {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.completepasswordreset.badpassword", err.Error())
+	}
+	switch err {
+	case nil:
+	case auther.ErrorPasswordResetTokenInvalid:
+		return nil, errors.Forbidden("com.HailoOSS.service.login.completepasswordreset.invalid", err.Error())
+	default:
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.completepasswordreset.auther", err.Error())
+	}
+
+	return &cpr.Response{}, nil
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/auther"
+	introspectproto "github.com/HailoOSS/login-service/proto/introspect"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// Introspect reports whether a token is active, with its claims if it is, so that services which only hold a
+// token can check it against server-side revocation
+func Introspect(req *server.Request) (proto.Message, errors.Error) {
+	request := &introspectproto.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.introspect.unmarshal", err.Error())
+	}
+
+	in, err := auther.Introspect(request.GetToken())
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.introspect.auther", err.Error())
+	}
+
+	rsp := &introspectproto.Response{
+		Active:    proto.Bool(in.Active),
+		TokenType: proto.String(in.TokenType),
+	}
+	if in.Active {
+		rsp.Sub = proto.String(in.Token.Id)
+		if in.SessionId != "" {
+			rsp.SessId = proto.String(in.SessionId)
+		}
+		rsp.Iat = timeToProto(in.Token.Created)
+		rsp.Exp = timeToProto(in.Token.Expires)
+		rsp.AuthMechanism = proto.String(in.Token.AuthMechanism)
+		rsp.DeviceType = proto.String(in.Token.DeviceType)
+		rsp.AutoRenew = timeToProto(in.Token.AutoRenew)
+		rsp.Roles = in.Token.Roles
+	}
+
+	return rsp, nil
+}
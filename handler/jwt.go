@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/login-service/signer"
+	"github.com/HailoOSS/platform/errors"
+)
+
+// sessionJWT signs the session's token as a JWT for the endpoint's response, if the client asked for one
+func sessionJWT(endpoint string, want bool, sess *domain.Session) (*string, errors.Error) {
+	if !want {
+		return nil, nil
+	}
+	jwt, err := signer.SignJWT(sess.Id, &sess.Token)
+	if err != nil {
+		return nil, errors.InternalServerError(fmt.Sprintf("com.HailoOSS.service.login.%s.jwt", endpoint),
+			fmt.Sprintf("Error signing JWT: %v", err))
+	}
+	return proto.String(jwt), nil
+}
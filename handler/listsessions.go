@@ -18,19 +18,21 @@ func ListSessions(r *server.Request) (proto.Message, errors.Error) {
 	}
 
 	rsp := &listproto.Response{
-		Sessions: make([]*listproto.Session, len(sessionIds)),
+		Sessions: make([]*listproto.Session, 0, len(sessionIds)),
 		Uid:      proto.String(r.Auth().AuthUser().Id),
 	}
 
-	i := 0
-	for _, sessionId := range sessionIds {
+	for sessionId := range sessionIds {
 		var s *domain.Session
 		if s, err = dao.ReadSession(sessionId); err != nil {
 			return nil, errors.InternalServerError("com.HailoOSS.service.login.listsessions.dao.read", err.Error())
 		}
+		// userSessions can point at sessions that are dead but not yet swept
+		if s == nil {
+			continue
+		}
 
-		rsp.Sessions[i] = sessionToProto(s)
-		i++
+		rsp.Sessions = append(rsp.Sessions, sessionToProto(s))
 	}
 
 	return rsp, nil
@@ -8,6 +8,7 @@ import (
 	"github.com/HailoOSS/login-service/domain"
 	protoep "github.com/HailoOSS/login-service/proto"
 	protosession "github.com/HailoOSS/login-service/proto/listsessions"
+	protofailedlogin "github.com/HailoOSS/login-service/proto/readfailedlogins"
 	protologin "github.com/HailoOSS/login-service/proto/readlogin"
 	protouser "github.com/HailoOSS/login-service/proto/readuser"
 )
@@ -91,12 +92,31 @@ func loginsToProto(logins []*domain.Login) []*protologin.Login {
 	return rsp
 }
 
+func failedLoginsToProto(failures []*domain.FailedLogin) []*protofailedlogin.FailedLogin {
+	rsp := make([]*protofailedlogin.FailedLogin, len(failures))
+	for i, failed := range failures {
+		rsp[i] = &protofailedlogin.FailedLogin{
+			Application:     proto.String(string(failed.App)),
+			Uid:             proto.String(failed.Uid),
+			Username:        proto.String(failed.Username),
+			FailedTimestamp: timeToProto(failed.Failed),
+			Reason:          proto.String(failed.Reason),
+			Mech:            proto.String(failed.AuthMechanism),
+			DeviceType:      proto.String(failed.DeviceType),
+			Meta:            mapToProto(failed.Meta),
+		}
+	}
+
+	return rsp
+}
+
 // sessionToProto marshals a session -> proto
 func sessionToProto(session *domain.Session) *protosession.Session {
 	return &protosession.Session{
-		CreatedTimestamp: timeToProto(session.Token.Created),
-		Mech:             proto.String(session.Token.AuthMechanism),
-		DeviceType:       proto.String(session.Token.DeviceType),
+		CreatedTimestamp:  timeToProto(session.Token.Created),
+		Mech:              proto.String(session.Token.AuthMechanism),
+		DeviceType:        proto.String(session.Token.DeviceType),
+		LoggedInTimestamp: timeToProto(session.Created),
 	}
 }
 
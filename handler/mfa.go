@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/login-service/auther"
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	"github.com/HailoOSS/platform/errors"
+)
+
+// readMfaUser loads the h2 user we are changing TOTP enrolment for, checking their password if given
+func readMfaUser(endpoint string, app domain.Application, username, password string, checkPassword bool) (*domain.User, errors.Error) {
+	if _, _, ok := dao.IsLDAPUser(app, username); ok {
+		return nil, errors.BadRequest(fmt.Sprintf("com.HailoOSS.service.login.%s.ldap", endpoint),
+			"LDAP users cannot enrol in multi-factor authentication")
+	}
+
+	user, err := dao.ReadUser(app, username)
+	if err != nil {
+		return nil, errors.InternalServerError(fmt.Sprintf("com.HailoOSS.service.login.%s.readuser", endpoint),
+			fmt.Sprintf("Error reading user: %v", err))
+	}
+	if user == nil {
+		return nil, errors.NotFound(fmt.Sprintf("com.HailoOSS.service.login.%s.readuser", endpoint),
+			fmt.Sprintf("Could not find user with username %s", username))
+	}
+
+	if checkPassword {
+		if err := auther.ValidateAuth(app, username, []byte(password)); err != nil {
+			return nil, errors.Forbidden(fmt.Sprintf("com.HailoOSS.service.login.%s.validateauth", endpoint), err.Error())
+		}
+	}
+
+	return user, nil
+}
+
+// updateMfa makes changes to a user's second factors under lock via auther.UpdateMfa, reading them afresh so
+// that a code can't be spent twice and a concurrent login can't be overwritten. update can refuse with a
+// platform error, which is returned as it is.
+func updateMfa(endpoint string, user *domain.User, update func(*domain.User) errors.Error) errors.Error {
+	updated, err := auther.UpdateMfa(user.App, user.Uid, func(u *domain.User) error {
+		if perr := update(u); perr != nil {
+			return perr
+		}
+		return nil
+	})
+	if perr, ok := err.(errors.Error); ok {
+		return perr
+	} else if err != nil {
+		return errors.InternalServerError(fmt.Sprintf("com.HailoOSS.service.login.%s.updateuser", endpoint), err.Error())
+	} else if updated == nil {
+		return errors.NotFound(fmt.Sprintf("com.HailoOSS.service.login.%s.readuser", endpoint),
+			fmt.Sprintf("Could not find user with username %s", user.Uid))
+	}
+	*user = *updated
+	return nil
+}
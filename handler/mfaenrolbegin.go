@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/domain"
+	enrolbegin "github.com/HailoOSS/login-service/proto/mfaenrolbegin"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// MfaEnrolBegin issues a new TOTP secret for a user; it is not used for logins until confirmed with a code
+// via MfaEnrolConfirm
+func MfaEnrolBegin(req *server.Request) (proto.Message, errors.Error) {
+	request := &enrolbegin.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.mfaenrolbegin.unmarshal", err.Error())
+	}
+
+	app := domain.Application(request.GetApplication())
+	user, perr := readMfaUser("mfaenrolbegin", app, request.GetUsername(), request.GetPassword(), true)
+	if perr != nil {
+		return nil, perr
+	}
+
+	secret, err := domain.NewTotpSecret()
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.mfaenrolbegin.secret", err.Error())
+	}
+	perr = updateMfa("mfaenrolbegin", user, func(u *domain.User) errors.Error {
+		if u.MfaEnrolled() {
+			return errors.BadRequest("com.HailoOSS.service.login.mfaenrolbegin.enrolled",
+				"Already enrolled, remove the existing enrolment first")
+		}
+		u.TotpPendingSecret = secret
+		return nil
+	})
+	if perr != nil {
+		return nil, perr
+	}
+
+	log.Infof("Began TOTP enrolment for user [user-name=%s]", user.Uid)
+	return &enrolbegin.Response{
+		Secret: proto.String(secret),
+		Uri:    proto.String(domain.TotpUri(app, user.Uid, secret)),
+	}, nil
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/domain"
+	enrolconfirm "github.com/HailoOSS/login-service/proto/mfaenrolconfirm"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// MfaEnrolConfirm completes TOTP enrolment, proving the user's authenticator has the pending secret, and
+// issues the user's first set of recovery codes
+func MfaEnrolConfirm(req *server.Request) (proto.Message, errors.Error) {
+	request := &enrolconfirm.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.mfaenrolconfirm.unmarshal", err.Error())
+	}
+
+	app := domain.Application(request.GetApplication())
+	user, perr := readMfaUser("mfaenrolconfirm", app, request.GetUsername(), request.GetPassword(), true)
+	if perr != nil {
+		return nil, perr
+	}
+
+	var codes []string
+	perr = updateMfa("mfaenrolconfirm", user, func(u *domain.User) errors.Error {
+		if len(u.TotpPendingSecret) == 0 {
+			return errors.BadRequest("com.HailoOSS.service.login.mfaenrolconfirm.notbegun", "No enrolment in progress")
+		}
+		step, ok := domain.MatchTotp(u.TotpPendingSecret, request.GetTotpCode(), time.Now())
+		if !ok {
+			return errors.Forbidden("com.HailoOSS.service.login.mfaenrolconfirm.invalidcode", "Invalid TOTP code")
+		}
+
+		// the code that confirmed enrolment can't then be used to log in
+		u.TotpSecret, u.TotpPendingSecret, u.TotpLastStep = u.TotpPendingSecret, "", step
+		var err error
+		if codes, err = u.NewRecoveryCodes(); err != nil {
+			return errors.InternalServerError("com.HailoOSS.service.login.mfaenrolconfirm.recoverycodes", err.Error())
+		}
+		return nil
+	})
+	if perr != nil {
+		return nil, perr
+	}
+
+	log.Infof("Confirmed TOTP enrolment for user [user-name=%s]", user.Uid)
+	return &enrolconfirm.Response{
+		RecoveryCodes: codes,
+	}, nil
+}
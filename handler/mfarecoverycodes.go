@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/domain"
+	recoverycodes "github.com/HailoOSS/login-service/proto/mfarecoverycodes"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// MfaRecoveryCodes regenerates a user's recovery codes, invalidating all those issued before
+func MfaRecoveryCodes(req *server.Request) (proto.Message, errors.Error) {
+	request := &recoverycodes.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.mfarecoverycodes.unmarshal", err.Error())
+	}
+
+	app := domain.Application(request.GetApplication())
+	user, perr := readMfaUser("mfarecoverycodes", app, request.GetUsername(), request.GetPassword(), true)
+	if perr != nil {
+		return nil, perr
+	}
+
+	var codes []string
+	perr = updateMfa("mfarecoverycodes", user, func(u *domain.User) errors.Error {
+		if !u.MfaEnrolled() {
+			return errors.BadRequest("com.HailoOSS.service.login.mfarecoverycodes.notenrolled", "Not enrolled")
+		}
+		if !u.UseTotpCode(request.GetTotpCode()) {
+			return errors.Forbidden("com.HailoOSS.service.login.mfarecoverycodes.invalidcode", "Invalid TOTP code")
+		}
+		var err error
+		if codes, err = u.NewRecoveryCodes(); err != nil {
+			return errors.InternalServerError("com.HailoOSS.service.login.mfarecoverycodes.generate", err.Error())
+		}
+		return nil
+	})
+	if perr != nil {
+		return nil, perr
+	}
+
+	log.Infof("Regenerated recovery codes for user [user-name=%s]", user.Uid)
+	return &recoverycodes.Response{
+		RecoveryCodes: codes,
+	}, nil
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/domain"
+	mfaremove "github.com/HailoOSS/login-service/proto/mfaremove"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// MfaRemove removes a user's TOTP enrolment (confirmed or pending) and recovery codes. Users must supply their password and a
+// current code; a real person with ADMIN can remove anyone's, eg: when they have lost their device.
+func MfaRemove(req *server.Request) (proto.Message, errors.Error) {
+	request := &mfaremove.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.mfaremove.unmarshal", err.Error())
+	}
+
+	isAdmin := req.Auth().IsAuth() && req.Auth().AuthUser().HasRole("ADMIN")
+
+	app := domain.Application(request.GetApplication())
+	user, perr := readMfaUser("mfaremove", app, request.GetUsername(), request.GetPassword(), !isAdmin)
+	if perr != nil {
+		return nil, perr
+	}
+	perr = updateMfa("mfaremove", user, func(u *domain.User) errors.Error {
+		if !isAdmin && u.MfaEnrolled() && !u.UseTotpCode(request.GetTotpCode()) {
+			return errors.Forbidden("com.HailoOSS.service.login.mfaremove.invalidcode", "Invalid TOTP code")
+		}
+		u.TotpSecret, u.TotpPendingSecret = "", ""
+		u.RecoveryCodes = nil
+		return nil
+	})
+	if perr != nil {
+		return nil, perr
+	}
+
+	log.Infof("Removed TOTP enrolment for user [user-name=%s]", user.Uid)
+	return &mfaremove.Response{}, nil
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	readproto "github.com/HailoOSS/login-service/proto/readfailedlogins"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// ReadFailedLogins will fetch a list of failed logins between two dates for a given user
+func ReadFailedLogins(req *server.Request) (proto.Message, errors.Error) {
+	request := &readproto.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.readfailedlogins.unmarshal", err.Error())
+	}
+
+	start := protoToTime(request.RangeStart, time.Now().AddDate(0, -1, 0))
+	end := protoToTime(request.RangeEnd, time.Now())
+	count := request.GetCount()
+	lastId := request.GetLastId()
+
+	failures, lastId, err := dao.ReadUserFailedLogins(domain.Application(request.GetApplication()), request.GetUid(), start, end, int(count), lastId)
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.readfailedlogins.dao.read", err.Error())
+	}
+
+	return &readproto.Response{
+		FailedLogin: failedLoginsToProto(failures),
+		LastId:      proto.String(lastId),
+	}, nil
+}
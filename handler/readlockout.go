@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	readlockoutproto "github.com/HailoOSS/login-service/proto/readlockout"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// ReadLockout reports a user's recent failed logins and whether they are locked out
+func ReadLockout(req *server.Request) (proto.Message, errors.Error) {
+	request := &readlockoutproto.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest(server.Name+".readlockout.unmarshal", err.Error())
+	}
+
+	lockout, err := dao.ReadLockout(domain.Application(request.GetApplication()), request.GetUid())
+	if err != nil {
+		return nil, errors.InternalServerError(server.Name+".readlockout.dao", err.Error())
+	}
+	if lockout == nil {
+		return &readlockoutproto.Response{
+			Failures: proto.Int64(0),
+			Locked:   proto.Bool(false),
+		}, nil
+	}
+
+	return &readlockoutproto.Response{
+		Failures:              proto.Int64(int64(lockout.Failures)),
+		Locked:                proto.Bool(lockout.IsLocked(time.Now())),
+		FirstFailureTimestamp: timeToProto(lockout.FirstFailure),
+		LastFailureTimestamp:  timeToProto(lockout.LastFailure),
+		LockedUntilTimestamp:  timeToProto(lockout.LockedUntil),
+	}, nil
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	readproto "github.com/HailoOSS/login-service/proto/readpublickeys"
+	"github.com/HailoOSS/login-service/signer"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// ReadPublicKeys returns the public keys tokens can be verified with (active, staged and recently retired),
+// so that verifiers can fetch them rather than be provisioned with key files
+func ReadPublicKeys(req *server.Request) (proto.Message, errors.Error) {
+	rotations, err := dao.ReadKeyRotations()
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.readpublickeys.dao.read", err.Error())
+	}
+	keys, err := signer.PublicKeys(rotations, domain.MaxTokenTtl())
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.readpublickeys.signer", err.Error())
+	}
+
+	rsp := &readproto.Response{
+		Key: make([]*readproto.PublicKey, len(keys)),
+	}
+	for i, k := range keys {
+		pem, err := k.PEM()
+		if err != nil {
+			return nil, errors.InternalServerError("com.HailoOSS.service.login.readpublickeys.marshal", err.Error())
+		}
+		rsp.Key[i] = &readproto.PublicKey{
+			KeyId:     proto.String(k.KeyId),
+			Pem:       proto.String(pem),
+			Status:    proto.String(k.Status),
+			NotBefore: timeToProto(k.NotBefore),
+			NotAfter:  timeToProto(k.NotAfter),
+		}
+	}
+	jwks, err := signer.JWKS(keys)
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.readpublickeys.marshal", err.Error())
+	}
+	rsp.Jwks = proto.String(string(jwks))
+
+	return rsp, nil
+}
@@ -15,7 +15,8 @@ import (
 
 // ReadSession will retrieve token/session information based on session ID.
 // By default this will auto-renew tokens for active sessions that have expired
-// or near-expired tokens - although this can be disabled.
+// or near-expired tokens - although this can be disabled. Sessions that have
+// been idle for longer than their application allows are expired instead.
 func ReadSession(req *server.Request) (proto.Message, errors.Error) {
 	request := &readsession.Request{}
 	if err := req.Unmarshal(request); err != nil {
@@ -34,6 +35,12 @@ func ReadSession(req *server.Request) (proto.Message, errors.Error) {
 
 	log.Debugf("Got back sess %#v", sess)
 
+	if sess, err = auther.Touch(sess); err == auther.ErrorSessionIdle {
+		return nil, errors.NotFound("com.HailoOSS.service.login.readsession.idle", fmt.Sprintf("Session %v expired after being idle", request.GetSessId()))
+	} else if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.readsession.touch", err.Error())
+	}
+
 	// noRenew means we DON'T want to automatically extend the lifetime of this session
 	if !request.GetNoRenew() {
 		updated, err := auther.AutoRenew(sess)
@@ -50,6 +57,10 @@ func ReadSession(req *server.Request) (proto.Message, errors.Error) {
 		SessId: proto.String(sess.Id),
 		Token:  proto.String(sess.Token.String()),
 	}
+	var jwtErr errors.Error
+	if rsp.Jwt, jwtErr = sessionJWT("readsession", request.GetJwt(), sess); jwtErr != nil {
+		return nil, jwtErr
+	}
 
 	return rsp, nil
 }
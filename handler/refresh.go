@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/auther"
+	"github.com/HailoOSS/login-service/domain"
+	refreshproto "github.com/HailoOSS/login-service/proto/refresh"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// Refresh exchanges a refresh token for a new token for its session, and a new refresh token
+func Refresh(req *server.Request) (proto.Message, errors.Error) {
+	request := &refreshproto.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.refresh.unmarshal", err.Error())
+	}
+
+	sess, refreshToken, err := auther.Refresh(request.GetRefreshToken())
+	switch err {
+	case nil:
+	case auther.ErrorRefreshTokenInvalid:
+		return nil, errors.Forbidden("com.HailoOSS.service.login.refresh.invalid", err.Error())
+	case auther.ErrorRefreshTokenReused:
+		return nil, errors.Forbidden("com.HailoOSS.service.login.refresh.reused", err.Error())
+	case auther.ErrorRefreshSessionEnded:
+		return nil, errors.Forbidden("com.HailoOSS.service.login.refresh.ended", err.Error())
+	case auther.ErrorRefreshSessionIdle:
+		return nil, errors.Forbidden("com.HailoOSS.service.login.refresh.idle", err.Error())
+	case auther.ErrorAccountIsDisabled:
+		return nil, errors.Forbidden("com.HailoOSS.service.login.refresh.accountdisabled", err.Error())
+	case auther.ErrorAccountIsExpired:
+		return nil, errors.Forbidden("com.HailoOSS.service.login.refresh.accountexpired", err.Error())
+	default:
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.refresh.auther", err.Error())
+	}
+
+	rsp := &refreshproto.Response{
+		SessId:       proto.String(sess.Id),
+		Token:        proto.String(sess.Token.String()),
+		RefreshToken: proto.String(refreshToken),
+	}
+	var jwtErr errors.Error
+	if rsp.Jwt, jwtErr = sessionJWT("refresh", request.GetJwt(), sess); jwtErr != nil {
+		return nil, jwtErr
+	}
+
+	return rsp, nil
+}
+
+// issueRefreshToken issues a refresh token for the endpoint's response if the client asked for one, returning
+// the updated session
+func issueRefreshToken(endpoint string, want bool, sess *domain.Session) (*domain.Session, *string, errors.Error) {
+	if !want {
+		return sess, nil, nil
+	}
+	updated, refreshToken, err := auther.IssueRefreshToken(sess)
+	if err == auther.ErrorRefreshDisabled {
+		return nil, nil, errors.BadRequest(fmt.Sprintf("com.HailoOSS.service.login.%s.refresh-disabled", endpoint), err.Error())
+	} else if err != nil {
+		return nil, nil, errors.InternalServerError(fmt.Sprintf("com.HailoOSS.service.login.%s.refreshtoken", endpoint), err.Error())
+	}
+	return updated, proto.String(refreshToken), nil
+}
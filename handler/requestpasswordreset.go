@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/auther"
+	"github.com/HailoOSS/login-service/constants"
+	"github.com/HailoOSS/login-service/domain"
+	rpr "github.com/HailoOSS/login-service/proto/requestpasswordreset"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// RequestPasswordReset sends a user who has forgotten their password a single-use reset token. The response
+// is the same whether or not the user exists.
+func RequestPasswordReset(req *server.Request) (proto.Message, errors.Error) {
+	request := &rpr.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.requestpasswordreset.unmarshal", err.Error())
+	}
+
+	app := domain.Application(request.GetApplication())
+	switch err := auther.RequestPasswordReset(app, request.GetUsername()); err {
+	case nil:
+	case auther.ErrorPasswordResetDisabled:
+		return nil, errors.BadRequest("com.HailoOSS.service.login.requestpasswordreset.disabled", err.Error())
+	case auther.ErrorRateLimited:
+		return nil, errors.Forbidden(constants.RateLimitedErrCode, err.Error())
+	default:
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.requestpasswordreset.auther", err.Error())
+	}
+
+	return &rpr.Response{}, nil
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/dao"
+	rotateproto "github.com/HailoOSS/login-service/proto/rotatesigningkey"
+	"github.com/HailoOSS/login-service/signer"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// RotateSigningKey promotes a key from the keyring to be the one tokens are signed with. The key must
+// already be deployed (staged) on every instance; other instances pick up the change within a minute, and
+// carry on verifying tokens signed with the previous key.
+func RotateSigningKey(req *server.Request) (proto.Message, errors.Error) {
+	request := &rotateproto.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.rotatesigningkey.unmarshal", err.Error())
+	}
+
+	previous := signer.ActiveKeyId()
+	if err := signer.Promote(request.GetKeyId()); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.rotatesigningkey.promote", err.Error())
+	}
+	if err := dao.WriteActiveKeyId(request.GetKeyId()); err != nil {
+		// don't leave this instance signing with a key the others don't know is active
+		if perr := signer.Promote(previous); perr != nil {
+			log.Errorf("Failed to revert to signing key '%v': %v", previous, perr)
+		}
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.rotatesigningkey.dao", err.Error())
+	}
+
+	log.Infof("Rotated signing key [previous=%s] [active=%s]", previous, request.GetKeyId())
+	return &rotateproto.Response{
+		ActiveKeyId:   proto.String(request.GetKeyId()),
+		PreviousKeyId: proto.String(previous),
+	}, nil
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	sp "github.com/HailoOSS/login-service/proto/scorepassword"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// ScorePassword tests a candidate password against an application's policy without setting it, so that UIs
+// can give feedback as it's typed. Anyone can score a password for a new user, but it's only tested against
+// an existing user's password history (and IDs) for that user themselves or an ADMIN, so that it can't be
+// used to guess their old passwords.
+func ScorePassword(req *server.Request) (proto.Message, errors.Error) {
+	request := &sp.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.service.login.scorepassword.unmarshal", err.Error())
+	}
+
+	app := domain.Application(request.GetApplication())
+	user := &domain.User{App: app, Uid: request.GetUsername()}
+	if request.GetUsername() != "" && req.Auth().IsAuth() {
+		caller := req.Auth().AuthUser()
+		existing, err := dao.ReadUser(app, request.GetUsername())
+		if err != nil {
+			return nil, errors.InternalServerError("com.HailoOSS.service.login.scorepassword.readuser",
+				fmt.Sprintf("Error reading user: %v", err))
+		}
+		if existing != nil && (caller.Id == existing.Uid || caller.HasRole("ADMIN")) {
+			user = existing
+		}
+	}
+
+	failures := domain.PasswordFailures(request.GetPassword(), user)
+	rsp := &sp.Response{
+		Score:    proto.Int32(int32(domain.PasswordStrength(request.GetPassword(), user))),
+		Valid:    proto.Bool(len(failures) == 0),
+		Failures: make([]string, len(failures)),
+	}
+	for i, err := range failures {
+		rsp.Failures[i] = err.Error()
+	}
+
+	return rsp, nil
+}
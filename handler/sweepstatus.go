@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/login-service/dao"
+	sweepstatusproto "github.com/HailoOSS/login-service/proto/sweepstatus"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// SweepStatus reports the session sweeper's last checkpointed progress
+func SweepStatus(req *server.Request) (proto.Message, errors.Error) {
+	progress, err := dao.SweepStatus()
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.service.login.sweepstatus.dao", err.Error())
+	}
+
+	return &sweepstatusproto.Response{
+		Phase:                 proto.String(progress.Phase),
+		Position:              proto.String(progress.Position),
+		Passes:                proto.Int64(progress.Passes),
+		StartedTimestamp:      timeToProto(progress.Started),
+		FinishedTimestamp:     timeToProto(progress.Finished),
+		CheckpointedTimestamp: timeToProto(progress.Checkpointed),
+		SessionsScanned:       proto.Int64(progress.SessionsScanned),
+		SessionsDeleted:       proto.Int64(progress.SessionsDeleted),
+		UserSessionsScanned:   proto.Int64(progress.UserSessionsScanned),
+		UserSessionsDeleted:   proto.Int64(progress.UserSessionsDeleted),
+	}, nil
+}
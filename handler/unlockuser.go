@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
+	unlockuserproto "github.com/HailoOSS/login-service/proto/unlockuser"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// UnlockUser forgets a user's recent failed logins, lifting any lockout
+func UnlockUser(req *server.Request) (proto.Message, errors.Error) {
+	request := &unlockuserproto.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest(server.Name+".unlockuser.unmarshal", err.Error())
+	}
+
+	if err := dao.DeleteLockout(domain.Application(request.GetApplication()), request.GetUid()); err != nil {
+		return nil, errors.InternalServerError(server.Name+".unlockuser.dao", err.Error())
+	}
+
+	log.Infof("Unlocked user [user-name=%s]", request.GetUid())
+	return &unlockuserproto.Response{}, nil
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"fmt"
 	"time"
 
+	log "github.com/cihub/seelog"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
 	"github.com/HailoOSS/login-service/dao"
+	"github.com/HailoOSS/login-service/domain"
 	"github.com/HailoOSS/login-service/handler"
 	authproto "github.com/HailoOSS/login-service/proto/auth"
 	authasproto "github.com/HailoOSS/login-service/proto/authas"
@@ -11,6 +17,7 @@ import (
 	changeidsproto "github.com/HailoOSS/login-service/proto/changeids"
 	changepasswordproto "github.com/HailoOSS/login-service/proto/changepassword"
 	changeuserstatusproto "github.com/HailoOSS/login-service/proto/changeuserstatus"
+	completepasswordresetproto "github.com/HailoOSS/login-service/proto/completepasswordreset"
 	createuserproto "github.com/HailoOSS/login-service/proto/createuser"
 	deleteindexproto "github.com/HailoOSS/login-service/proto/deleteindex"
 	deletesessionproto "github.com/HailoOSS/login-service/proto/deletesession"
@@ -19,20 +26,36 @@ import (
 	expirepasswordproto "github.com/HailoOSS/login-service/proto/expirepassword"
 	grantserviceproto "github.com/HailoOSS/login-service/proto/grantservice"
 	grantuserproto "github.com/HailoOSS/login-service/proto/grantuser"
+	introspectproto "github.com/HailoOSS/login-service/proto/introspect"
 	listsessionsproto "github.com/HailoOSS/login-service/proto/listsessions"
 	listusersproto "github.com/HailoOSS/login-service/proto/listusers"
 	logoutuserproto "github.com/HailoOSS/login-service/proto/logoutuser"
+	mfaenrolbeginproto "github.com/HailoOSS/login-service/proto/mfaenrolbegin"
+	mfaenrolconfirmproto "github.com/HailoOSS/login-service/proto/mfaenrolconfirm"
+	mfarecoverycodesproto "github.com/HailoOSS/login-service/proto/mfarecoverycodes"
+	mfaremoveproto "github.com/HailoOSS/login-service/proto/mfaremove"
+	readfailedloginsproto "github.com/HailoOSS/login-service/proto/readfailedlogins"
+	readlockoutproto "github.com/HailoOSS/login-service/proto/readlockout"
 	readloginproto "github.com/HailoOSS/login-service/proto/readlogin"
+	readpublickeysproto "github.com/HailoOSS/login-service/proto/readpublickeys"
 	readsessionproto "github.com/HailoOSS/login-service/proto/readsession"
 	readuserproto "github.com/HailoOSS/login-service/proto/readuser"
 	readusermultiproto "github.com/HailoOSS/login-service/proto/readusermulti"
+	refreshproto "github.com/HailoOSS/login-service/proto/refresh"
+	requestpasswordresetproto "github.com/HailoOSS/login-service/proto/requestpasswordreset"
 	revokeserviceproto "github.com/HailoOSS/login-service/proto/revokeservice"
 	revokeuserproto "github.com/HailoOSS/login-service/proto/revokeuser"
+	rotatesigningkeyproto "github.com/HailoOSS/login-service/proto/rotatesigningkey"
+	scorepasswordproto "github.com/HailoOSS/login-service/proto/scorepassword"
 	setpasswordhashproto "github.com/HailoOSS/login-service/proto/setpasswordhash"
+	sweepstatusproto "github.com/HailoOSS/login-service/proto/sweepstatus"
+	unlockuserproto "github.com/HailoOSS/login-service/proto/unlockuser"
 	updateuserrolesproto "github.com/HailoOSS/login-service/proto/updateuserroles"
 	"github.com/HailoOSS/login-service/sessinvalidator"
+	"github.com/HailoOSS/login-service/signer"
 	service "github.com/HailoOSS/platform/server"
 	"github.com/HailoOSS/service/cassandra"
+	"github.com/HailoOSS/service/config"
 	"github.com/HailoOSS/service/nsq"
 	"github.com/HailoOSS/service/zookeeper"
 )
@@ -47,6 +70,25 @@ func main() {
 
 	service.Init()
 
+	// pick our storage backend -- Cassandra unless configured otherwise
+	store := config.AtPath("hailo", "service", "login", "store").AsString(dao.DefaultStore)
+	if err := dao.SelectStore(store); err != nil {
+		log.Flush()
+		panic(fmt.Sprintf("Failed to select storage backend: %v", err))
+	}
+
+	// peppers are optional, but a broken pepper directory must stop us hashing passwords without them
+	if err := domain.LoadPeppers(); err != nil {
+		log.Flush()
+		panic(fmt.Sprintf("Failed to load password peppers: %v", err))
+	}
+	go domain.ReloadPeppersEvery(time.Minute)
+
+	if err := domain.LoadPasswordBlocklist(); err != nil {
+		log.Flush()
+		panic(fmt.Sprintf("Failed to load password blocklist: %v", err))
+	}
+
 	service.Register(&service.Endpoint{
 		Name:             "auth",
 		Mean:             500,
@@ -57,6 +99,16 @@ func main() {
 		ResponseProtocol: new(authproto.Response),
 	})
 
+	service.Register(&service.Endpoint{
+		Name:             "refresh",
+		Mean:             100,
+		Upper95:          500,
+		Handler:          handler.Refresh,
+		Authoriser:       service.OpenToTheWorldAuthoriser(),
+		RequestProtocol:  new(refreshproto.Request),
+		ResponseProtocol: new(refreshproto.Response),
+	})
+
 	service.Register(&service.Endpoint{
 		Name:             "authas",
 		Mean:             500,
@@ -96,6 +148,15 @@ func main() {
 			RequestProtocol:  new(readsessionproto.Request),
 			ResponseProtocol: new(readsessionproto.Response),
 		},
+		&service.Endpoint{
+			Name:             "introspect",
+			Mean:             50,
+			Upper95:          200,
+			Handler:          handler.Introspect,
+			Authoriser:       service.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(introspectproto.Request),
+			ResponseProtocol: new(introspectproto.Response),
+		},
 		&service.Endpoint{
 			Name:    "deletesession",
 			Mean:    50,
@@ -177,6 +238,33 @@ func main() {
 			Handler:    handler.ReindexUsers,
 			Authoriser: service.RoleAuthoriser([]string{"ADMIN"}),
 		},
+		&service.Endpoint{
+			Name:             "sweepstatus",
+			Mean:             50,
+			Upper95:          200,
+			Handler:          handler.SweepStatus,
+			Authoriser:       service.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(sweepstatusproto.Request),
+			ResponseProtocol: new(sweepstatusproto.Response),
+		},
+		&service.Endpoint{
+			Name:             "readpublickeys",
+			Mean:             50,
+			Upper95:          200,
+			Handler:          handler.ReadPublicKeys,
+			Authoriser:       service.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(readpublickeysproto.Request),
+			ResponseProtocol: new(readpublickeysproto.Response),
+		},
+		&service.Endpoint{
+			Name:             "rotatesigningkey",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.RotateSigningKey,
+			Authoriser:       service.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(rotatesigningkeyproto.Request),
+			ResponseProtocol: new(rotatesigningkeyproto.Response),
+		},
 		&service.Endpoint{
 			Name:    "endpointauth",
 			Mean:    100,
@@ -214,6 +302,15 @@ func main() {
 			RequestProtocol:  new(readloginproto.Request),
 			ResponseProtocol: new(readloginproto.Response),
 		},
+		&service.Endpoint{
+			Name:             "readfailedlogins",
+			Mean:             50,
+			Upper95:          200,
+			Handler:          handler.ReadFailedLogins,
+			Authoriser:       service.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(readfailedloginsproto.Request),
+			ResponseProtocol: new(readfailedloginsproto.Response),
+		},
 		&service.Endpoint{
 			Name:             "changeids",
 			Mean:             100,
@@ -232,6 +329,69 @@ func main() {
 			RequestProtocol:  new(changepasswordproto.Request),
 			ResponseProtocol: new(changepasswordproto.Response),
 		},
+		&service.Endpoint{
+			Name:             "requestpasswordreset",
+			Mean:             150,
+			Upper95:          500,
+			Handler:          handler.RequestPasswordReset,
+			Authoriser:       service.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(requestpasswordresetproto.Request),
+			ResponseProtocol: new(requestpasswordresetproto.Response),
+		},
+		&service.Endpoint{
+			Name:             "completepasswordreset",
+			Mean:             150,
+			Upper95:          500,
+			Handler:          handler.CompletePasswordReset,
+			Authoriser:       service.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(completepasswordresetproto.Request),
+			ResponseProtocol: new(completepasswordresetproto.Response),
+		},
+		&service.Endpoint{
+			Name:             "scorepassword",
+			Mean:             50,
+			Upper95:          200,
+			Handler:          handler.ScorePassword,
+			Authoriser:       service.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(scorepasswordproto.Request),
+			ResponseProtocol: new(scorepasswordproto.Response),
+		},
+		&service.Endpoint{
+			Name:             "mfaenrolbegin",
+			Mean:             150,
+			Upper95:          500,
+			Handler:          handler.MfaEnrolBegin,
+			Authoriser:       service.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(mfaenrolbeginproto.Request),
+			ResponseProtocol: new(mfaenrolbeginproto.Response),
+		},
+		&service.Endpoint{
+			Name:             "mfaenrolconfirm",
+			Mean:             500,
+			Upper95:          2000,
+			Handler:          handler.MfaEnrolConfirm,
+			Authoriser:       service.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(mfaenrolconfirmproto.Request),
+			ResponseProtocol: new(mfaenrolconfirmproto.Response),
+		},
+		&service.Endpoint{
+			Name:             "mfaremove",
+			Mean:             150,
+			Upper95:          500,
+			Handler:          handler.MfaRemove,
+			Authoriser:       service.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(mfaremoveproto.Request),
+			ResponseProtocol: new(mfaremoveproto.Response),
+		},
+		&service.Endpoint{
+			Name:             "mfarecoverycodes",
+			Mean:             500,
+			Upper95:          2000,
+			Handler:          handler.MfaRecoveryCodes,
+			Authoriser:       service.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(mfarecoverycodesproto.Request),
+			ResponseProtocol: new(mfarecoverycodesproto.Response),
+		},
 		&service.Endpoint{
 			Name:             "expirepassword",
 			Mean:             100,
@@ -241,6 +401,24 @@ func main() {
 			RequestProtocol:  new(expirepasswordproto.Request),
 			ResponseProtocol: new(expirepasswordproto.Response),
 		},
+		&service.Endpoint{
+			Name:             "readlockout",
+			Mean:             50,
+			Upper95:          200,
+			Handler:          handler.ReadLockout,
+			Authoriser:       service.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(readlockoutproto.Request),
+			ResponseProtocol: new(readlockoutproto.Response),
+		},
+		&service.Endpoint{
+			Name:             "unlockuser",
+			Mean:             50,
+			Upper95:          200,
+			Handler:          handler.UnlockUser,
+			Authoriser:       service.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(unlockuserproto.Request),
+			ResponseProtocol: new(unlockuserproto.Response),
+		},
 		&service.Endpoint{
 			Name:             "setpasswordhash",
 			Mean:             150,
@@ -300,8 +478,16 @@ func main() {
 	// run our session expirer
 	service.RegisterPostConnectHandler(sessinvalidator.Run)
 
+	// and the sweeper that garbage-collects expired sessions
+	service.RegisterPostConnectHandler(dao.RunSweeper)
+
+	// and follow signing key rotations made via any instance
+	service.RegisterPostConnectHandler(func() { signer.WatchActiveKey(dao.ReadActiveKeyId) })
+
 	// add healthchecks
-	service.HealthCheck(cassandra.HealthCheckId, cassandra.HealthCheck(dao.Keyspace, dao.Cfs))
+	if store == dao.DefaultStore {
+		service.HealthCheck(cassandra.HealthCheckId, cassandra.HealthCheck(dao.Keyspace, dao.Cfs))
+	}
 	service.HealthCheck(zookeeper.HealthCheckId, zookeeper.HealthCheck())
 	service.HealthCheck(nsq.HealthCheckId, nsq.HealthCheck())
 	service.HealthCheck(nsq.HighWatermarkId, nsq.HighWatermark(sessinvalidator.TopicName, sessinvalidator.ChannelName, 50))
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/HailoOSS/login-service/domain"
+)
+
+// mkblocklist turns a text list of password SHA-1s (eg: a Have I Been Pwned download) into a Bloom filter
+// that the login service can load as its password blocklist, at a fraction of the size
+func main() {
+	in := flag.String("in", "", "Text file of hex SHA-1s, one per line (default stdin)")
+	out := flag.String("out", "password-blocklist", "Where to write the Bloom filter")
+	fpRate := flag.Float64("fp", 0.001, "False positive rate, ie: the proportion of good passwords rejected")
+	n := flag.Int("n", 0, "How many SHA-1s the input has (default counted with a first pass over -in)")
+	flag.Parse()
+
+	r := os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			fmt.Println("Failed to open input:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// the filter is sized up front, so that the list can be streamed through it rather than held in memory
+	if *n <= 0 {
+		if *in == "" {
+			fmt.Println("-n is required when reading from stdin")
+			os.Exit(1)
+		}
+		count, err := domain.CountHashList(bufio.NewReader(r))
+		if err == nil {
+			_, err = r.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			fmt.Println("Failed to count input:", err)
+			os.Exit(1)
+		}
+		*n = count
+	}
+
+	filter, err := domain.BuildBloomFilter(bufio.NewReader(r), *n, *fpRate)
+	if err != nil {
+		fmt.Println("Failed to build Bloom filter:", err)
+		os.Exit(1)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		fmt.Println("Failed to create output:", err)
+		os.Exit(1)
+	}
+	w := bufio.NewWriter(f)
+	_, err = filter.WriteTo(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		fmt.Println("Failed to write Bloom filter:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Wrote Bloom filter to", *out)
+}
@@ -39,10 +39,18 @@ type Request struct {
 	// meta data is optional meta data for h2 logins to attach to the login record, things like IP etc.
 	Meta []*com_HailoOSS_service_login.KeyValue `protobuf:"bytes,10,rep,name=meta" json:"meta,omitempty"`
 	// If true will authenticate the user with the current session
-	NoExpire         *bool   `protobuf:"varint,11,opt,name=noExpire" json:"noExpire,omitempty"`
-	OauthToken       *string `protobuf:"bytes,12,opt,name=oauthToken" json:"oauthToken,omitempty"`
-	Provider         *string `protobuf:"bytes,13,opt,name=provider" json:"provider,omitempty"`
-	XXX_unrecognized []byte  `json:"-"`
+	NoExpire   *bool   `protobuf:"varint,11,opt,name=noExpire" json:"noExpire,omitempty"`
+	OauthToken *string `protobuf:"bytes,12,opt,name=oauthToken" json:"oauthToken,omitempty"`
+	Provider   *string `protobuf:"bytes,13,opt,name=provider" json:"provider,omitempty"`
+	// totpCode is the second factor, for users enrolled in TOTP
+	TotpCode *string `protobuf:"bytes,14,opt,name=totpCode" json:"totpCode,omitempty"`
+	// recoveryCode is a single-use code that can be used instead of totpCode
+	RecoveryCode *string `protobuf:"bytes,15,opt,name=recoveryCode" json:"recoveryCode,omitempty"`
+	// jwt asks for the token as a JWT too
+	Jwt *bool `protobuf:"varint,16,opt,name=jwt" json:"jwt,omitempty"`
+	// refreshToken asks for a refresh token, if the application issues them
+	RefreshToken     *bool  `protobuf:"varint,17,opt,name=refreshToken" json:"refreshToken,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
 }
 
 func (m *Request) Reset()         { *m = Request{} }
@@ -140,9 +148,41 @@ func (m *Request) GetProvider() string {
 	return ""
 }
 
+func (m *Request) GetTotpCode() string {
+	if m != nil && m.TotpCode != nil {
+		return *m.TotpCode
+	}
+	return ""
+}
+
+func (m *Request) GetRecoveryCode() string {
+	if m != nil && m.RecoveryCode != nil {
+		return *m.RecoveryCode
+	}
+	return ""
+}
+
+func (m *Request) GetJwt() bool {
+	if m != nil && m.Jwt != nil {
+		return *m.Jwt
+	}
+	return false
+}
+
+func (m *Request) GetRefreshToken() bool {
+	if m != nil && m.RefreshToken != nil {
+		return *m.RefreshToken
+	}
+	return false
+}
+
 type Response struct {
-	SessId           *string `protobuf:"bytes,1,req,name=sessId" json:"sessId,omitempty"`
-	Token            *string `protobuf:"bytes,2,req,name=token" json:"token,omitempty"`
+	SessId *string `protobuf:"bytes,1,req,name=sessId" json:"sessId,omitempty"`
+	Token  *string `protobuf:"bytes,2,req,name=token" json:"token,omitempty"`
+	// jwt is the same token as a signed JWT, if asked for
+	Jwt *string `protobuf:"bytes,3,opt,name=jwt" json:"jwt,omitempty"`
+	// refreshToken can be exchanged once, via refresh, for a new token and refresh token
+	RefreshToken     *string `protobuf:"bytes,4,opt,name=refreshToken" json:"refreshToken,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -164,5 +204,19 @@ func (m *Response) GetToken() string {
 	return ""
 }
 
+func (m *Response) GetJwt() string {
+	if m != nil && m.Jwt != nil {
+		return *m.Jwt
+	}
+	return ""
+}
+
+func (m *Response) GetRefreshToken() string {
+	if m != nil && m.RefreshToken != nil {
+		return *m.RefreshToken
+	}
+	return ""
+}
+
 func init() {
 }
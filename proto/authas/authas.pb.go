@@ -30,8 +30,10 @@ type Request struct {
 	Application *string `protobuf:"bytes,2,opt,name=application" json:"application,omitempty"`
 	DeviceType  *string `protobuf:"bytes,3,opt,name=deviceType" json:"deviceType,omitempty"`
 	// meta data is optional meta data for h2 logins to attach to the login record, things like IP etc.
-	Meta             []*com_HailoOSS_service_login.KeyValue `protobuf:"bytes,4,rep,name=meta" json:"meta,omitempty"`
-	XXX_unrecognized []byte                                 `json:"-"`
+	Meta []*com_HailoOSS_service_login.KeyValue `protobuf:"bytes,4,rep,name=meta" json:"meta,omitempty"`
+	// jwt asks for the token as a JWT too
+	Jwt              *bool  `protobuf:"varint,5,opt,name=jwt" json:"jwt,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
 }
 
 func (m *Request) Reset()         { *m = Request{} }
@@ -66,9 +68,18 @@ func (m *Request) GetMeta() []*com_HailoOSS_service_login.KeyValue {
 	return nil
 }
 
+func (m *Request) GetJwt() bool {
+	if m != nil && m.Jwt != nil {
+		return *m.Jwt
+	}
+	return false
+}
+
 type Response struct {
-	SessId           *string `protobuf:"bytes,1,req,name=sessId" json:"sessId,omitempty"`
-	Token            *string `protobuf:"bytes,2,req,name=token" json:"token,omitempty"`
+	SessId *string `protobuf:"bytes,1,req,name=sessId" json:"sessId,omitempty"`
+	Token  *string `protobuf:"bytes,2,req,name=token" json:"token,omitempty"`
+	// jwt is the same token as a signed JWT, if asked for
+	Jwt              *string `protobuf:"bytes,3,opt,name=jwt" json:"jwt,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -90,5 +101,12 @@ func (m *Response) GetToken() string {
 	return ""
 }
 
+func (m *Response) GetJwt() string {
+	if m != nil && m.Jwt != nil {
+		return *m.Jwt
+	}
+	return ""
+}
+
 func init() {
 }
@@ -0,0 +1,62 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/completepasswordreset/completepasswordreset.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_completepasswordreset is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/completepasswordreset/completepasswordreset.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_completepasswordreset
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	// The reset token sent to the user by requestpasswordreset
+	ResetToken *string `protobuf:"bytes,1,req,name=resetToken" json:"resetToken,omitempty"`
+	// The password to set, which must pass the application's policy
+	NewPassword      *string `protobuf:"bytes,2,req,name=newPassword" json:"newPassword,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetResetToken() string {
+	if m != nil && m.ResetToken != nil {
+		return *m.ResetToken
+	}
+	return ""
+}
+
+func (m *Request) GetNewPassword() string {
+	if m != nil && m.NewPassword != nil {
+		return *m.NewPassword
+	}
+	return ""
+}
+
+// Response is empty if the call was successful
+type Response struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func init() {
+}
@@ -0,0 +1,134 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/introspect/introspect.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_introspect is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/introspect/introspect.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_introspect
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	// token is a token string in either the colon-delimited or JWT format
+	Token            *string `protobuf:"bytes,1,req,name=token" json:"token,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetToken() string {
+	if m != nil && m.Token != nil {
+		return *m.Token
+	}
+	return ""
+}
+
+type Response struct {
+	Active *bool `protobuf:"varint,1,req,name=active" json:"active,omitempty"`
+	// tokenType is hailo or jwt
+	TokenType *string `protobuf:"bytes,2,opt,name=tokenType" json:"tokenType,omitempty"`
+	// the remaining fields are only set for active tokens, named as in RFC 7662 where it has a name
+	Sub              *string  `protobuf:"bytes,3,opt,name=sub" json:"sub,omitempty"`
+	SessId           *string  `protobuf:"bytes,4,opt,name=sessId" json:"sessId,omitempty"`
+	Iat              *int64   `protobuf:"varint,5,opt,name=iat" json:"iat,omitempty"`
+	Exp              *int64   `protobuf:"varint,6,opt,name=exp" json:"exp,omitempty"`
+	AuthMechanism    *string  `protobuf:"bytes,7,opt,name=authMechanism" json:"authMechanism,omitempty"`
+	DeviceType       *string  `protobuf:"bytes,8,opt,name=deviceType" json:"deviceType,omitempty"`
+	AutoRenew        *int64   `protobuf:"varint,9,opt,name=autoRenew" json:"autoRenew,omitempty"`
+	Roles            []string `protobuf:"bytes,10,rep,name=roles" json:"roles,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetActive() bool {
+	if m != nil && m.Active != nil {
+		return *m.Active
+	}
+	return false
+}
+
+func (m *Response) GetTokenType() string {
+	if m != nil && m.TokenType != nil {
+		return *m.TokenType
+	}
+	return ""
+}
+
+func (m *Response) GetSub() string {
+	if m != nil && m.Sub != nil {
+		return *m.Sub
+	}
+	return ""
+}
+
+func (m *Response) GetSessId() string {
+	if m != nil && m.SessId != nil {
+		return *m.SessId
+	}
+	return ""
+}
+
+func (m *Response) GetIat() int64 {
+	if m != nil && m.Iat != nil {
+		return *m.Iat
+	}
+	return 0
+}
+
+func (m *Response) GetExp() int64 {
+	if m != nil && m.Exp != nil {
+		return *m.Exp
+	}
+	return 0
+}
+
+func (m *Response) GetAuthMechanism() string {
+	if m != nil && m.AuthMechanism != nil {
+		return *m.AuthMechanism
+	}
+	return ""
+}
+
+func (m *Response) GetDeviceType() string {
+	if m != nil && m.DeviceType != nil {
+		return *m.DeviceType
+	}
+	return ""
+}
+
+func (m *Response) GetAutoRenew() int64 {
+	if m != nil && m.AutoRenew != nil {
+		return *m.AutoRenew
+	}
+	return 0
+}
+
+func (m *Response) GetRoles() []string {
+	if m != nil {
+		return m.Roles
+	}
+	return nil
+}
+
+func init() {
+}
@@ -36,7 +36,9 @@ type Session struct {
 	CreatedTimestamp *int64  `protobuf:"varint,1,opt,name=createdTimestamp" json:"createdTimestamp,omitempty"`
 	Mech             *string `protobuf:"bytes,2,opt,name=mech" json:"mech,omitempty"`
 	DeviceType       *string `protobuf:"bytes,3,opt,name=deviceType" json:"deviceType,omitempty"`
-	XXX_unrecognized []byte  `json:"-"`
+	// when the user logged in; createdTimestamp is when the current token was minted
+	LoggedInTimestamp *int64 `protobuf:"varint,4,opt,name=loggedInTimestamp" json:"loggedInTimestamp,omitempty"`
+	XXX_unrecognized  []byte `json:"-"`
 }
 
 func (m *Session) Reset()         { *m = Session{} }
@@ -64,6 +66,13 @@ func (m *Session) GetDeviceType() string {
 	return ""
 }
 
+func (m *Session) GetLoggedInTimestamp() int64 {
+	if m != nil && m.LoggedInTimestamp != nil {
+		return *m.LoggedInTimestamp
+	}
+	return 0
+}
+
 type Response struct {
 	Sessions         []*Session `protobuf:"bytes,1,rep,name=sessions" json:"sessions,omitempty"`
 	Uid              *string    `protobuf:"bytes,2,opt,name=uid" json:"uid,omitempty"`
@@ -0,0 +1,88 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/mfaenrolbegin/mfaenrolbegin.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_mfaenrolbegin is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/mfaenrolbegin/mfaenrolbegin.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_mfaenrolbegin
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	// defines the application this data logically belongs to
+	Application *string `protobuf:"bytes,1,req,name=application" json:"application,omitempty"`
+	// Who is enrolling
+	Username *string `protobuf:"bytes,2,req,name=username" json:"username,omitempty"`
+	// The user's password
+	Password         *string `protobuf:"bytes,3,req,name=password" json:"password,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetApplication() string {
+	if m != nil && m.Application != nil {
+		return *m.Application
+	}
+	return ""
+}
+
+func (m *Request) GetUsername() string {
+	if m != nil && m.Username != nil {
+		return *m.Username
+	}
+	return ""
+}
+
+func (m *Request) GetPassword() string {
+	if m != nil && m.Password != nil {
+		return *m.Password
+	}
+	return ""
+}
+
+type Response struct {
+	// The base32 encoded TOTP shared secret
+	Secret *string `protobuf:"bytes,1,req,name=secret" json:"secret,omitempty"`
+	// otpauth:// URI for authenticator apps, usually shown as a QR code
+	Uri              *string `protobuf:"bytes,2,req,name=uri" json:"uri,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetSecret() string {
+	if m != nil && m.Secret != nil {
+		return *m.Secret
+	}
+	return ""
+}
+
+func (m *Response) GetUri() string {
+	if m != nil && m.Uri != nil {
+		return *m.Uri
+	}
+	return ""
+}
+
+func init() {
+}
@@ -0,0 +1,88 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/mfaenrolconfirm/mfaenrolconfirm.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_mfaenrolconfirm is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/mfaenrolconfirm/mfaenrolconfirm.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_mfaenrolconfirm
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	// defines the application this data logically belongs to
+	Application *string `protobuf:"bytes,1,req,name=application" json:"application,omitempty"`
+	// Who is enrolling
+	Username *string `protobuf:"bytes,2,req,name=username" json:"username,omitempty"`
+	// The user's password
+	Password *string `protobuf:"bytes,3,req,name=password" json:"password,omitempty"`
+	// A code generated from the secret returned by mfaenrolbegin
+	TotpCode         *string `protobuf:"bytes,4,req,name=totpCode" json:"totpCode,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetApplication() string {
+	if m != nil && m.Application != nil {
+		return *m.Application
+	}
+	return ""
+}
+
+func (m *Request) GetUsername() string {
+	if m != nil && m.Username != nil {
+		return *m.Username
+	}
+	return ""
+}
+
+func (m *Request) GetPassword() string {
+	if m != nil && m.Password != nil {
+		return *m.Password
+	}
+	return ""
+}
+
+func (m *Request) GetTotpCode() string {
+	if m != nil && m.TotpCode != nil {
+		return *m.TotpCode
+	}
+	return ""
+}
+
+type Response struct {
+	// Single-use codes that can stand in for a TOTP code, these are only ever returned once
+	RecoveryCodes    []string `protobuf:"bytes,1,rep,name=recoveryCodes" json:"recoveryCodes,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetRecoveryCodes() []string {
+	if m != nil {
+		return m.RecoveryCodes
+	}
+	return nil
+}
+
+func init() {
+}
@@ -0,0 +1,188 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/readfailedlogins/readfailedlogins.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_readfailedlogins is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/readfailedlogins/readfailedlogins.proto
+
+It has these top-level messages:
+	Request
+	Response
+	FailedLogin
+*/
+package com_HailoOSS_service_login_readfailedlogins
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+import com_HailoOSS_service_login "github.com/HailoOSS/login-service/proto"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	// which user (or, for attempts on unknown users, the username tried)
+	Application *string `protobuf:"bytes,1,req,name=application" json:"application,omitempty"`
+	Uid         *string `protobuf:"bytes,2,req,name=uid" json:"uid,omitempty"`
+	// specify a time range to search between
+	RangeStart *int64 `protobuf:"varint,3,opt,name=rangeStart" json:"rangeStart,omitempty"`
+	RangeEnd   *int64 `protobuf:"varint,4,opt,name=rangeEnd" json:"rangeEnd,omitempty"`
+	// paginate
+	LastId           *string `protobuf:"bytes,5,opt,name=lastId" json:"lastId,omitempty"`
+	Count            *int32  `protobuf:"varint,6,opt,name=count,def=10" json:"count,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+const Default_Request_Count int32 = 10
+
+func (m *Request) GetApplication() string {
+	if m != nil && m.Application != nil {
+		return *m.Application
+	}
+	return ""
+}
+
+func (m *Request) GetUid() string {
+	if m != nil && m.Uid != nil {
+		return *m.Uid
+	}
+	return ""
+}
+
+func (m *Request) GetRangeStart() int64 {
+	if m != nil && m.RangeStart != nil {
+		return *m.RangeStart
+	}
+	return 0
+}
+
+func (m *Request) GetRangeEnd() int64 {
+	if m != nil && m.RangeEnd != nil {
+		return *m.RangeEnd
+	}
+	return 0
+}
+
+func (m *Request) GetLastId() string {
+	if m != nil && m.LastId != nil {
+		return *m.LastId
+	}
+	return ""
+}
+
+func (m *Request) GetCount() int32 {
+	if m != nil && m.Count != nil {
+		return *m.Count
+	}
+	return Default_Request_Count
+}
+
+type Response struct {
+	FailedLogin      []*FailedLogin `protobuf:"bytes,1,rep,name=failedLogin" json:"failedLogin,omitempty"`
+	LastId           *string        `protobuf:"bytes,2,opt,name=lastId" json:"lastId,omitempty"`
+	XXX_unrecognized []byte         `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetFailedLogin() []*FailedLogin {
+	if m != nil {
+		return m.FailedLogin
+	}
+	return nil
+}
+
+func (m *Response) GetLastId() string {
+	if m != nil && m.LastId != nil {
+		return *m.LastId
+	}
+	return ""
+}
+
+type FailedLogin struct {
+	Application *string `protobuf:"bytes,1,opt,name=application" json:"application,omitempty"`
+	Uid         *string `protobuf:"bytes,2,opt,name=uid" json:"uid,omitempty"`
+	// the username tried, which may be any of the user's IDs
+	Username        *string `protobuf:"bytes,3,opt,name=username" json:"username,omitempty"`
+	FailedTimestamp *int64  `protobuf:"varint,4,opt,name=failedTimestamp" json:"failedTimestamp,omitempty"`
+	// unknownUser, badCredentials, mfaInvalid, locked or rateLimited
+	Reason           *string                                `protobuf:"bytes,5,opt,name=reason" json:"reason,omitempty"`
+	Mech             *string                                `protobuf:"bytes,6,opt,name=mech" json:"mech,omitempty"`
+	DeviceType       *string                                `protobuf:"bytes,7,opt,name=deviceType" json:"deviceType,omitempty"`
+	Meta             []*com_HailoOSS_service_login.KeyValue `protobuf:"bytes,8,rep,name=meta" json:"meta,omitempty"`
+	XXX_unrecognized []byte                                 `json:"-"`
+}
+
+func (m *FailedLogin) Reset()         { *m = FailedLogin{} }
+func (m *FailedLogin) String() string { return proto.CompactTextString(m) }
+func (*FailedLogin) ProtoMessage()    {}
+
+func (m *FailedLogin) GetApplication() string {
+	if m != nil && m.Application != nil {
+		return *m.Application
+	}
+	return ""
+}
+
+func (m *FailedLogin) GetUid() string {
+	if m != nil && m.Uid != nil {
+		return *m.Uid
+	}
+	return ""
+}
+
+func (m *FailedLogin) GetUsername() string {
+	if m != nil && m.Username != nil {
+		return *m.Username
+	}
+	return ""
+}
+
+func (m *FailedLogin) GetFailedTimestamp() int64 {
+	if m != nil && m.FailedTimestamp != nil {
+		return *m.FailedTimestamp
+	}
+	return 0
+}
+
+func (m *FailedLogin) GetReason() string {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ""
+}
+
+func (m *FailedLogin) GetMech() string {
+	if m != nil && m.Mech != nil {
+		return *m.Mech
+	}
+	return ""
+}
+
+func (m *FailedLogin) GetDeviceType() string {
+	if m != nil && m.DeviceType != nil {
+		return *m.DeviceType
+	}
+	return ""
+}
+
+func (m *FailedLogin) GetMeta() []*com_HailoOSS_service_login.KeyValue {
+	if m != nil {
+		return m.Meta
+	}
+	return nil
+}
+
+func init() {
+}
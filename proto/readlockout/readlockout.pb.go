@@ -0,0 +1,101 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/readlockout/readlockout.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_readlockout is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/readlockout/readlockout.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_readlockout
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Application *string `protobuf:"bytes,1,req,name=application" json:"application,omitempty"`
+	// uid is the user's UID, or username for LDAP users
+	Uid              *string `protobuf:"bytes,2,req,name=uid" json:"uid,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetApplication() string {
+	if m != nil && m.Application != nil {
+		return *m.Application
+	}
+	return ""
+}
+
+func (m *Request) GetUid() string {
+	if m != nil && m.Uid != nil {
+		return *m.Uid
+	}
+	return ""
+}
+
+type Response struct {
+	// failures is the number of recent failed logins
+	Failures              *int64 `protobuf:"varint,1,req,name=failures" json:"failures,omitempty"`
+	Locked                *bool  `protobuf:"varint,2,req,name=locked" json:"locked,omitempty"`
+	FirstFailureTimestamp *int64 `protobuf:"varint,3,opt,name=firstFailureTimestamp" json:"firstFailureTimestamp,omitempty"`
+	LastFailureTimestamp  *int64 `protobuf:"varint,4,opt,name=lastFailureTimestamp" json:"lastFailureTimestamp,omitempty"`
+	LockedUntilTimestamp  *int64 `protobuf:"varint,5,opt,name=lockedUntilTimestamp" json:"lockedUntilTimestamp,omitempty"`
+	XXX_unrecognized      []byte `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetFailures() int64 {
+	if m != nil && m.Failures != nil {
+		return *m.Failures
+	}
+	return 0
+}
+
+func (m *Response) GetLocked() bool {
+	if m != nil && m.Locked != nil {
+		return *m.Locked
+	}
+	return false
+}
+
+func (m *Response) GetFirstFailureTimestamp() int64 {
+	if m != nil && m.FirstFailureTimestamp != nil {
+		return *m.FirstFailureTimestamp
+	}
+	return 0
+}
+
+func (m *Response) GetLastFailureTimestamp() int64 {
+	if m != nil && m.LastFailureTimestamp != nil {
+		return *m.LastFailureTimestamp
+	}
+	return 0
+}
+
+func (m *Response) GetLockedUntilTimestamp() int64 {
+	if m != nil && m.LockedUntilTimestamp != nil {
+		return *m.LockedUntilTimestamp
+	}
+	return 0
+}
+
+func init() {
+}
@@ -0,0 +1,115 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/readpublickeys/readpublickeys.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_readpublickeys is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/readpublickeys/readpublickeys.proto
+
+It has these top-level messages:
+	Request
+	Response
+	PublicKey
+*/
+package com_HailoOSS_service_login_readpublickeys
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+type Response struct {
+	// active, then staged, then recently retired keys
+	Key []*PublicKey `protobuf:"bytes,1,rep,name=key" json:"key,omitempty"`
+	// the same keys as a JSON Web Key Set document
+	Jwks             *string `protobuf:"bytes,2,opt,name=jwks" json:"jwks,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetKey() []*PublicKey {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *Response) GetJwks() string {
+	if m != nil && m.Jwks != nil {
+		return *m.Jwks
+	}
+	return ""
+}
+
+type PublicKey struct {
+	// matches the kid in tokens, empty for the legacy key
+	KeyId *string `protobuf:"bytes,1,opt,name=keyId" json:"keyId,omitempty"`
+	// PKIX, PEM encoded
+	Pem *string `protobuf:"bytes,2,opt,name=pem" json:"pem,omitempty"`
+	// active, staged or retired
+	Status *string `protobuf:"bytes,3,opt,name=status" json:"status,omitempty"`
+	// when the key started signing tokens, if it has
+	NotBefore *int64 `protobuf:"varint,4,opt,name=notBefore" json:"notBefore,omitempty"`
+	// when tokens signed with a retired key will all have expired
+	NotAfter         *int64 `protobuf:"varint,5,opt,name=notAfter" json:"notAfter,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *PublicKey) Reset()         { *m = PublicKey{} }
+func (m *PublicKey) String() string { return proto.CompactTextString(m) }
+func (*PublicKey) ProtoMessage()    {}
+
+func (m *PublicKey) GetKeyId() string {
+	if m != nil && m.KeyId != nil {
+		return *m.KeyId
+	}
+	return ""
+}
+
+func (m *PublicKey) GetPem() string {
+	if m != nil && m.Pem != nil {
+		return *m.Pem
+	}
+	return ""
+}
+
+func (m *PublicKey) GetStatus() string {
+	if m != nil && m.Status != nil {
+		return *m.Status
+	}
+	return ""
+}
+
+func (m *PublicKey) GetNotBefore() int64 {
+	if m != nil && m.NotBefore != nil {
+		return *m.NotBefore
+	}
+	return 0
+}
+
+func (m *PublicKey) GetNotAfter() int64 {
+	if m != nil && m.NotAfter != nil {
+		return *m.NotAfter
+	}
+	return 0
+}
+
+func init() {
+}
@@ -24,9 +24,11 @@ var _ = &json.SyntaxError{}
 var _ = math.Inf
 
 type Request struct {
-	SessId           *string `protobuf:"bytes,1,req,name=sessId" json:"sessId,omitempty"`
-	NoRenew          *bool   `protobuf:"varint,2,opt,name=noRenew" json:"noRenew,omitempty"`
-	XXX_unrecognized []byte  `json:"-"`
+	SessId  *string `protobuf:"bytes,1,req,name=sessId" json:"sessId,omitempty"`
+	NoRenew *bool   `protobuf:"varint,2,opt,name=noRenew" json:"noRenew,omitempty"`
+	// jwt asks for the token as a JWT too
+	Jwt              *bool  `protobuf:"varint,3,opt,name=jwt" json:"jwt,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
 }
 
 func (m *Request) Reset()         { *m = Request{} }
@@ -47,9 +49,18 @@ func (m *Request) GetNoRenew() bool {
 	return false
 }
 
+func (m *Request) GetJwt() bool {
+	if m != nil && m.Jwt != nil {
+		return *m.Jwt
+	}
+	return false
+}
+
 type Response struct {
-	SessId           *string `protobuf:"bytes,1,req,name=sessId" json:"sessId,omitempty"`
-	Token            *string `protobuf:"bytes,2,req,name=token" json:"token,omitempty"`
+	SessId *string `protobuf:"bytes,1,req,name=sessId" json:"sessId,omitempty"`
+	Token  *string `protobuf:"bytes,2,req,name=token" json:"token,omitempty"`
+	// jwt is the same token as a signed JWT, if asked for
+	Jwt              *string `protobuf:"bytes,3,opt,name=jwt" json:"jwt,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -71,5 +82,12 @@ func (m *Response) GetToken() string {
 	return ""
 }
 
+func (m *Response) GetJwt() string {
+	if m != nil && m.Jwt != nil {
+		return *m.Jwt
+	}
+	return ""
+}
+
 func init() {
 }
@@ -0,0 +1,94 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/refresh/refresh.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_refresh is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/refresh/refresh.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_refresh
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	RefreshToken *string `protobuf:"bytes,1,req,name=refreshToken" json:"refreshToken,omitempty"`
+	// jwt asks for the new token as a JWT too
+	Jwt              *bool  `protobuf:"varint,2,opt,name=jwt" json:"jwt,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetRefreshToken() string {
+	if m != nil && m.RefreshToken != nil {
+		return *m.RefreshToken
+	}
+	return ""
+}
+
+func (m *Request) GetJwt() bool {
+	if m != nil && m.Jwt != nil {
+		return *m.Jwt
+	}
+	return false
+}
+
+type Response struct {
+	SessId *string `protobuf:"bytes,1,req,name=sessId" json:"sessId,omitempty"`
+	Token  *string `protobuf:"bytes,2,req,name=token" json:"token,omitempty"`
+	// refreshToken replaces the one exchanged, which can't be used again
+	RefreshToken *string `protobuf:"bytes,3,req,name=refreshToken" json:"refreshToken,omitempty"`
+	// jwt is the same token as a signed JWT, if asked for
+	Jwt              *string `protobuf:"bytes,4,opt,name=jwt" json:"jwt,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetSessId() string {
+	if m != nil && m.SessId != nil {
+		return *m.SessId
+	}
+	return ""
+}
+
+func (m *Response) GetToken() string {
+	if m != nil && m.Token != nil {
+		return *m.Token
+	}
+	return ""
+}
+
+func (m *Response) GetRefreshToken() string {
+	if m != nil && m.RefreshToken != nil {
+		return *m.RefreshToken
+	}
+	return ""
+}
+
+func (m *Response) GetJwt() string {
+	if m != nil && m.Jwt != nil {
+		return *m.Jwt
+	}
+	return ""
+}
+
+func init() {
+}
@@ -0,0 +1,62 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/requestpasswordreset/requestpasswordreset.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_requestpasswordreset is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/requestpasswordreset/requestpasswordreset.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_requestpasswordreset
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	// defines the application this data logically belongs to
+	Application *string `protobuf:"bytes,1,req,name=application" json:"application,omitempty"`
+	// Who has forgotten their password
+	Username         *string `protobuf:"bytes,2,req,name=username" json:"username,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetApplication() string {
+	if m != nil && m.Application != nil {
+		return *m.Application
+	}
+	return ""
+}
+
+func (m *Request) GetUsername() string {
+	if m != nil && m.Username != nil {
+		return *m.Username
+	}
+	return ""
+}
+
+// Response is empty whether or not a reset token was sent, so that callers can't find out who has an account
+type Response struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func init() {
+}
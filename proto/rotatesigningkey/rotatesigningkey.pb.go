@@ -0,0 +1,69 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/rotatesigningkey/rotatesigningkey.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_rotatesigningkey is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/rotatesigningkey/rotatesigningkey.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_rotatesigningkey
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	// the key to sign with from now on, which must be in every instance's keyring
+	KeyId            *string `protobuf:"bytes,1,req,name=keyId" json:"keyId,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetKeyId() string {
+	if m != nil && m.KeyId != nil {
+		return *m.KeyId
+	}
+	return ""
+}
+
+type Response struct {
+	ActiveKeyId *string `protobuf:"bytes,1,opt,name=activeKeyId" json:"activeKeyId,omitempty"`
+	// empty for the legacy key
+	PreviousKeyId    *string `protobuf:"bytes,2,opt,name=previousKeyId" json:"previousKeyId,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetActiveKeyId() string {
+	if m != nil && m.ActiveKeyId != nil {
+		return *m.ActiveKeyId
+	}
+	return ""
+}
+
+func (m *Response) GetPreviousKeyId() string {
+	if m != nil && m.PreviousKeyId != nil {
+		return *m.PreviousKeyId
+	}
+	return ""
+}
+
+func init() {
+}
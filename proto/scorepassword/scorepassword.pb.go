@@ -0,0 +1,97 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/scorepassword/scorepassword.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_scorepassword is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/login-service/proto/scorepassword/scorepassword.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_scorepassword
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	// defines the application whose password policy the password is tested against
+	Application *string `protobuf:"bytes,1,req,name=application" json:"application,omitempty"`
+	// Who the password is for, if they have an account yet
+	Username *string `protobuf:"bytes,2,opt,name=username" json:"username,omitempty"`
+	// The candidate password, which isn't set
+	Password         *string `protobuf:"bytes,3,req,name=password" json:"password,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetApplication() string {
+	if m != nil && m.Application != nil {
+		return *m.Application
+	}
+	return ""
+}
+
+func (m *Request) GetUsername() string {
+	if m != nil && m.Username != nil {
+		return *m.Username
+	}
+	return ""
+}
+
+func (m *Request) GetPassword() string {
+	if m != nil && m.Password != nil {
+		return *m.Password
+	}
+	return ""
+}
+
+type Response struct {
+	// How hard the password is to guess, from 0 (too guessable) to 4 (very unguessable)
+	Score *int32 `protobuf:"varint,1,req,name=score" json:"score,omitempty"`
+	// Whether the password passes the application's policy, ie: whether changepassword would accept it
+	Valid *bool `protobuf:"varint,2,req,name=valid" json:"valid,omitempty"`
+	// Why the password fails the policy, one per failing check
+	Failures         []string `protobuf:"bytes,3,rep,name=failures" json:"failures,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetScore() int32 {
+	if m != nil && m.Score != nil {
+		return *m.Score
+	}
+	return 0
+}
+
+func (m *Response) GetValid() bool {
+	if m != nil && m.Valid != nil {
+		return *m.Valid
+	}
+	return false
+}
+
+func (m *Response) GetFailures() []string {
+	if m != nil {
+		return m.Failures
+	}
+	return nil
+}
+
+func init() {
+}
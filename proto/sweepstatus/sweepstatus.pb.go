@@ -0,0 +1,125 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/login-service/proto/sweepstatus/sweepstatus.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_service_login_sweepstatus is a generated protocol buffer package.
+
+It is generated from these files:
+
+	github.com/HailoOSS/login-service/proto/sweepstatus/sweepstatus.proto
+
+It has these top-level messages:
+
+	Request
+	Response
+*/
+package com_HailoOSS_service_login_sweepstatus
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+type Response struct {
+	Phase                 *string `protobuf:"bytes,1,opt,name=phase" json:"phase,omitempty"`
+	Position              *string `protobuf:"bytes,2,opt,name=position" json:"position,omitempty"`
+	Passes                *int64  `protobuf:"varint,3,opt,name=passes" json:"passes,omitempty"`
+	StartedTimestamp      *int64  `protobuf:"varint,4,opt,name=startedTimestamp" json:"startedTimestamp,omitempty"`
+	FinishedTimestamp     *int64  `protobuf:"varint,5,opt,name=finishedTimestamp" json:"finishedTimestamp,omitempty"`
+	CheckpointedTimestamp *int64  `protobuf:"varint,6,opt,name=checkpointedTimestamp" json:"checkpointedTimestamp,omitempty"`
+	SessionsScanned       *int64  `protobuf:"varint,7,opt,name=sessionsScanned" json:"sessionsScanned,omitempty"`
+	SessionsDeleted       *int64  `protobuf:"varint,8,opt,name=sessionsDeleted" json:"sessionsDeleted,omitempty"`
+	UserSessionsScanned   *int64  `protobuf:"varint,9,opt,name=userSessionsScanned" json:"userSessionsScanned,omitempty"`
+	UserSessionsDeleted   *int64  `protobuf:"varint,10,opt,name=userSessionsDeleted" json:"userSessionsDeleted,omitempty"`
+	XXX_unrecognized      []byte  `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetPhase() string {
+	if m != nil && m.Phase != nil {
+		return *m.Phase
+	}
+	return ""
+}
+
+func (m *Response) GetPosition() string {
+	if m != nil && m.Position != nil {
+		return *m.Position
+	}
+	return ""
+}
+
+func (m *Response) GetPasses() int64 {
+	if m != nil && m.Passes != nil {
+		return *m.Passes
+	}
+	return 0
+}
+
+func (m *Response) GetStartedTimestamp() int64 {
+	if m != nil && m.StartedTimestamp != nil {
+		return *m.StartedTimestamp
+	}
+	return 0
+}
+
+func (m *Response) GetFinishedTimestamp() int64 {
+	if m != nil && m.FinishedTimestamp != nil {
+		return *m.FinishedTimestamp
+	}
+	return 0
+}
+
+func (m *Response) GetCheckpointedTimestamp() int64 {
+	if m != nil && m.CheckpointedTimestamp != nil {
+		return *m.CheckpointedTimestamp
+	}
+	return 0
+}
+
+func (m *Response) GetSessionsScanned() int64 {
+	if m != nil && m.SessionsScanned != nil {
+		return *m.SessionsScanned
+	}
+	return 0
+}
+
+func (m *Response) GetSessionsDeleted() int64 {
+	if m != nil && m.SessionsDeleted != nil {
+		return *m.SessionsDeleted
+	}
+	return 0
+}
+
+func (m *Response) GetUserSessionsScanned() int64 {
+	if m != nil && m.UserSessionsScanned != nil {
+		return *m.UserSessionsScanned
+	}
+	return 0
+}
+
+func (m *Response) GetUserSessionsDeleted() int64 {
+	if m != nil && m.UserSessionsDeleted != nil {
+		return *m.UserSessionsDeleted
+	}
+	return 0
+}
+
+func init() {
+}
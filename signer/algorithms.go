@@ -0,0 +1,162 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/HailoOSS/service/config"
+)
+
+// The algorithms a token can be signed with, named as in JWA (RFC 7518) where there is a name. The
+// legacy algorithm is RSA PKCS#1 v1.5 with SHA-1, as the PHP login service signed, and is never
+// written into the token. RS256 is for JWTs signed with an RSA key when no modern algorithm is configured,
+// since JWTs can't use the legacy one.
+const (
+	AlgorithmLegacy = ""
+	AlgorithmRS256  = "RS256"
+	AlgorithmPS256  = "PS256"
+	AlgorithmES256  = "ES256"
+	AlgorithmEdDSA  = "EdDSA"
+)
+
+// ecdsaP256Size is the size of each of r and s in an ES256 signature, which is their concatenation
+const ecdsaP256Size = 32
+
+// algorithm signs and verifies with a single kind of key; sign and verify are nil for the legacy algorithm
+type algorithm struct {
+	// fits tests whether a key can be used with this algorithm
+	fits   func(pub crypto.PublicKey) bool
+	sign   func(prv crypto.Signer, data []byte) ([]byte, error)
+	verify func(pub crypto.PublicKey, sig, data []byte) error
+}
+
+var algorithms = map[string]*algorithm{
+	// the legacy algorithm's hash is up to the signer
+	AlgorithmLegacy: {fits: isRSA},
+	AlgorithmRS256: {
+		fits: isRSA,
+		sign: func(prv crypto.Signer, data []byte) ([]byte, error) {
+			digest := sha256.Sum256(data)
+			return rsa.SignPKCS1v15(rand.Reader, prv.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		},
+		verify: func(pub crypto.PublicKey, sig, data []byte) error {
+			digest := sha256.Sum256(data)
+			return rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], sig)
+		},
+	},
+	AlgorithmPS256: {
+		fits: isRSA,
+		sign: func(prv crypto.Signer, data []byte) ([]byte, error) {
+			digest := sha256.Sum256(data)
+			return rsa.SignPSS(rand.Reader, prv.(*rsa.PrivateKey), crypto.SHA256, digest[:], pssOptions)
+		},
+		verify: func(pub crypto.PublicKey, sig, data []byte) error {
+			digest := sha256.Sum256(data)
+			return rsa.VerifyPSS(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], sig, pssOptions)
+		},
+	},
+	AlgorithmES256: {
+		fits: func(pub crypto.PublicKey) bool {
+			ec, ok := pub.(*ecdsa.PublicKey)
+			return ok && ec.Curve == elliptic.P256()
+		},
+		sign: func(prv crypto.Signer, data []byte) ([]byte, error) {
+			digest := sha256.Sum256(data)
+			r, s, err := ecdsa.Sign(rand.Reader, prv.(*ecdsa.PrivateKey), digest[:])
+			if err != nil {
+				return nil, err
+			}
+			// r || s, each padded to the curve size, as JWS has it
+			sig := make([]byte, 2*ecdsaP256Size)
+			r.FillBytes(sig[:ecdsaP256Size])
+			s.FillBytes(sig[ecdsaP256Size:])
+			return sig, nil
+		},
+		verify: func(pub crypto.PublicKey, sig, data []byte) error {
+			if len(sig) != 2*ecdsaP256Size {
+				return fmt.Errorf("Signature is %d bytes, expecting %d", len(sig), 2*ecdsaP256Size)
+			}
+			digest := sha256.Sum256(data)
+			r := new(big.Int).SetBytes(sig[:ecdsaP256Size])
+			s := new(big.Int).SetBytes(sig[ecdsaP256Size:])
+			if !ecdsa.Verify(pub.(*ecdsa.PublicKey), digest[:], r, s) {
+				return fmt.Errorf("ECDSA verification failed")
+			}
+			return nil
+		},
+	},
+	AlgorithmEdDSA: {
+		fits: func(pub crypto.PublicKey) bool {
+			_, ok := pub.(ed25519.PublicKey)
+			return ok
+		},
+		sign: func(prv crypto.Signer, data []byte) ([]byte, error) {
+			return ed25519.Sign(prv.(ed25519.PrivateKey), data), nil
+		},
+		verify: func(pub crypto.PublicKey, sig, data []byte) error {
+			if !ed25519.Verify(pub.(ed25519.PublicKey), data, sig) {
+				return fmt.Errorf("Ed25519 verification failed")
+			}
+			return nil
+		},
+	},
+}
+
+var pssOptions = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+
+func isRSA(pub crypto.PublicKey) bool {
+	_, ok := pub.(*rsa.PublicKey)
+	return ok
+}
+
+// modernAlgorithms are tried in order for a key the configured algorithm can't use
+var modernAlgorithms = []string{AlgorithmPS256, AlgorithmES256, AlgorithmEdDSA}
+
+// chooseAlgorithm picks the algorithm to sign new tokens with using the supplied key: the one configured
+// at `hailo.service.login.signer.algorithm` (default legacy) if the key can be used with it, otherwise the
+// modern one for the key. It's an error if no algorithm can use the key.
+func chooseAlgorithm(pub crypto.PublicKey) (string, error) {
+	configured := config.AtPath("hailo", "service", "login", "signer", "algorithm").AsString(AlgorithmLegacy)
+	if alg, ok := algorithms[configured]; ok && alg.fits(pub) {
+		return configured, nil
+	}
+
+	for _, name := range modernAlgorithms {
+		if algorithms[name].fits(pub) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("No supported algorithm can sign with a %v key", describeKey(pub))
+}
+
+// supportedKey tests whether any algorithm can use a key; we only support RSA, ECDSA P-256 and Ed25519
+func supportedKey(pub crypto.PublicKey) bool {
+	for _, alg := range algorithms {
+		if alg.fits(pub) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeKey names a key's type (and curve, for ECDSA) for error messages
+func describeKey(pub crypto.PublicKey) string {
+	if ec, ok := pub.(*ecdsa.PublicKey); ok {
+		return "ECDSA " + ec.Curve.Params().Name
+	}
+	return fmt.Sprintf("%T", pub)
+}
+
+// acceptLegacy tests whether we still verify tokens signed with the legacy algorithm; turn on
+// `hailo.service.login.signer.rejectLegacy` to end the migration, once every such token has expired
+func acceptLegacy() bool {
+	return !config.AtPath("hailo", "service", "login", "signer", "rejectLegacy").AsBool()
+}
@@ -0,0 +1,139 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/HailoOSS/service/config"
+)
+
+// writeTestSigner writes any type of key into dir as kid, with the private key as PKCS8
+func writeTestSigner(t *testing.T, dir, kid string, prv crypto.Signer) {
+	pubBytes, err := x509.MarshalPKIXPublicKey(prv.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	prvBytes, err := x509.MarshalPKCS8PrivateKey(prv)
+	if err != nil {
+		t.Fatalf("Failed to marshal private key: %v", err)
+	}
+	pubPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes})
+	prvPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: prvBytes})
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+publicKeyExt), pubPem, 0600); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+privateKeyExt), prvPem, 0600); err != nil {
+		t.Fatalf("Failed to write private key: %v", err)
+	}
+}
+
+func loadSignerConfig(t *testing.T, algorithm string, rejectLegacy bool) {
+	cfg := `{"hailo":{"service":{"login":{"signer":{"algorithm":"` + algorithm + `","rejectLegacy":`
+	if rejectLegacy {
+		cfg += `true}}}}}`
+	} else {
+		cfg += `false}}}}}`
+	}
+	if err := config.Load(strings.NewReader(cfg)); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+}
+
+func TestAlgorithms(t *testing.T) {
+	defer setupKeyring(t)()
+	defer loadSignerConfig(t, "", false)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writeTestKey(t, keyringDir, "rsa", true)
+	writeTestSigner(t, keyringDir, "ec", ecKey)
+	writeTestSigner(t, keyringDir, "ed", edKey)
+
+	testCases := []struct {
+		kid, configured, expected string
+	}{
+		{"rsa", "", AlgorithmLegacy},
+		{"rsa", AlgorithmPS256, AlgorithmPS256},
+		// a configured algorithm the key can't do gets the key's modern algorithm
+		{"rsa", AlgorithmES256, AlgorithmPS256},
+		{"ec", "", AlgorithmES256},
+		{"ec", AlgorithmES256, AlgorithmES256},
+		{"ed", AlgorithmPS256, AlgorithmEdDSA},
+		{"ed", AlgorithmEdDSA, AlgorithmEdDSA},
+	}
+
+	for _, tc := range testCases {
+		s := makeSigner()
+		loadSignerConfig(t, tc.configured, false)
+		if !assert.NoError(t, s.Promote(tc.kid)) {
+			continue
+		}
+
+		token := mintToken()
+		signed, err := s.Sign(&token)
+		if !assert.NoError(t, err, "Failed to sign with %v/%v", tc.kid, tc.configured) {
+			continue
+		}
+		assert.Equal(t, tc.expected, signed.Algorithm, "Unexpected algorithm for %v/%v", tc.kid, tc.configured)
+		assert.True(t, s.Verify(signed), "Failed to verify with %v/%v", tc.kid, tc.configured)
+
+		tampered := signed.Copy()
+		tampered.Id = "Dave"
+		assert.False(t, s.Verify(tampered), "Expecting tampered token to fail for %v/%v", tc.kid, tc.configured)
+
+		// the algorithm is signed, so can't be swapped for another
+		tampered = signed.Copy()
+		tampered.Algorithm = AlgorithmPS256
+		if tampered.Algorithm != signed.Algorithm {
+			assert.False(t, s.Verify(tampered), "Expecting swapped algorithm to fail for %v/%v", tc.kid, tc.configured)
+		}
+	}
+}
+
+func TestUnsupportedKey(t *testing.T) {
+	defer setupKeyring(t)()
+
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, err := chooseAlgorithm(p384Key.Public())
+	assert.Error(t, err, "Expecting no algorithm for a P-384 key")
+
+	// it never makes it into the keyring, so can't be promoted to sign with
+	writeTestSigner(t, keyringDir, "p384", p384Key)
+	_, err = loadKeyPair(filepath.Join(keyringDir, "p384"))
+	assert.Error(t, err)
+	s := makeSigner()
+	assert.Error(t, s.Promote("p384"))
+	token := mintToken()
+	signed, err := s.Sign(&token)
+	if assert.NoError(t, err) {
+		assert.Equal(t, AlgorithmLegacy, signed.Algorithm, "Expecting to still sign with the legacy key")
+	}
+}
+
+func TestRejectLegacy(t *testing.T) {
+	defer loadSignerConfig(t, "", false)
+	s := makeSigner()
+	token := mintToken()
+
+	loadSignerConfig(t, AlgorithmPS256, false)
+	modern, err := s.Sign(&token)
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmPS256, modern.Algorithm)
+	assert.True(t, s.Verify(&token), "Expecting legacy token to verify during the migration")
+	assert.True(t, s.Verify(modern))
+
+	loadSignerConfig(t, AlgorithmPS256, true)
+	assert.False(t, s.Verify(&token), "Expecting legacy token to be rejected after the migration")
+	assert.True(t, s.Verify(modern))
+}
@@ -86,3 +86,47 @@ func bytesToPrivateKey(bytes []byte) (*rsa.PrivateKey, error) {
 
 	return prvKey, nil
 }
+
+// bytesToAnyPublicKey parses []byte into a PublicKey of any type we can sign with
+func bytesToAnyPublicKey(bytes []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(bytes)
+	if block == nil {
+		return nil, fmt.Errorf("Failed to decode public key - PEM decode failed")
+	}
+	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse PKIX public key: %v", err)
+	}
+
+	return pubKey, nil
+}
+
+// bytesToSigner parses []byte into a private key of any type we can sign with: PKCS1 for RSA, SEC1 for
+// ECDSA or PKCS8 for anything
+func bytesToSigner(bytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(bytes)
+	if block == nil {
+		return nil, fmt.Errorf("Failed to decode private key - PEM decode failed")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return bytesToPrivateKey(bytes)
+	case "EC PRIVATE KEY":
+		prvKey, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse EC private key: %v", err)
+		}
+		return prvKey, nil
+	}
+	someKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse PKCS8 private key: %v", err)
+	}
+	prvKey, ok := someKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Failed to cast to a signing key")
+	}
+
+	return prvKey, nil
+}
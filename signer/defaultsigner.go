@@ -3,7 +3,9 @@ package signer
 import (
 	"crypto"
 	"crypto/rsa"
+	"fmt"
 	"sort"
+	"sync"
 
 	log "github.com/cihub/seelog"
 
@@ -18,11 +20,17 @@ const (
 	publicKeyFn  = "/opt/hailo/login-service/public-key"
 )
 
-// defaultSigner is the default implementation - we read prv/pub key from file
+// defaultSigner is the default implementation - we read prv/pub key from file, plus any further keys from the
+// keyring
 type defaultSigner struct {
 	pub  *rsa.PublicKey
 	prv  *rsa.PrivateKey
 	hash crypto.Hash
+	// keyring holds keys by ID, reloaded every keyringReloadInterval; active is the one we sign with, ""
+	// meaning the legacy pub/prv above
+	keyMtx  sync.RWMutex
+	keyring map[string]*keyPair
+	active  string
 	// This channel is closed when the keys are loaded (used by WaitForLoad)
 	loadedChan       chan struct{}
 	loadedChanClosed bool
@@ -36,9 +44,108 @@ func newDefaultSigner() *defaultSigner {
 	}
 
 	go s.lazyLoadKeys()
+	go s.reloadKeyringEvery(keyringReloadInterval)
 	return s
 }
 
+// reloadKeyringEvery loads the keyring from disk, then keeps reloading it so that newly deployed keys are
+// staged (ie: used for verification) without a restart
+func (s *defaultSigner) reloadKeyringEvery(interval time.Duration) {
+	for {
+		if err := s.reloadKeyring(keyringDir); err != nil {
+			log.Warnf("[Signer] Failed to reload keyring: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (s *defaultSigner) reloadKeyring(dir string) error {
+	keys, err := loadKeyring(dir)
+	if err != nil {
+		return err
+	}
+
+	s.keyMtx.Lock()
+	defer s.keyMtx.Unlock()
+	if pair, ok := keys[s.active]; s.active != "" && (!ok || pair.prv == nil) {
+		// keep signing with the key we have rather than stop signing altogether
+		log.Errorf("[Signer] Active key '%v' has gone from the keyring, keeping the old copy", s.active)
+		keys[s.active] = s.keyring[s.active]
+	}
+	s.keyring = keys
+	return nil
+}
+
+// ActiveKeyId returns the ID of the key we sign with, "" meaning the legacy key
+func (s *defaultSigner) ActiveKeyId() string {
+	s.keyMtx.RLock()
+	defer s.keyMtx.RUnlock()
+	return s.active
+}
+
+// Promote switches to signing with the supplied key, reloading the keyring first in case it has only just
+// been deployed
+func (s *defaultSigner) Promote(kid string) error {
+	if err := s.reloadKeyring(keyringDir); err != nil {
+		return err
+	}
+
+	s.keyMtx.Lock()
+	defer s.keyMtx.Unlock()
+	pair, ok := s.keyring[kid]
+	if !ok {
+		return fmt.Errorf("No key '%v' in the keyring", kid)
+	} else if pair.prv == nil {
+		return fmt.Errorf("Key '%v' has no private key, so cannot sign", kid)
+	}
+	s.active = kid
+	return nil
+}
+
+// PublicKeys returns every key we can verify with, by ID ("" being the legacy key)
+func (s *defaultSigner) PublicKeys() map[string]crypto.PublicKey {
+	s.waitForLoad()
+	s.keyMtx.RLock()
+	defer s.keyMtx.RUnlock()
+	keys := make(map[string]crypto.PublicKey, len(s.keyring)+1)
+	if s.pub != nil {
+		keys[""] = s.pub
+	}
+	for kid, pair := range s.keyring {
+		keys[kid] = pair.pub
+	}
+	return keys
+}
+
+// signingKey returns the key ID and private key to sign with, or a nil key if we have none
+func (s *defaultSigner) signingKey() (string, crypto.Signer) {
+	s.keyMtx.RLock()
+	defer s.keyMtx.RUnlock()
+	if s.active != "" {
+		return s.active, s.keyring[s.active].prv
+	}
+	if s.prv == nil {
+		return "", nil
+	}
+	return "", s.prv
+}
+
+// verifyingKey returns the public key for a key ID, or nil if we don't have it
+func (s *defaultSigner) verifyingKey(kid string) crypto.PublicKey {
+	s.keyMtx.RLock()
+	defer s.keyMtx.RUnlock()
+	if kid == "" {
+		if s.pub == nil {
+			return nil
+		}
+		return s.pub
+	}
+	if pair, ok := s.keyring[kid]; ok {
+		return pair.pub
+	}
+	return nil
+}
+
 // lazyLoadKeys is ment for lazy private/public key initialization and ideally should be run
 // in a separate goroutine
 func (s *defaultSigner) lazyLoadKeys() {
@@ -94,29 +201,117 @@ func (s *defaultSigner) lazyLoadKeys() {
 	log.Debug("[Lazy key initialiser] Exiting")
 }
 
-// Sign will generate and add a signature to a token, using the configured private key
+// Sign will generate and add a signature to a token, using the active private key and the configured
+// algorithm (and recording both)
 func (s *defaultSigner) Sign(t *domain.Token) (*domain.Token, error) {
 	s.waitForLoad()
 	sort.Strings(t.Roles)
-	sig, err := sign(s.prv, s.hash, t.DataToSign())
+	tsig := t.Copy()
+	kid, prv := s.signingKey()
+	if prv == nil {
+		return nil, fmt.Errorf("Failed to sign: no private key")
+	}
+	alg, err := chooseAlgorithm(prv.Public())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to sign: %v", err)
+	}
+	tsig.KeyId = kid
+	tsig.Algorithm = alg
+
+	var sig []byte
+	if tsig.Algorithm == AlgorithmLegacy {
+		sig, err = sign(prv.(*rsa.PrivateKey), s.hash, tsig.DataToSign())
+	} else {
+		sig, err = algorithms[tsig.Algorithm].sign(prv, tsig.DataToSign())
+	}
 	if err != nil {
 		return nil, err
 	}
-	tsig := t.Copy()
 	tsig.Sign(sig)
 	return tsig, nil
 }
 
-// Validate will test whether a token's signature validates using the public key
+// Validate will test whether a token's signature validates using the public key and algorithm it was
+// signed with
 func (s *defaultSigner) Verify(t *domain.Token) bool {
 	s.waitForLoad()
-	ok, err := verify(s.pub, s.hash, t.DecodedSig(), t.DataToSign())
+	pub := s.verifyingKey(t.KeyId)
+	if pub == nil {
+		log.Debugf("Error verifying: unknown key '%v'", t.KeyId)
+		return false
+	}
+	alg, ok := algorithms[t.Algorithm]
+	if !ok || !alg.fits(pub) {
+		log.Debugf("Error verifying: algorithm '%v' cannot be used with key '%v'", t.Algorithm, t.KeyId)
+		return false
+	}
+
+	var err error
+	if t.Algorithm == AlgorithmLegacy {
+		if !acceptLegacy() {
+			log.Debugf("Error verifying: legacy algorithm no longer accepted")
+			return false
+		}
+		ok, err = verify(pub.(*rsa.PublicKey), s.hash, t.DecodedSig(), t.DataToSign())
+	} else {
+		err = alg.verify(pub, t.DecodedSig(), t.DataToSign())
+		ok = err == nil
+	}
 	if err != nil {
 		log.Debugf("Error verifying: %v", err)
 	}
 	return ok
 }
 
+// SignJWT signs a JWT with the active key. JWTs can't use the legacy algorithm, so RSA keys sign with RS256
+// unless a modern algorithm is configured.
+func (s *defaultSigner) SignJWT(c *JWTClaims) (string, error) {
+	s.waitForLoad()
+	kid, prv := s.signingKey()
+	if prv == nil {
+		return "", fmt.Errorf("Failed to sign: no private key")
+	}
+	alg, err := chooseAlgorithm(prv.Public())
+	if err != nil {
+		return "", fmt.Errorf("Failed to sign: %v", err)
+	}
+	h := &jwtHeader{Alg: alg, Typ: "JWT", Kid: kid}
+	if h.Alg == AlgorithmLegacy {
+		h.Alg = AlgorithmRS256
+	}
+
+	input, err := encodeJWT(h, c)
+	if err != nil {
+		return "", err
+	}
+	sig, err := algorithms[h.Alg].sign(prv, []byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + b64.EncodeToString(sig), nil
+}
+
+// VerifyJWT checks a JWT's signature using the key and algorithm named in its header
+func (s *defaultSigner) VerifyJWT(jwt string) (*JWTClaims, error) {
+	s.waitForLoad()
+	h, c, input, sig, err := decodeJWT(jwt)
+	if err != nil {
+		return nil, err
+	}
+	pub := s.verifyingKey(h.Kid)
+	if pub == nil {
+		return nil, fmt.Errorf("Unknown key '%v'", h.Kid)
+	}
+	alg, ok := algorithms[h.Alg]
+	if !ok || alg.verify == nil || !alg.fits(pub) {
+		return nil, fmt.Errorf("Algorithm '%v' cannot be used with key '%v'", h.Alg, h.Kid)
+	}
+	if err := alg.verify(pub, sig, []byte(input)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 func (s *defaultSigner) waitForLoad() {
 	<-s.loadedChan
 }
@@ -0,0 +1,144 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/HailoOSS/login-service/domain"
+)
+
+// jwtIssuer is the iss claim of every JWT we sign
+const jwtIssuer = "com.HailoOSS.service.login"
+
+// JWTClaims are the claims of a JWT (RFC 7519) carrying the same information as a token, plus the session
+// ID. Times are seconds since the epoch, as JWT has them; AutoRenew is 0 if the token can't be renewed.
+type JWTClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	SessionId     string   `json:"sid"`
+	IssuedAt      int64    `json:"iat"`
+	Expires       int64    `json:"exp"`
+	AuthMechanism string   `json:"am"`
+	DeviceType    string   `json:"d"`
+	AutoRenew     int64    `json:"rt,omitempty"`
+	Roles         []string `json:"roles"`
+}
+
+// NewJWTClaims maps a session's token to JWT claims
+func NewJWTClaims(sessId string, t *domain.Token) *JWTClaims {
+	c := &JWTClaims{
+		Issuer:        jwtIssuer,
+		Subject:       t.Id,
+		SessionId:     sessId,
+		IssuedAt:      t.Created.Unix(),
+		Expires:       t.Expires.Unix(),
+		AuthMechanism: t.AuthMechanism,
+		DeviceType:    t.DeviceType,
+		Roles:         t.Roles,
+	}
+	if !t.AutoRenew.IsZero() {
+		c.AutoRenew = t.AutoRenew.Unix()
+	}
+	if c.Roles == nil {
+		c.Roles = []string{}
+	}
+	return c
+}
+
+// Token maps the claims back to a token, without a signature
+func (c *JWTClaims) Token() *domain.Token {
+	t := &domain.Token{
+		Created:       time.Unix(c.IssuedAt, 0),
+		AuthMechanism: c.AuthMechanism,
+		DeviceType:    c.DeviceType,
+		Id:            c.Subject,
+		Expires:       time.Unix(c.Expires, 0),
+		Roles:         c.Roles,
+	}
+	if c.AutoRenew != 0 {
+		t.AutoRenew = time.Unix(c.AutoRenew, 0)
+	}
+	return t
+}
+
+// jwtHeader is the JOSE header of a JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// jwtSigner is implemented by signers that can also issue tokens as JWTs
+type jwtSigner interface {
+	// SignJWT returns a compact serialised JWT of the claims, signed with the active key
+	SignJWT(c *JWTClaims) (string, error)
+	// VerifyJWT checks a JWT's signature, returning its claims if it is valid
+	VerifyJWT(jwt string) (*JWTClaims, error)
+}
+
+// SignJWT signs a JWT for a session's token using defaultInstance
+func SignJWT(sessId string, t *domain.Token) (string, error) {
+	s, ok := defaultInstance.(jwtSigner)
+	if !ok {
+		return "", fmt.Errorf("Signer does not support JWTs")
+	}
+	return s.SignJWT(NewJWTClaims(sessId, t))
+}
+
+// VerifyJWT verifies a JWT using defaultInstance. It doesn't check expiry.
+func VerifyJWT(jwt string) (*JWTClaims, error) {
+	s, ok := defaultInstance.(jwtSigner)
+	if !ok {
+		return nil, fmt.Errorf("Signer does not support JWTs")
+	}
+	return s.VerifyJWT(jwt)
+}
+
+var b64 = base64.RawURLEncoding
+
+// encodeJWT serialises the header and claims, returning the signing input
+func encodeJWT(h *jwtHeader, c *JWTClaims) (string, error) {
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	cb, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return b64.EncodeToString(hb) + "." + b64.EncodeToString(cb), nil
+}
+
+// decodeJWT splits a compact serialised JWT into its header, claims, signing input and signature
+func decodeJWT(jwt string) (*jwtHeader, *JWTClaims, string, []byte, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return nil, nil, "", nil, fmt.Errorf("JWT has %d parts, expecting 3", len(parts))
+	}
+
+	h := &jwtHeader{}
+	if err := decodeJWTPart(parts[0], h); err != nil {
+		return nil, nil, "", nil, fmt.Errorf("Bad JWT header: %v", err)
+	}
+	c := &JWTClaims{}
+	if err := decodeJWTPart(parts[1], c); err != nil {
+		return nil, nil, "", nil, fmt.Errorf("Bad JWT claims: %v", err)
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("Bad JWT signature: %v", err)
+	}
+	return h, c, parts[0] + "." + parts[1], sig, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := b64.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
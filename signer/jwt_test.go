@@ -0,0 +1,89 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func jwtHeaderOf(t *testing.T, jwt string) map[string]interface{} {
+	b, err := base64.RawURLEncoding.DecodeString(strings.Split(jwt, ".")[0])
+	assert.NoError(t, err)
+	h := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(b, &h))
+	return h
+}
+
+func TestJWTClaims(t *testing.T) {
+	token := mintToken()
+	c := NewJWTClaims("sess1", &token)
+	assert.Equal(t, jwtIssuer, c.Issuer)
+	assert.Equal(t, "dave", c.Subject)
+	assert.Equal(t, "sess1", c.SessionId)
+	assert.Equal(t, int64(1378377733), c.IssuedAt)
+	assert.Equal(t, int64(1378406533), c.Expires)
+	assert.Equal(t, int64(0), c.AutoRenew)
+	assert.Equal(t, []string{"ADMIN"}, c.Roles)
+
+	back := c.Token()
+	assert.Equal(t, token.DataToSign(), back.DataToSign(), "Expecting the claims to map back to the same token")
+}
+
+func TestSignJWT(t *testing.T) {
+	s := makeSigner()
+	token := mintToken()
+
+	// the legacy key signs with RS256, since JWTs can't use the legacy algorithm
+	jwt, err := s.SignJWT(NewJWTClaims("sess1", &token))
+	assert.NoError(t, err)
+	h := jwtHeaderOf(t, jwt)
+	assert.Equal(t, AlgorithmRS256, h["alg"])
+	assert.Equal(t, "JWT", h["typ"])
+	assert.NotContains(t, h, "kid")
+
+	c, err := s.VerifyJWT(jwt)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "dave", c.Subject)
+		assert.Equal(t, "sess1", c.SessionId)
+	}
+
+	// tamper with the claims
+	parts := strings.Split(jwt, ".")
+	tampered := NewJWTClaims("sess1", &token)
+	tampered.Roles = []string{"ADMIN", "SUPERUSER"}
+	cb, _ := json.Marshal(tampered)
+	_, err = s.VerifyJWT(parts[0] + "." + base64.RawURLEncoding.EncodeToString(cb) + "." + parts[2])
+	assert.Error(t, err, "Expecting tampered claims to fail")
+
+	// nor may anyone switch the algorithm to none
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	_, err = s.VerifyJWT(none + "." + parts[1] + ".")
+	assert.Error(t, err, "Expecting an unsigned JWT to fail")
+
+	_, err = s.VerifyJWT("not-a-jwt")
+	assert.Error(t, err)
+}
+
+func TestSignJWTWithKeyring(t *testing.T) {
+	defer setupKeyring(t)()
+	s := makeSigner()
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	writeTestSigner(t, keyringDir, "ec", ecKey)
+	assert.NoError(t, s.Promote("ec"))
+
+	token := mintToken()
+	jwt, err := s.SignJWT(NewJWTClaims("sess1", &token))
+	assert.NoError(t, err)
+	h := jwtHeaderOf(t, jwt)
+	assert.Equal(t, AlgorithmES256, h["alg"])
+	assert.Equal(t, "ec", h["kid"])
+
+	_, err = s.VerifyJWT(jwt)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,136 @@
+package signer
+
+import (
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+)
+
+// the keyring lives next to the legacy key pair, and for the same reason isn't configurable (it's a var only
+// so tests can point it elsewhere). Each key is a `<kid>.pub` file, plus a `<kid>.key` file if we may sign
+// with it.
+var keyringDir = "/opt/hailo/login-service/keys"
+
+const (
+	publicKeyExt          = ".pub"
+	privateKeyExt         = ".key"
+	keyringReloadInterval = time.Minute
+	activeKeyPollInterval = 30 * time.Second
+)
+
+// keyPair is a single key from the keyring, of any type we have an algorithm for; prv is nil for keys that
+// can only verify
+type keyPair struct {
+	pub crypto.PublicKey
+	prv crypto.Signer
+}
+
+// keyRotator is implemented by signers that have a keyring of keys to sign with
+type keyRotator interface {
+	// ActiveKeyId returns the ID of the key we sign with, "" meaning the legacy key
+	ActiveKeyId() string
+	// Promote switches to signing with the supplied key, which must be in the keyring with a private key
+	Promote(kid string) error
+}
+
+// ActiveKeyId returns the ID of the key defaultInstance signs with
+func ActiveKeyId() string {
+	if r, ok := defaultInstance.(keyRotator); ok {
+		return r.ActiveKeyId()
+	}
+	return ""
+}
+
+// Promote switches defaultInstance to signing with the supplied key
+func Promote(kid string) error {
+	r, ok := defaultInstance.(keyRotator)
+	if !ok {
+		return fmt.Errorf("Signer does not support key rotation")
+	}
+	return r.Promote(kid)
+}
+
+// WatchActiveKey polls source for the ID of the key we should sign with, promoting it whenever it changes, so
+// that a rotation triggered on any one instance reaches them all
+func WatchActiveKey(source func() (string, error)) {
+	log.Info("[Signer] Watching for signing key rotation...")
+	go func() {
+		for {
+			if kid, err := source(); err != nil {
+				log.Warnf("[Signer] Failed to read active signing key: %v", err)
+			} else if kid != "" && kid != ActiveKeyId() {
+				if err := Promote(kid); err != nil {
+					log.Errorf("[Signer] Failed to promote signing key '%v': %v", kid, err)
+				} else {
+					log.Infof("[Signer] Now signing with key '%v'", kid)
+				}
+			}
+			time.Sleep(activeKeyPollInterval)
+		}
+	}()
+}
+
+// loadKeyring reads every key in dir. A missing directory is an empty keyring, and a bad key is skipped
+// (with a warning) rather than failing the lot.
+func loadKeyring(dir string) (map[string]*keyPair, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return map[string]*keyPair{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not read keyring '%v': %v", dir, err)
+	}
+
+	keys := make(map[string]*keyPair)
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), publicKeyExt) {
+			continue
+		}
+		kid := strings.TrimSuffix(f.Name(), publicKeyExt)
+		pair, err := loadKeyPair(filepath.Join(dir, kid))
+		if err != nil {
+			log.Warnf("[Signer] Skipping key '%v': %v", kid, err)
+			continue
+		}
+		keys[kid] = pair
+	}
+	return keys, nil
+}
+
+// loadKeyPair loads the public key at fn + publicKeyExt, and the private key at fn + privateKeyExt if it exists
+func loadKeyPair(fn string) (*keyPair, error) {
+	pubBytes, err := loadKeyToBytes(fn + publicKeyExt)
+	if err != nil {
+		return nil, err
+	}
+	pair := &keyPair{}
+	if pair.pub, err = bytesToAnyPublicKey(pubBytes); err != nil {
+		return nil, err
+	}
+	if !supportedKey(pair.pub) {
+		return nil, fmt.Errorf("Unsupported key: only RSA, ECDSA P-256 and Ed25519 keys can be used, not %v",
+			describeKey(pair.pub))
+	}
+
+	if _, err := os.Stat(fn + privateKeyExt); os.IsNotExist(err) {
+		return pair, nil
+	}
+	prvBytes, err := loadKeyToBytes(fn + privateKeyExt)
+	if err != nil {
+		return nil, err
+	}
+	if pair.prv, err = bytesToSigner(prvBytes); err != nil {
+		return nil, err
+	}
+	if pub, ok := pair.prv.Public().(interface {
+		Equal(crypto.PublicKey) bool
+	}); !ok || !pub.Equal(pair.pub) {
+		return nil, fmt.Errorf("Private key does not match public key")
+	}
+	return pair, nil
+}
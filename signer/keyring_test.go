@@ -0,0 +1,115 @@
+package signer
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestKey generates a key pair into dir as kid, leaving out the private key if !withPrivate
+func writeTestKey(t *testing.T, dir, kid string, withPrivate bool) {
+	prv, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(&prv.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	pubPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes})
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+publicKeyExt), pubPem, 0600); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+	if withPrivate {
+		prvPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(prv)})
+		if err := ioutil.WriteFile(filepath.Join(dir, kid+privateKeyExt), prvPem, 0600); err != nil {
+			t.Fatalf("Failed to write private key: %v", err)
+		}
+	}
+}
+
+func setupKeyring(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "keyring")
+	if err != nil {
+		t.Fatalf("Failed to create keyring dir: %v", err)
+	}
+	oldDir := keyringDir
+	keyringDir = dir
+	return func() {
+		keyringDir = oldDir
+		os.RemoveAll(dir)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	defer setupKeyring(t)()
+	s := makeSigner()
+
+	// tokens signed before rotation use the legacy key, with no kid
+	token := mintToken()
+	legacy, err := s.Sign(&token)
+	assert.NoError(t, err)
+	assert.Equal(t, "", legacy.KeyId)
+
+	// a key must be deployed before it can be promoted
+	assert.Error(t, s.Promote("2016-01"))
+	writeTestKey(t, keyringDir, "2016-01", true)
+	assert.NoError(t, s.Promote("2016-01"))
+	assert.Equal(t, "2016-01", s.ActiveKeyId())
+
+	rotated, err := s.Sign(&token)
+	assert.NoError(t, err)
+	assert.Equal(t, "2016-01", rotated.KeyId)
+	assert.True(t, s.Verify(rotated), "Expecting token signed with the new key to verify")
+	assert.True(t, s.Verify(legacy), "Expecting token signed with the legacy key to still verify")
+
+	// the kid is signed, so can't be swapped for another
+	swapped := rotated.Copy()
+	swapped.KeyId = ""
+	assert.False(t, s.Verify(swapped))
+	swapped.KeyId = "unknown"
+	assert.False(t, s.Verify(swapped))
+}
+
+func TestKeyringVerifyOnlyKeys(t *testing.T) {
+	defer setupKeyring(t)()
+	s := makeSigner()
+
+	writeTestKey(t, keyringDir, "retired", false)
+	assert.NoError(t, s.reloadKeyring(keyringDir))
+	if assert.Contains(t, s.keyring, "retired") {
+		assert.Nil(t, s.keyring["retired"].prv)
+	}
+	assert.Error(t, s.Promote("retired"), "Expecting a key without a private key not to be promoted")
+	assert.Equal(t, "", s.ActiveKeyId())
+
+	// a mismatched pair is skipped
+	writeTestKey(t, keyringDir, "a", true)
+	writeTestKey(t, keyringDir, "b", true)
+	os.Rename(filepath.Join(keyringDir, "b"+privateKeyExt), filepath.Join(keyringDir, "a"+privateKeyExt))
+	assert.NoError(t, s.reloadKeyring(keyringDir))
+	assert.NotContains(t, s.keyring, "a")
+}
+
+func TestKeyringKeepsActiveKey(t *testing.T) {
+	defer setupKeyring(t)()
+	s := makeSigner()
+
+	writeTestKey(t, keyringDir, "2016-01", true)
+	assert.NoError(t, s.Promote("2016-01"))
+	os.Remove(filepath.Join(keyringDir, "2016-01"+privateKeyExt))
+	assert.NoError(t, s.reloadKeyring(keyringDir))
+
+	token := mintToken()
+	signed, err := s.Sign(&token)
+	assert.NoError(t, err)
+	assert.Equal(t, "2016-01", signed.KeyId)
+	assert.True(t, s.Verify(signed))
+}
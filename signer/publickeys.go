@@ -0,0 +1,179 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/HailoOSS/login-service/domain"
+)
+
+// The states a public key can be in
+const (
+	KeyStatusActive  = "active"
+	KeyStatusStaged  = "staged"
+	KeyStatusRetired = "retired"
+)
+
+// PublicKey is a key that tokens can be verified with, for distribution to token verifiers
+type PublicKey struct {
+	// KeyId matches the kid in tokens; "" for the legacy key
+	KeyId  string
+	Key    crypto.PublicKey
+	Status string
+	// NotBefore is when the key started signing tokens; zero if it hasn't yet, or we don't know
+	NotBefore time.Time
+	// NotAfter is when every token a retired key signed will have expired; zero unless retired
+	NotAfter time.Time
+}
+
+// publicKeyLister is implemented by signers that can tell us the public halves of their keys
+type publicKeyLister interface {
+	// PublicKeys returns every key we can verify with, by ID ("" being the legacy key)
+	PublicKeys() map[string]crypto.PublicKey
+}
+
+// PublicKeys describes defaultInstance's keys, working out which are active, staged or retired from the
+// rotations (oldest first). Retired keys are left out once every token they signed has expired, which is
+// tokenTtl after the next rotation.
+func PublicKeys(rotations []*domain.KeyRotation, tokenTtl time.Duration) ([]*PublicKey, error) {
+	lister, ok := defaultInstance.(publicKeyLister)
+	if !ok {
+		return nil, fmt.Errorf("Signer cannot list its public keys")
+	}
+	return describeKeys(lister.PublicKeys(), rotations, tokenTtl, time.Now()), nil
+}
+
+func describeKeys(keys map[string]crypto.PublicKey, rotations []*domain.KeyRotation, tokenTtl time.Duration, now time.Time) []*PublicKey {
+	ret := make([]*PublicKey, 0, len(keys))
+	for kid, pub := range keys {
+		k := &PublicKey{KeyId: kid, Key: pub, Status: KeyStatusStaged}
+
+		last := -1
+		for i, r := range rotations {
+			if r.KeyId == kid {
+				last = i
+			}
+		}
+		switch {
+		case last >= 0 && last == len(rotations)-1:
+			k.Status = KeyStatusActive
+			k.NotBefore = rotations[last].Promoted
+		case last >= 0:
+			k.Status = KeyStatusRetired
+			k.NotBefore = rotations[last].Promoted
+			k.NotAfter = rotations[last+1].Promoted.Add(tokenTtl)
+		case kid == "" && len(rotations) == 0:
+			k.Status = KeyStatusActive
+		case kid == "":
+			// the legacy key was in use until the first rotation
+			k.Status = KeyStatusRetired
+			k.NotAfter = rotations[0].Promoted.Add(tokenTtl)
+		}
+
+		if k.Status == KeyStatusRetired && !k.NotAfter.After(now) {
+			continue
+		}
+		ret = append(ret, k)
+	}
+	sort.Sort(publicKeysByStatus(ret))
+	return ret
+}
+
+// PEM encodes the key as PKIX, the same as our public key files
+func (k *PublicKey) PEM() (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(k.Key)
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal public key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// jwk is a single key in a JSON Web Key Set (RFC 7517, with RFC 8037 for Ed25519); nbf/exp carry the
+// validity window
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	Nbf int64  `json:"nbf,omitempty"`
+	Exp int64  `json:"exp,omitempty"`
+}
+
+// JWKS renders keys as a JSON Web Key Set document. RSA keys have no alg, since they may sign with either
+// the legacy algorithm or PS256.
+func JWKS(keys []*PublicKey) ([]byte, error) {
+	doc := struct {
+		Keys []*jwk `json:"keys"`
+	}{Keys: make([]*jwk, len(keys))}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	for i, k := range keys {
+		j := &jwk{
+			Use: "sig",
+			Kid: k.KeyId,
+			Nbf: unixOrZero(k.NotBefore),
+			Exp: unixOrZero(k.NotAfter),
+		}
+		switch pub := k.Key.(type) {
+		case *rsa.PublicKey:
+			j.Kty = "RSA"
+			j.N = b64(pub.N.Bytes())
+			j.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			if !algorithms[AlgorithmES256].fits(pub) {
+				return nil, fmt.Errorf("Unsupported key %v for key '%v'", describeKey(pub), k.KeyId)
+			}
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			j.Kty, j.Alg, j.Crv = "EC", AlgorithmES256, pub.Curve.Params().Name
+			j.X = b64(pub.X.FillBytes(make([]byte, size)))
+			j.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			j.Kty, j.Alg, j.Crv = "OKP", AlgorithmEdDSA, "Ed25519"
+			j.X = b64(pub)
+		default:
+			return nil, fmt.Errorf("Unsupported key type %T for key '%v'", k.Key, k.KeyId)
+		}
+		doc.Keys[i] = j
+	}
+	return json.Marshal(doc)
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// publicKeysByStatus sorts active, then staged, then retired keys, newest first within each
+type publicKeysByStatus []*PublicKey
+
+var keyStatusOrder = map[string]int{KeyStatusActive: 0, KeyStatusStaged: 1, KeyStatusRetired: 2}
+
+func (l publicKeysByStatus) Len() int      { return len(l) }
+func (l publicKeysByStatus) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l publicKeysByStatus) Less(i, j int) bool {
+	if l[i].Status != l[j].Status {
+		return keyStatusOrder[l[i].Status] < keyStatusOrder[l[j].Status]
+	}
+	if !l[i].NotBefore.Equal(l[j].NotBefore) {
+		return l[i].NotBefore.After(l[j].NotBefore)
+	}
+	return l[i].KeyId < l[j].KeyId
+}
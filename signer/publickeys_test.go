@@ -0,0 +1,108 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/HailoOSS/login-service/domain"
+)
+
+func TestDescribeKeys(t *testing.T) {
+	pub, _ := bytesToPublicKey([]byte(testPublicKey))
+	keys := map[string]crypto.PublicKey{"": pub, "k1": pub, "k2": pub, "k3": pub, "k4": pub}
+	now := time.Unix(1378740807, 0)
+	ttl := 8 * time.Hour
+
+	// no rotations: only the legacy key has ever signed
+	described := describeKeys(keys, nil, ttl, now)
+	if assert.Len(t, described, 5) {
+		assert.Equal(t, "", described[0].KeyId)
+		assert.Equal(t, KeyStatusActive, described[0].Status)
+		for _, k := range described[1:] {
+			assert.Equal(t, KeyStatusStaged, k.Status)
+		}
+	}
+
+	// the legacy key and k1 have long been retired, k2 only just, and k3 is active
+	rotations := []*domain.KeyRotation{
+		{KeyId: "k1", Promoted: now.Add(-72 * time.Hour)},
+		{KeyId: "k2", Promoted: now.Add(-48 * time.Hour)},
+		{KeyId: "k3", Promoted: now.Add(-time.Hour)},
+	}
+	described = describeKeys(keys, rotations, ttl, now)
+	if assert.Len(t, described, 3) {
+		assert.Equal(t, "k3", described[0].KeyId)
+		assert.Equal(t, KeyStatusActive, described[0].Status)
+		assert.Equal(t, now.Add(-time.Hour), described[0].NotBefore)
+		assert.True(t, described[0].NotAfter.IsZero())
+
+		assert.Equal(t, "k4", described[1].KeyId)
+		assert.Equal(t, KeyStatusStaged, described[1].Status)
+
+		assert.Equal(t, "k2", described[2].KeyId)
+		assert.Equal(t, KeyStatusRetired, described[2].Status)
+		assert.Equal(t, now.Add(-48*time.Hour), described[2].NotBefore)
+		assert.Equal(t, now.Add(7*time.Hour), described[2].NotAfter)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	pub, _ := bytesToPublicKey([]byte(testPublicKey))
+	doc, err := JWKS([]*PublicKey{
+		{KeyId: "k1", Key: pub, Status: KeyStatusActive, NotBefore: time.Unix(1378740807, 0)},
+		{Key: pub, Status: KeyStatusRetired, NotAfter: time.Unix(1378769607, 0)},
+	})
+	assert.NoError(t, err)
+
+	parsed := struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}{}
+	assert.NoError(t, json.Unmarshal(doc, &parsed))
+	if assert.Len(t, parsed.Keys, 2) {
+		assert.Equal(t, "RSA", parsed.Keys[0]["kty"])
+		assert.Equal(t, "sig", parsed.Keys[0]["use"])
+		assert.Equal(t, "k1", parsed.Keys[0]["kid"])
+		assert.Equal(t, "Iw", parsed.Keys[0]["e"], "Expecting e=35, base64url encoded")
+		assert.Equal(t, float64(1378740807), parsed.Keys[0]["nbf"])
+		assert.NotContains(t, parsed.Keys[0], "exp")
+
+		assert.NotContains(t, parsed.Keys[1], "kid")
+		assert.Equal(t, float64(1378769607), parsed.Keys[1]["exp"])
+	}
+}
+
+func TestJWKSModernKeys(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	doc, err := JWKS([]*PublicKey{
+		{KeyId: "ec", Key: ecKey.Public(), Status: KeyStatusActive},
+		{KeyId: "ed", Key: edPub, Status: KeyStatusStaged},
+	})
+	assert.NoError(t, err)
+
+	parsed := struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}{}
+	assert.NoError(t, json.Unmarshal(doc, &parsed))
+	if assert.Len(t, parsed.Keys, 2) {
+		assert.Equal(t, "EC", parsed.Keys[0]["kty"])
+		assert.Equal(t, AlgorithmES256, parsed.Keys[0]["alg"])
+		assert.Equal(t, "P-256", parsed.Keys[0]["crv"])
+		assert.Len(t, parsed.Keys[0]["x"], 43, "Expecting a 32 byte coordinate, base64url encoded")
+		assert.Len(t, parsed.Keys[0]["y"], 43, "Expecting a 32 byte coordinate, base64url encoded")
+
+		assert.Equal(t, "OKP", parsed.Keys[1]["kty"])
+		assert.Equal(t, AlgorithmEdDSA, parsed.Keys[1]["alg"])
+		assert.Equal(t, "Ed25519", parsed.Keys[1]["crv"])
+		assert.Len(t, parsed.Keys[1]["x"], 43)
+		assert.NotContains(t, parsed.Keys[1], "y")
+	}
+}
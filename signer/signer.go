@@ -19,6 +19,11 @@ func init() {
 	defaultInstance = newDefaultSigner()
 }
 
+// SetSigner switches the Sign/Verify wrappers to use the supplied Signer
+func SetSigner(s Signer) {
+	defaultInstance = s
+}
+
 // Sign wraps defaultInstance.Sign
 func Sign(t *domain.Token) (*domain.Token, error) {
 	signed, err := defaultInstance.Sign(t)